@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/pdf"
+	"ezkitchen/internal/storage"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// agreementTerms are printed above the customer's signature on every agreement.
+const agreementTerms = `By signing, the customer agrees to the work and prices listed above. Prices include the ` +
+	`products, labor and sales tax shown; changes to the scope of work after signing are quoted separately. ` +
+	`Delivery and installation dates are scheduled once the products have been ordered. Payment is due as ` +
+	`agreed with your surveyor.`
+
+// recordAgreement stores what a customer signed, as part of the transaction that records their signature: the
+// signature image, the PDF agreement rendered from the products and prices they agreed to, and its certificate of
+// completion. The agreement's hash is recorded on the signature audit. If any of it fails the signature must not be
+// committed, so it is never recorded without the agreement it was made on.
+func (app *application) recordAgreement(ctx context.Context, tx *sql.Tx, estimate models.Estimate, customer models.User,
+	products []models.EstimateProduct, audit models.SignatureAudit, signaturePNG []byte) error {
+	signature, err := png.Decode(bytes.NewReader(signaturePNG))
+	if err != nil {
+		return fmt.Errorf("decoding signature of estimate %d: %w", estimate.EstimateID, err)
+	}
+
+	err = app.storage.UploadSignature(ctx, estimate.EstimateID, bytes.NewReader(signaturePNG), "image/png")
+	if err != nil {
+		return err
+	}
+
+	models.ApplyPriceTiers(products, models.ListValue(products))
+	totals := app.estimates.CalculateEstimateTotals(products)
+	estimate.SignedAt = sql.NullTime{Time: audit.SignedAt, Valid: true}
+
+	var buf bytes.Buffer
+	_, err = agreementDocument(estimate, customer, products, totals, signature).WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	err = app.storage.UploadAgreement(ctx, estimate.EstimateID, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	err = app.estimates.SetAgreementKeyTx(tx, estimate.EstimateID, storage.AgreementKey(estimate.EstimateID))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	audit.AgreementSHA256 = sql.NullString{String: hex.EncodeToString(sum[:]), Valid: true}
+	err = app.signatureAudits.SetAgreementHashTx(tx, estimate.EstimateID, audit.AgreementSHA256.String)
+	if err != nil {
+		return err
+	}
+
+	buf.Reset()
+	_, err = certificateDocument(audit, signature, time.Now()).WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	err = app.storage.UploadCertificate(ctx, estimate.EstimateID, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	return app.signatureAudits.SetCertificateKeyTx(tx, estimate.EstimateID, storage.CertificateKey(estimate.EstimateID))
+}
+
+// agreementDocument lays out a signed estimate for printing: the customer and address, every line item the customer
+// agreed to, the totals, the terms and the signature with when it was made.
+func agreementDocument(estimate models.Estimate, customer models.User, products []models.EstimateProduct, totals models.EstimateTotals, signature image.Image) *pdf.Document {
+	dollars := func(cents int) string { return fmt.Sprintf("$%.2f", float64(cents)/100) }
+
+	doc := pdf.New(fmt.Sprintf("Agreement for Estimate #%d", estimate.EstimateID))
+	doc.Text(18, pdf.Bold, "Kitchen Remodel Agreement")
+	doc.Text(10, pdf.Regular, fmt.Sprintf("Estimate: #%d", estimate.EstimateID))
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Customer")
+	doc.Text(10, pdf.Regular, customer.Name)
+	doc.Text(10, pdf.Regular, estimate.Street)
+	doc.Text(10, pdf.Regular, fmt.Sprintf("%s, %s %s", estimate.City, estimate.State, estimate.Zip))
+	for _, line := range []string{customer.Email, customer.Phone} {
+		if line != "" {
+			doc.Text(10, pdf.Regular, line)
+		}
+	}
+	doc.Space(14)
+
+	cols := func(item, quantity, price, total string) []pdf.Column {
+		return []pdf.Column{
+			{X: 0, Width: 290, Text: item},
+			{X: 296, Width: 50, Text: quantity, Right: true},
+			{X: 352, Width: 76, Text: price, Right: true},
+			{X: 434, Width: 70, Text: total, Right: true},
+		}
+	}
+
+	doc.Row(10, pdf.Bold, cols("Item", "Qty", "Unit Price", "Total")...)
+	doc.Rule()
+	for _, ep := range products {
+		name := ep.Product.Name
+		if !ep.EstimateItem.Taxable {
+			name += " (tax exempt)"
+		}
+		doc.Row(10, pdf.Regular, cols(name, strconv.Itoa(ep.EstimateItem.Quantity), dollars(ep.UnitPrice()), dollars(ep.LineTotal()))...)
+
+		if t := ep.AppliedTier; t != nil {
+			note := fmt.Sprintf("    %d+ unit price, was %s each", t.Threshold, dollars(ep.Product.UnitPrice))
+			if !t.IsQuantity() {
+				note = fmt.Sprintf("    Orders of %s+, was %s each", dollars(t.Threshold), dollars(ep.Product.UnitPrice))
+			}
+			doc.Row(9, pdf.Regular, cols(note, "", "", "")...)
+		}
+		for _, c := range ep.Components {
+			doc.Row(9, pdf.Regular, cols("    "+c.Product.Name, strconv.Itoa(c.Total(ep.EstimateItem.Quantity)), "Included", "")...)
+		}
+	}
+	doc.Rule()
+	doc.Row(10, pdf.Regular, cols("", "", "Subtotal", dollars(totals.Subtotal))...)
+	doc.Row(10, pdf.Regular, cols("", "", "Labor", dollars(totals.LaborTotal))...)
+	doc.Row(10, pdf.Regular, cols("", "", "Sales Tax", dollars(totals.SalesTax))...)
+	doc.Row(11, pdf.Bold, cols("", "", "Total", dollars(totals.EstimateTotal))...)
+	doc.Space(18)
+
+	doc.Text(11, pdf.Bold, "Terms")
+	doc.Paragraph(10, pdf.Regular, agreementTerms)
+	doc.Space(18)
+
+	doc.Text(11, pdf.Bold, "Customer Signature")
+	doc.Image(signature, 200)
+	doc.Rule()
+	signed := "Signed by " + customer.Name
+	if estimate.SignedAt.Valid {
+		signed += " on " + estimate.SignedAt.Time.Format("Jan 2, 2006 at 3:04 PM MST")
+	}
+	doc.Text(10, pdf.Regular, signed)
+
+	return doc
+}
+
+// estimateAgreement downloads the PDF agreement of a signed estimate.
+func (app *application) estimateAgreement(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	allowed, err := app.estimates.CanAccess(id, app.currentUser(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !allowed {
+		http.NotFound(w, r)
+		return
+	}
+
+	app.serveAgreement(w, r, id)
+}
+
+// invoiceAgreement lets a customer download the agreement they signed using the link from their invoice. The link
+// only works once the invoice has been signed and until it expires.
+func (app *application) invoiceAgreement(w http.ResponseWriter, r *http.Request) {
+	it, err := app.invoiceToken.GetByRawToken(r.URL.Query().Get("token"))
+	if err != nil || !it.UsedAt.Valid || time.Now().After(it.ExpiresAt) {
+		http.NotFound(w, r)
+		return
+	}
+
+	app.serveAgreement(w, r, it.EstimateID)
+}
+
+// serveAgreement writes the agreement stored when an estimate was signed. It is never generated here: an agreement
+// rendered now would show the estimate as it is today rather than what the customer signed.
+func (app *application) serveAgreement(w http.ResponseWriter, r *http.Request, estimateID int) {
+	estimate, err := app.estimates.Get(estimateID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if !estimate.SignatureObjectKey.Valid {
+		http.NotFound(w, r)
+		return
+	}
+	if !estimate.AgreementObjectKey.Valid {
+		app.serverError(w, r, fmt.Errorf("estimate %d was signed but its agreement was never stored", estimateID))
+		return
+	}
+
+	app.servePDF(w, r, estimate.AgreementObjectKey.String, fmt.Sprintf("agreement-%d.pdf", estimateID))
+}
+
+// servePDF writes a stored PDF as a download named filename.
+func (app *application) servePDF(w http.ResponseWriter, r *http.Request, key, filename string) {
+	obj, err := app.storage.Get(r.Context(), key)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+
+	_, err = io.Copy(w, obj.Body)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/pdf"
+	"fmt"
+	"image"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// consentStatement is what a customer agrees to by signing electronically. The version is recorded with every
+// signature, so change it whenever the text changes.
+type consentStatement struct {
+	Version string
+	Text    string
+}
+
+// esignConsent is the consent statement currently shown on the signing page.
+var esignConsent = consentStatement{
+	Version: "2026-10-19",
+	Text: "I agree to sign this agreement electronically. My electronic signature has the same effect as a " +
+		"handwritten signature, and I have reviewed the items, options and totals shown above.",
+}
+
+// auditTime is how times are printed on certificates of completion.
+const auditTime = "Jan 2, 2006 at 3:04:05 PM MST"
+
+// clientIP returns the address a request came from, without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// certificateDocument lays out the certificate of completion: who signed, when the signing link was sent, opened
+// and signed, the device it was signed from, the consent statement agreed to and the hash of the agreement.
+func certificateDocument(audit models.SignatureAudit, signature image.Image, generatedAt time.Time) *pdf.Document {
+	field := func(label, value string) []pdf.Column {
+		return []pdf.Column{
+			{X: 0, Width: 120, Text: label},
+			{X: 126, Width: pdf.ContentWidth - 126, Text: value},
+		}
+	}
+
+	opened := "Not recorded"
+	if audit.LinkOpenedAt.Valid {
+		opened = audit.LinkOpenedAt.Time.Format(auditTime)
+	}
+
+	doc := pdf.New(fmt.Sprintf("Certificate of Completion for Estimate #%d", audit.EstimateID))
+	doc.Text(18, pdf.Bold, "Certificate of Completion")
+	doc.Text(10, pdf.Regular, fmt.Sprintf("Kitchen Remodel Agreement for Estimate #%d", audit.EstimateID))
+	doc.Space(14)
+
+	doc.Text(11, pdf.Bold, "Signer")
+	doc.Rule()
+	doc.Row(10, pdf.Regular, field("Name", audit.SignerName)...)
+	doc.Row(10, pdf.Regular, field("Email", audit.SignerEmail)...)
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Signing Events")
+	doc.Rule()
+	doc.Row(10, pdf.Regular, field("Link sent", audit.LinkSentAt.Format(auditTime))...)
+	doc.Row(10, pdf.Regular, field("Link opened", opened)...)
+	doc.Row(10, pdf.Regular, field("Signed", audit.SignedAt.Format(auditTime))...)
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Signing Device")
+	doc.Rule()
+	doc.Row(10, pdf.Regular, field("IP address", audit.IPAddress)...)
+	doc.Row(10, pdf.Regular, field("User agent", "")...)
+	doc.Paragraph(9, pdf.Regular, audit.UserAgent)
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Consent")
+	doc.Rule()
+	doc.Row(10, pdf.Regular, field("Version", audit.ConsentVersion)...)
+	doc.Paragraph(10, pdf.Regular, audit.ConsentText)
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Signed Document")
+	doc.Rule()
+	doc.Row(10, pdf.Regular, field("Agreement", fmt.Sprintf("agreement-%d.pdf", audit.EstimateID))...)
+	doc.Row(10, pdf.Regular, field("SHA-256", "")...)
+	doc.Text(9, pdf.Regular, audit.AgreementSHA256.String)
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Signature")
+	doc.Image(signature, 200)
+	doc.Rule()
+	doc.Space(6)
+
+	doc.Paragraph(9, pdf.Regular, fmt.Sprintf("Generated by EzKitchen on %s. The SHA-256 hash above identifies the "+
+		"agreement that was signed; any change to the agreement changes its hash.", generatedAt.Format(auditTime)))
+
+	return doc
+}
+
+// estimateCertificate downloads the certificate of completion of a signed estimate.
+func (app *application) estimateCertificate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	allowed, err := app.estimates.CanAccess(id, app.currentUser(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !allowed {
+		http.NotFound(w, r)
+		return
+	}
+
+	audit, err := app.signatureAudits.GetByEstimateID(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !audit.CertificateObjectKey.Valid {
+		app.serverError(w, r, fmt.Errorf("estimate %d was signed but its certificate was never stored", id))
+		return
+	}
+
+	app.servePDF(w, r, audit.CertificateObjectKey.String, fmt.Sprintf("certificate-%d.pdf", id))
+}
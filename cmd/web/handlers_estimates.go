@@ -5,21 +5,58 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"ezkitchen/internal/mailer"
 	"ezkitchen/internal/models"
 	"ezkitchen/internal/validator"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type productRequestBody struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID  int   `json:"product_id"`
+	Quantity   int   `json:"quantity"`
+	OptionID   int   `json:"option_id"`
+	IsOptional *bool `json:"is_optional"`
 	validator.Validator
 }
+
+type customItemRequestBody struct {
+	Description string `json:"description"`
+	UnitPrice   int    `json:"unit_price"`
+	Quantity    int    `json:"quantity"`
+	Category    string `json:"category"`
+	Taxable     *bool  `json:"taxable"`
+	IsOptional  bool   `json:"is_optional"`
+	validator.Validator
+}
+
+type itemOperationRequest struct {
+	Op                  string `json:"op"`
+	LineItemID          int    `json:"line_item_id"`
+	ProductID           int    `json:"product_id"`
+	Quantity            int    `json:"quantity"`
+	OptionID            int    `json:"option_id"`
+	IsOptional          *bool  `json:"is_optional"`
+	validator.Validator `json:"-"`
+}
+
+type itemBatchRequest struct {
+	Operations []itemOperationRequest `json:"operations"`
+}
+
+type itemOperationResult struct {
+	Index      int               `json:"index"`
+	Op         string            `json:"op"`
+	OK         bool              `json:"ok"`
+	LineItemID int               `json:"line_item_id,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// maxItemBatchSize caps how many operations a single batch request may contain.
+const maxItemBatchSize = 100
+
 type estimateCreateForm struct {
 	Name                string  `form:"customerName"`
 	StreetAddress       string  `form:"streetAddress"`
@@ -89,11 +126,15 @@ func (app *application) estimateView(w http.ResponseWriter, r *http.Request) {
 		} else {
 			app.serverError(w, r, err)
 		}
+		return
 	}
 
-	currUser := app.currentUser(r)
-
-	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
+	allowed, err := app.estimates.CanAccess(estimate.EstimateID, app.currentUser(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !allowed {
 		app.clientError(w, r, http.StatusNotFound)
 		return
 	}
@@ -104,7 +145,14 @@ func (app *application) estimateView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	estimateTotals := app.estimates.CalculateEstimateTotals(estimateProducts)
+	baseProducts, optionGroups, err := app.estimateOptions(estimate.EstimateID, estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	requiredProducts, addonProducts := models.SplitOptionalProducts(baseProducts)
+	estimateTotals := app.estimates.CalculateEstimateTotals(requiredProducts)
 
 	customer, err := app.users.Get(estimate.CustomerID)
 	if err != nil {
@@ -115,9 +163,52 @@ func (app *application) estimateView(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Estimate = estimate
 	data.Customer = customer
-	data.Products = estimateProducts
+	data.Products = baseProducts
+	data.Addons = addonProducts
+	data.OptionGroups = optionGroups
 	data.EstimateTotals = estimateTotals
 
+	data.CustomerResponses, err = app.estimateResponses.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	audit, err := app.signatureAudits.GetByEstimateID(estimate.EstimateID)
+	switch {
+	case err == nil:
+		data.SignatureAudit = &audit
+	case !errors.Is(err, models.ErrNoRecord):
+		app.serverError(w, r, err)
+		return
+	}
+
+	if data.IsAdmin {
+		// Margins cover what the customer is buying: the required items, which include the add-ons accepted when
+		// signing, and the option chosen in each group.
+		selections := make(map[int]int, len(optionGroups))
+		for _, group := range optionGroups {
+			if group.SelectedOptionID.Valid {
+				selections[group.GroupID] = int(group.SelectedOptionID.Int64)
+			}
+		}
+		chosen := chosenProducts(requiredProducts, nil, optionGroups, selections, nil)
+		models.ApplyPriceTiers(chosen, models.ListValue(chosen))
+		data.Margins = models.CalculateMargins(chosen)
+
+		data.PurchaseOrders, err = app.purchaseOrders.GetByEstimateID(estimate.EstimateID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.SigningLinks, err = app.signingLinks(estimate.EstimateID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	app.render(w, r, http.StatusOK, "viewEstimate.tmpl", data)
 }
 
@@ -240,11 +331,15 @@ func (app *application) estimateEditView(w http.ResponseWriter, r *http.Request)
 		} else {
 			app.serverError(w, r, err)
 		}
+		return
 	}
 
-	currUser := app.currentUser(r)
-
-	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
+	allowed, err := app.estimates.CanAccess(estimate.EstimateID, app.currentUser(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !allowed {
 		app.clientError(w, r, http.StatusNotFound)
 		return
 	}
@@ -260,7 +355,14 @@ func (app *application) estimateEditView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	estimateTotals := app.estimates.CalculateEstimateTotals(estimateProducts)
+	baseProducts, optionGroups, err := app.estimateOptions(estimate.EstimateID, estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	requiredProducts, addonProducts := models.SplitOptionalProducts(baseProducts)
+	estimateTotals := app.estimates.CalculateEstimateTotals(requiredProducts)
 
 	customer, err := app.users.Get(estimate.CustomerID)
 	if err != nil {
@@ -268,11 +370,49 @@ func (app *application) estimateEditView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	shares, err := app.estimates.GetShares(estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	openings, err := app.estimateOpenings.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	currUser := app.currentUser(r)
+
 	data := app.newTemplateData(r)
 	data.Estimate = estimate
 	data.Customer = customer
-	data.Products = estimateProducts
+	data.Products = baseProducts
+	data.Addons = addonProducts
+	data.OptionGroups = optionGroups
 	data.EstimateTotals = estimateTotals
+	data.Shares = shares
+	data.CanShare = currUser.UserID == estimate.CreatedBy || currUser.Role == models.RoleAdmin
+	data.Openings = openings
+	data.DeliveryChecks = deliveryReport(pathFromOpenings(estimate, openings), estimateProducts)
+
+	data.StockWarnings, err = app.stockWarnings(estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.CompatFindings, data.SiteChecklist, err = app.compatibility(estimate.EstimateID, estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data.CustomerResponses, err = app.estimateResponses.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	app.render(w, r, http.StatusOK, "editEstimate.tmpl", data)
 
@@ -300,7 +440,13 @@ func (app *application) progressEstimate(w http.ResponseWriter, r *http.Request)
 
 	currUser := app.currentUser(r)
 
-	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
+	// Anyone who can work on the estimate's line items can also move it forward.
+	allowed, err := app.estimates.CanAccess(estimate.EstimateID, currUser)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !allowed {
 		app.clientError(w, r, http.StatusNotFound)
 		return
 	}
@@ -326,36 +472,68 @@ func (app *application) progressEstimate(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		err = app.estimates.UpdateStatus(id, estimate.Status.Next())
+		_, optionGroups, err := app.estimateOptions(id, ei)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
-		expiresAt := time.Now().Add(72 * time.Hour)
-		rawToken, err := app.invoiceToken.Insert(id, expiresAt)
+		for _, group := range optionGroups {
+			complete := len(group.Options) > 0
+			for _, option := range group.Options {
+				if len(option.Products) == 0 {
+					complete = false
+				}
+			}
+
+			if !complete {
+				app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+					Type:    "error",
+					Message: fmt.Sprintf("Every option in the %q group needs at least one product before submitting!", group.Name),
+				})
+				http.Redirect(
+					w, r,
+					fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID),
+					http.StatusSeeOther,
+				)
+				return
+			}
+		}
+
+		// Leaving Draft freezes the estimate's prices, so catalog changes no longer move what the customer signs.
+		tx, err := app.estimates.DB.Begin()
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
+		defer tx.Rollback()
 
-		customer, err := app.users.Get(estimate.CustomerID)
+		err = app.estimates.UpdateStatusTx(tx, id, estimate.Status.Next())
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 
-		signURL := os.Getenv("APP_BASE_URL") + "/invoice/sign?token=" + rawToken
+		err = app.estimateItems.SnapshotPricesTx(tx, id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.estimateItems.SnapshotBundlesTx(tx, id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 
-		invoiceData := mailer.InvoiceLinkData{
-			CustomerName:   customer.Name,
-			EstimateNumber: estimate.EstimateID,
-			SignURL:        signURL,
-			ExpiresAt:      expiresAt.Format("Jan 2, 2006 3:04 PM")}
+		err = tx.Commit()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
 
-		err = app.mailer.SendInvoiceLink(customer.Email, invoiceData)
+		err = app.sendInvoiceLink(estimate, currUser.UserID)
 		if err != nil {
-			app.logger.Error("email send failed", "error", err)
 			app.serverError(w, r, err)
 			return
 		}
@@ -367,7 +545,27 @@ func (app *application) progressEstimate(w http.ResponseWriter, r *http.Request)
 
 	case models.StatusInProgress:
 
-		err = app.estimates.UpdateStatus(id, estimate.Status.Next())
+		// Completing the install takes the stock reserved at signing off hand.
+		tx, err := app.estimates.DB.Begin()
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		defer tx.Rollback()
+
+		err = app.estimates.UpdateStatusTx(tx, id, estimate.Status.Next())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.inventory.ConsumeTx(tx, id, currUser.UserID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = tx.Commit()
 		if err != nil {
 			app.serverError(w, r, err)
 			return
@@ -436,70 +634,217 @@ func (app *application) estimateDelete(w http.ResponseWriter, r *http.Request) {
 func (app *application) estimateAddItem(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil || id < 1 {
-		http.NotFound(w, r)
+		app.errorJSON(w, http.StatusNotFound, "estimate not found")
 		return
 	}
 
 	var req productRequestBody
-	json.NewDecoder(r.Body).Decode(&req)
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, "The request body is not valid JSON.")
+		return
+	}
+
+	if !app.authorizeItemChange(w, r, id) {
+		return
+	}
 
 	item := &models.EstimateItem{
 		EstimateID: id,
 		ProductID:  req.ProductID,
 		Quantity:   req.Quantity,
+		IsOptional: req.IsOptional != nil && *req.IsOptional,
+	}
+
+	if req.OptionID > 0 {
+		optionEstimateID, err := app.estimateOptionGroups.GetOptionEstimateID(req.OptionID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		req.CheckField(err == nil && optionEstimateID == id, "option", "The selected option does not belong to this estimate.")
+		item.OptionID = sql.NullInt64{Int64: int64(req.OptionID), Valid: true}
 	}
 
 	estimate, err := app.estimates.Get(item.EstimateID)
 	if err != nil {
 		app.serverError(w, r, err)
+		return
+	}
+
+	req.CheckField(validator.GreaterThanN(item.Quantity, 0), "quantity", "The quantity must be at least 1")
+
+	var components []models.BundleComponent
+	product, err := app.products.Get(item.ProductID)
+	if err != nil {
+		if !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+		req.CheckField(false, "product", "The selected product does not exist.")
+	} else if product.Discontinued {
+		req.AddFieldError("product", "This product has been discontinued.")
+	} else {
+		path, err := app.deliveryPath(estimate)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		bundles, err := app.products.GetBundleComponents([]int{product.ProductID})
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		components = bundles[product.ProductID]
 
+		message := discontinuedComponent(components)
+		if message == "" {
+			message = deliveryProblem(product, components, path)
+		}
+		req.CheckField(message == "", "product", message)
 	}
 
-	currUser := app.currentUser(r)
+	if !req.Valid() {
+		var proposed []models.EstimateProduct
+		if product.ProductID != 0 {
+			proposed = append(proposed, models.EstimateProduct{Product: product, EstimateItem: *item, Components: components})
+		}
 
-	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
-		app.clientError(w, r, http.StatusNotFound)
+		warnings, err := app.itemChangeWarnings(id, proposed...)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.failedValidationJSON(w, req.FieldErrors, warnings...)
 		return
 	}
 
-	product, err := app.products.Get(item.ProductID)
+	err = app.estimateItems.Insert(item)
+	if err != nil {
+		app.itemChangeError(w, r, err)
+		return
+	}
+
+	// Stock problems do not block the item; the surveyor is warned once the page reloads.
+	warnings, err := app.stockWarnings([]models.EstimateProduct{{Product: product, EstimateItem: *item, Components: components}})
 	if err != nil {
 		app.serverError(w, r, err)
+		return
 	}
+	app.flashStockWarnings(r, warnings)
 
-	req.CheckField(validator.GreaterThanN(item.Quantity, 0), "quantity", "The quantity must be at least 1")
+	findings, err := app.itemChangeWarnings(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	req.CheckField(
-		(product.Width <= estimate.DoorWidthInch+1 && product.Height <= estimate.DoorHeightInch+1) ||
-			(product.Length <= estimate.DoorWidthInch+1 && product.Height <= estimate.DoorHeightInch+1) ||
-			(product.Width <= estimate.DoorHeightInch+1 && product.Length <= estimate.DoorWidthInch+1),
-		"product",
-		"Product must have at least an one inch clearance of doorway width and height to fit through the doorway.",
-	)
+	app.warningsJSON(w, findings)
+}
+
+// discontinuedComponent explains why a bundle cannot be added when one of its components has been discontinued,
+// or returns an empty string.
+func discontinuedComponent(components []models.BundleComponent) string {
+	for _, c := range components {
+		if c.Product.Discontinued {
+			return fmt.Sprintf("This bundle includes %s, which has been discontinued.", c.Product.Name)
+		}
+	}
+	return ""
+}
+
+// estimateAddCustomItem adds an ad-hoc line item that is not in the product catalog (ex. drywall repair).
+// Custom items are taxable unless the request says otherwise.
+func (app *application) estimateAddCustomItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.errorJSON(w, http.StatusNotFound, "estimate not found")
+		return
+	}
+
+	var req customItemRequestBody
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, "The request body is not valid JSON.")
+		return
+	}
+
+	if !app.authorizeItemChange(w, r, id) {
+		return
+	}
+
+	req.CheckField(validator.NotBlank(req.Description), "description", "This field cannot be blank.")
+	req.CheckField(validator.MaxChars(req.Description, 255), "description", "This field cannot be more than 255 characters long.")
+	req.CheckField(validator.GreaterThanN(req.UnitPrice, -1), "unit_price", "The unit price cannot be negative.")
+	req.CheckField(validator.GreaterThanN(req.Quantity, 0), "quantity", "The quantity must be at least 1")
+	req.CheckField(validator.PermittedValue(req.Category, models.ProductCategories...), "category", "Please choose a valid category.")
 
 	if !req.Valid() {
-		app.failedValidationJSON(w, req.FieldErrors)
+		warnings, err := app.itemChangeWarnings(id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.failedValidationJSON(w, req.FieldErrors, warnings...)
 		return
 	}
 
+	item := &models.EstimateItem{
+		EstimateID:        id,
+		Quantity:          req.Quantity,
+		IsOptional:        req.IsOptional,
+		CustomDescription: strings.TrimSpace(req.Description),
+		CustomUnitPrice:   req.UnitPrice,
+		CustomCategory:    req.Category,
+		Taxable:           req.Taxable == nil || *req.Taxable,
+	}
+
 	err = app.estimateItems.Insert(item)
+	if err != nil {
+		app.itemChangeError(w, r, err)
+		return
+	}
+
+	warnings, err := app.itemChangeWarnings(id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
 
+	app.warningsJSON(w, warnings)
+}
+
+// productModalData is passed to the add line item modal.
+type productModalData struct {
+	Query       string
+	Category    string
+	Subcategory string
+	Color       string
+	Families    []models.ProductFamily
 }
 
+// fetchProductsByFilters renders the add line item modal. The q parameter is a free text search that may include
+// dimension limits, ex. "quiet dishwasher stainless max width 24in".
 func (app *application) fetchProductsByFilters(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
 	category := queryParams.Get("category")
 	subcategory := queryParams.Get("subcategory")
 	color := queryParams.Get("color")
+	query := strings.TrimSpace(queryParams.Get("q"))
 
-	products, err := app.products.GetByProductFilter(category, subcategory, color)
+	search := models.ParseProductSearch(query)
+	search.Category = category
+	search.Subcategory = subcategory
+	search.Color = color
+
+	families, err := app.products.SearchFamilies(search)
 	if err != nil {
 		app.serverError(w, r, err)
+		return
 	}
 	var buf bytes.Buffer
 
@@ -507,9 +852,22 @@ func (app *application) fetchProductsByFilters(w http.ResponseWriter, r *http.Re
 	if !ok {
 		app.logger.Error("the template addLineItemModal.tmpl does not exist")
 		http.Error(w, `{"status": "error", "message": "template not found"}`, http.StatusInternalServerError)
+		return
 	}
 
-	ts.ExecuteTemplate(&buf, "addLineItemModal", products)
+	data := productModalData{
+		Query:       query,
+		Category:    category,
+		Subcategory: subcategory,
+		Color:       color,
+		Families:    families,
+	}
+
+	err = ts.ExecuteTemplate(&buf, "addLineItemModal", data)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	w.Header().Add("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
@@ -519,66 +877,277 @@ func (app *application) fetchProductsByFilters(w http.ResponseWriter, r *http.Re
 
 func (app *application) estimateUpdateItem(w http.ResponseWriter, r *http.Request) {
 	lineItemID, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil {
-		http.Error(w, "invalid line item id", http.StatusBadRequest)
+	if err != nil || lineItemID < 1 {
+		app.errorJSON(w, http.StatusNotFound, "line item not found")
 		return
 	}
+
 	var req productRequestBody
-	json.NewDecoder(r.Body).Decode(&req)
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, "The request body is not valid JSON.")
+		return
+	}
 
 	estimateItem := models.EstimateItem{
 		LineItemID: lineItemID,
 		Quantity:   req.Quantity,
 	}
 
-	estimateID, err := app.estimateItems.GetEstimateIDByLineItemID(lineItemID)
-	if err != nil {
-		app.serverError(w, r, err)
-		return
-	}
-
-	estimate, err := app.estimates.Get(estimateID)
-	if err != nil {
-		app.serverError(w, r, err)
+	estimateID, ok := app.lineItemEstimateID(w, r, lineItemID)
+	if !ok {
 		return
 	}
 
-	currUser := app.currentUser(r)
-
-	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
-		app.clientError(w, r, http.StatusNotFound)
+	if !app.authorizeItemChange(w, r, estimateID) {
 		return
 	}
 
 	req.CheckField(validator.GreaterThanN(estimateItem.Quantity, 0), "quantity", "The quantity must be at least 1")
 
 	if !req.Valid() {
-		app.failedValidationJSON(w, req.FieldErrors)
+		warnings, err := app.itemChangeWarnings(estimateID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.failedValidationJSON(w, req.FieldErrors, warnings...)
 		return
 	}
 
 	err = app.estimateItems.Update(estimateItem)
+	if err != nil {
+		app.itemChangeError(w, r, err)
+		return
+	}
+
+	if req.IsOptional != nil {
+		err = app.estimateItems.SetOptional(lineItemID, *req.IsOptional)
+		if err != nil {
+			app.itemChangeError(w, r, err)
+			return
+		}
+	}
+
+	warnings, err := app.itemChangeWarnings(estimateID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	app.warningsJSON(w, warnings)
 }
 
 func (app *application) estimateDeleteItem(w http.ResponseWriter, r *http.Request) {
 	lineItemID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil || lineItemID < 1 {
-		http.Error(w, "invalid line item id", http.StatusBadRequest)
+		app.errorJSON(w, http.StatusNotFound, "line item not found")
+		return
+	}
+
+	estimateID, ok := app.lineItemEstimateID(w, r, lineItemID)
+	if !ok {
+		return
+	}
+
+	if !app.authorizeItemChange(w, r, estimateID) {
 		return
 	}
 
 	err = app.estimateItems.Delete(lineItemID)
+	if err != nil {
+		app.itemChangeError(w, r, err)
+		return
+	}
+
+	warnings, err := app.itemChangeWarnings(estimateID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	app.warningsJSON(w, warnings)
+}
+
+// estimateBatchItems validates and applies a list of add/update/delete line item operations in one transaction.
+// Every operation is validated before anything is written; if any operation is invalid nothing is applied and the
+// response lists the errors per operation. On success each result carries the affected line item ID.
+func (app *application) estimateBatchItems(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.errorJSON(w, http.StatusNotFound, "estimate not found")
+		return
+	}
+
+	var req itemBatchRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorJSON(w, http.StatusBadRequest, "the request body must be a JSON list of operations")
+		return
+	}
+
+	if len(req.Operations) == 0 || len(req.Operations) > maxItemBatchSize {
+		app.errorJSON(w, http.StatusBadRequest, fmt.Sprintf("a batch must contain between 1 and %d operations", maxItemBatchSize))
+		return
+	}
+
+	if !app.authorizeItemChange(w, r, id) {
+		return
+	}
+
+	estimate, err := app.estimates.Get(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	path, err := app.deliveryPath(estimate)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	estimateProducts, err := app.estimateItems.GetByEstimateID(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	existing := make(map[int]models.EstimateProduct, len(estimateProducts))
+	for _, ep := range estimateProducts {
+		existing[ep.EstimateItem.LineItemID] = ep
+	}
+
+	// changed are the line items the batch adds or updates, checked for stock once it is applied.
+	var changed []models.EstimateProduct
+
+	ops := make([]models.ItemOperation, len(req.Operations))
+	results := make([]itemOperationResult, len(req.Operations))
+	touched := map[int]bool{}
+	valid := true
+
+	for i := range req.Operations {
+		opReq := &req.Operations[i]
+		op := models.ItemOperation{Kind: models.ItemOperationKind(opReq.Op)}
+
+		switch op.Kind {
+		case models.ItemOperationAdd:
+			opReq.CheckField(validator.GreaterThanN(opReq.Quantity, 0), "quantity", "The quantity must be at least 1")
+
+			var components []models.BundleComponent
+			product, err := app.products.Get(opReq.ProductID)
+			if err != nil {
+				if !errors.Is(err, models.ErrNoRecord) {
+					app.serverError(w, r, err)
+					return
+				}
+				opReq.AddFieldError("product", "The product does not exist")
+			} else if product.Discontinued {
+				opReq.AddFieldError("product", "This product has been discontinued.")
+			} else {
+				bundles, err := app.products.GetBundleComponents([]int{product.ProductID})
+				if err != nil {
+					app.serverError(w, r, err)
+					return
+				}
+				components = bundles[product.ProductID]
+
+				message := discontinuedComponent(components)
+				if message == "" {
+					message = deliveryProblem(product, components, path)
+				}
+				opReq.CheckField(message == "", "product", message)
+			}
+
+			if opReq.OptionID > 0 {
+				optionEstimateID, err := app.estimateOptionGroups.GetOptionEstimateID(opReq.OptionID)
+				if err != nil && !errors.Is(err, models.ErrNoRecord) {
+					app.serverError(w, r, err)
+					return
+				}
+				opReq.CheckField(err == nil && optionEstimateID == id, "option", "The selected option does not belong to this estimate.")
+				op.Item.OptionID = sql.NullInt64{Int64: int64(opReq.OptionID), Valid: true}
+			}
+
+			op.Item.ProductID = opReq.ProductID
+			op.Item.Quantity = opReq.Quantity
+			op.Item.IsOptional = opReq.IsOptional != nil && *opReq.IsOptional
+			changed = append(changed, models.EstimateProduct{Product: product, EstimateItem: op.Item, Components: components})
+
+		case models.ItemOperationUpdate, models.ItemOperationDelete:
+			current, ok := existing[opReq.LineItemID]
+			opReq.CheckField(ok, "line_item_id", "The line item does not belong to this estimate")
+			opReq.CheckField(!touched[opReq.LineItemID], "line_item_id", "A line item can only be changed once per batch")
+			touched[opReq.LineItemID] = true
+
+			if op.Kind == models.ItemOperationUpdate {
+				opReq.CheckField(validator.GreaterThanN(opReq.Quantity, 0), "quantity", "The quantity must be at least 1")
+
+				current.EstimateItem.Quantity = opReq.Quantity
+				changed = append(changed, current)
+			}
+
+			// An update only changes whether the line item is an add-on when the request says so.
+			op.Item.LineItemID = opReq.LineItemID
+			op.Item.Quantity = opReq.Quantity
+			op.Item.IsOptional = current.EstimateItem.IsOptional
+			if opReq.IsOptional != nil {
+				op.Item.IsOptional = *opReq.IsOptional
+			}
+
+		default:
+			opReq.AddFieldError("op", `The operation must be "add", "update" or "delete"`)
+		}
+
+		ops[i] = op
+		results[i] = itemOperationResult{Index: i, Op: opReq.Op, OK: opReq.Valid(), Errors: opReq.FieldErrors}
+		if !opReq.Valid() {
+			valid = false
+		}
+	}
+
+	if !valid {
+		warnings, _, err := app.compatibility(id, estimateProducts)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"results": results, "warnings": warnings})
+		return
+	}
+
+	lineItemIDs, err := app.estimateItems.ApplyBatch(id, ops)
+	if err != nil {
+		if errors.Is(err, models.ErrEstimateLocked) || errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusConflict, "the estimate changed while the batch was being applied, please reload")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	for i := range results {
+		results[i].LineItemID = lineItemIDs[i]
+	}
+
+	// Stock problems do not block the batch; the surveyor is warned once the page reloads.
+	stock, err := app.stockWarnings(changed)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	app.flashStockWarnings(r, stock)
+
+	warnings, err := app.itemChangeWarnings(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"results": results, "warnings": warnings})
 }
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ledgerLimit is the number of adjustments shown on the ledger page.
+const ledgerLimit = 200
+
+// stockWarning is a stock problem with a single line item shown on the edit page.
+type stockWarning struct {
+	Item    models.EstimateProduct
+	Message string
+}
+
+// inventoryAdjustForm adds or removes stock of a product at a location.
+type inventoryAdjustForm struct {
+	ProductID           int    `form:"productID"`
+	LocationID          int    `form:"locationID"`
+	Delta               int    `form:"delta"`
+	Reason              string `form:"reason"`
+	validator.Validator `form:"-"`
+}
+
+// validate checks every field of the adjustment form.
+func (f *inventoryAdjustForm) validate(products []models.Product, locations []models.InventoryLocation) {
+	productIDs := make([]int, len(products))
+	for i, p := range products {
+		productIDs[i] = p.ProductID
+	}
+	locationIDs := make([]int, len(locations))
+	for i, l := range locations {
+		locationIDs[i] = l.LocationID
+	}
+
+	f.CheckField(validator.PermittedValue(f.ProductID, productIDs...), "productID", "Please choose a product.")
+	f.CheckField(validator.PermittedValue(f.LocationID, locationIDs...), "locationID", "Please choose a location.")
+	f.CheckField(f.Delta != 0, "delta", "The adjustment cannot be zero.")
+	f.CheckField(validator.NotBlank(f.Reason), "reason", "This field cannot be blank.")
+	f.CheckField(validator.MaxChars(f.Reason, 255), "reason", "This field cannot be more than 255 characters long.")
+}
+
+// stockWarnings lists the catalog line items of an estimate that are out of stock or would leave their product low.
+// Bundles warn for each component that is short. Untracked products never warn.
+func (app *application) stockWarnings(estimateProducts []models.EstimateProduct) ([]stockWarning, error) {
+	var productIDs []int
+	for _, ep := range estimateProducts {
+		if ep.EstimateItem.ProductID != 0 {
+			productIDs = append(productIDs, ep.EstimateItem.ProductID)
+		}
+		for _, c := range ep.Components {
+			productIDs = append(productIDs, c.Product.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return nil, nil
+	}
+
+	stock, err := app.inventory.GetProductStock(productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []stockWarning
+	for _, ep := range estimateProducts {
+		for _, c := range ep.Components {
+			s, ok := stock[c.Product.ProductID]
+			if !ok {
+				continue
+			}
+
+			if message := s.Warning(c.Total(ep.EstimateItem.Quantity)); message != "" {
+				warnings = append(warnings, stockWarning{Item: ep, Message: fmt.Sprintf("%s: %s", c.Product.Name, message)})
+			}
+		}
+
+		s, ok := stock[ep.EstimateItem.ProductID]
+		if !ok {
+			continue
+		}
+
+		if message := s.Warning(ep.EstimateItem.Quantity); message != "" {
+			warnings = append(warnings, stockWarning{Item: ep, Message: message})
+		}
+	}
+
+	return warnings, nil
+}
+
+// flashStockWarnings warns the surveyor about every stock problem in one flash message, shown once the page reloads.
+func (app *application) flashStockWarnings(r *http.Request, warnings []stockWarning) {
+	if len(warnings) == 0 {
+		return
+	}
+
+	messages := make([]string, len(warnings))
+	for i, warning := range warnings {
+		messages[i] = fmt.Sprintf("%s: %s", warning.Item.Product.Name, warning.Message)
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "warning",
+		Message: strings.Join(messages, " "),
+	})
+}
+
+// renderInventory shows the stock page with the adjustment form.
+func (app *application) renderInventory(w http.ResponseWriter, r *http.Request, status int, form inventoryAdjustForm) {
+	levels, err := app.inventory.GetStockLevels()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	locations, err := app.inventory.GetLocations()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	reservations, err := app.inventory.GetOpenReservations()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	products, err := app.products.List("")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.StockLevels = levels
+	data.InventoryLocations = locations
+	data.Reservations = reservations
+	data.ProductList = products
+	data.Form = form
+
+	app.render(w, r, status, "inventory.tmpl", data)
+}
+
+func (app *application) inventoryView(w http.ResponseWriter, r *http.Request) {
+	app.renderInventory(w, r, http.StatusOK, inventoryAdjustForm{})
+}
+
+// inventoryAdjust records stock received, counted or written off at a location.
+func (app *application) inventoryAdjust(w http.ResponseWriter, r *http.Request) {
+	var form inventoryAdjustForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	products, err := app.products.List("")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	locations, err := app.inventory.GetLocations()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate(products, locations)
+	if !form.Valid() {
+		app.renderInventory(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	err = app.inventory.Adjust(&models.InventoryAdjustment{
+		ProductID:  form.ProductID,
+		LocationID: form.LocationID,
+		Delta:      form.Delta,
+		Reason:     strings.TrimSpace(form.Reason),
+		AdjustedBy: app.currentUser(r).UserID,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, r, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Stock adjusted.",
+	})
+
+	http.Redirect(w, r, "/inventory", http.StatusSeeOther)
+}
+
+// inventoryReorderPoint sets the stock level at which a product is reported low at a location.
+func (app *application) inventoryReorderPoint(w http.ResponseWriter, r *http.Request) {
+	var form struct {
+		ProductID    int `form:"productID"`
+		LocationID   int `form:"locationID"`
+		ReorderPoint int `form:"reorderPoint"`
+	}
+	err := app.decodePostForm(r, &form)
+	if err != nil || form.ReorderPoint < 0 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = app.inventory.SetReorderPoint(form.ProductID, form.LocationID, form.ReorderPoint)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, r, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Reorder point saved.",
+	})
+
+	http.Redirect(w, r, "/inventory", http.StatusSeeOther)
+}
+
+// inventoryLocationCreate adds a place stock is kept.
+func (app *application) inventoryLocationCreate(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+
+	flash := FlashMessage{Type: "success", Message: fmt.Sprintf("Location %q added.", name)}
+	switch {
+	case !validator.NotBlank(name):
+		flash = FlashMessage{Type: "error", Message: "The location name cannot be blank."}
+	case !validator.MaxChars(name, 100):
+		flash = FlashMessage{Type: "error", Message: "The location name cannot be more than 100 characters long."}
+	default:
+		err = app.inventory.InsertLocation(&models.InventoryLocation{Name: name})
+		if errors.Is(err, models.ErrDuplicateLocation) {
+			flash = FlashMessage{Type: "error", Message: fmt.Sprintf("A location named %q already exists.", name)}
+		} else if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, "/inventory", http.StatusSeeOther)
+}
+
+// inventoryLedgerView lists the most recent changes to stock on hand.
+func (app *application) inventoryLedgerView(w http.ResponseWriter, r *http.Request) {
+	ledger, err := app.inventory.GetLedger(ledgerLimit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.InventoryLedger = ledger
+
+	app.render(w, r, http.StatusOK, "inventoryLedger.tmpl", data)
+}
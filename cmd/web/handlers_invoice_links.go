@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"ezkitchen/internal/mailer"
+	"ezkitchen/internal/models"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// invoiceLinkConfig is how long signing links can be used and when customers are reminded of links they have not
+// signed with yet.
+type invoiceLinkConfig struct {
+	lifetime time.Duration
+	// remindAfter is how long after a link is sent to remind the customer, and remindBefore how long before it
+	// expires.
+	remindAfter  []time.Duration
+	remindBefore []time.Duration
+}
+
+// invoiceLinkConfigFromEnv reads the signing link settings, falling back to links that last 72 hours with reminders
+// 24 hours after sending and 6 hours before expiry:
+//
+//	INVOICE_LINK_TTL=72h
+//	INVOICE_REMINDERS_AFTER=24h
+//	INVOICE_REMINDERS_BEFORE=6h
+//
+// Reminders take a comma separated list of durations, or nothing to send none. Sending reminders requires
+// INVOICE_TOKEN_KEY.
+func invoiceLinkConfigFromEnv() (invoiceLinkConfig, error) {
+	cfg := invoiceLinkConfig{lifetime: 72 * time.Hour}
+
+	if v, ok := os.LookupEnv("INVOICE_LINK_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid INVOICE_LINK_TTL %q", v)
+		}
+		cfg.lifetime = d
+	}
+
+	var err error
+	cfg.remindAfter, err = durationsFromEnv("INVOICE_REMINDERS_AFTER", "24h")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.remindBefore, err = durationsFromEnv("INVOICE_REMINDERS_BEFORE", "6h")
+	if err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// durationsFromEnv parses a comma separated list of positive durations from an environment variable, using def
+// when it is not set.
+func durationsFromEnv(name, def string) ([]time.Duration, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		v = def
+	}
+
+	var durations []time.Duration
+	for _, field := range strings.Split(v, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", name, v)
+		}
+		durations = append(durations, d)
+	}
+
+	return durations, nil
+}
+
+// invoiceTokenKeyFromEnv reads the base64 encoded 32 byte key in INVOICE_TOKEN_KEY that seals signing links for
+// reminders. It returns nil when the key is not set, which is only allowed when no reminders are scheduled.
+func invoiceTokenKeyFromEnv() ([]byte, error) {
+	v := os.Getenv("INVOICE_TOKEN_KEY")
+	if v == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("INVOICE_TOKEN_KEY must be 32 bytes, base64 encoded")
+	}
+
+	return key, nil
+}
+
+// reminders reports whether any reminders are scheduled.
+func (c invoiceLinkConfig) reminders() bool {
+	return len(c.remindAfter) > 0 || len(c.remindBefore) > 0
+}
+
+// reminderTimes returns when the customer should be reminded of a link, in order. Reminders that would fall
+// outside the time the link can be used are dropped.
+func (c invoiceLinkConfig) reminderTimes(it models.InvoiceToken) []time.Time {
+	var times []time.Time
+	for _, d := range c.remindAfter {
+		times = append(times, it.CreatedAt.Add(d))
+	}
+	for _, d := range c.remindBefore {
+		times = append(times, it.ExpiresAt.Add(-d))
+	}
+
+	times = slices.DeleteFunc(times, func(t time.Time) bool {
+		return !t.After(it.CreatedAt) || !t.Before(it.ExpiresAt)
+	})
+	slices.SortFunc(times, time.Time.Compare)
+
+	return times
+}
+
+// reminderDue reports whether a reminder for a link has come due since the customer was last reminded, or since
+// the link was sent. Reminders missed while the server was down are sent as one.
+func (c invoiceLinkConfig) reminderDue(it models.InvoiceToken, now time.Time) bool {
+	last := it.CreatedAt
+	if it.RemindedAt.Valid {
+		last = it.RemindedAt.Time
+	}
+
+	for _, t := range c.reminderTimes(it) {
+		if t.After(last) && !t.After(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signingLink is a signing link issued for an estimate along with its status, listed for admins on the estimate.
+type signingLink struct {
+	models.InvoiceToken
+	Status models.TokenStatus
+}
+
+// signingLinks lists the signing links issued for an estimate, newest first.
+func (app *application) signingLinks(estimateID int) ([]signingLink, error) {
+	tokens, err := app.invoiceToken.GetByEstimateID(estimateID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	links := make([]signingLink, len(tokens))
+	for i, it := range tokens {
+		links[i] = signingLink{InvoiceToken: it, Status: it.Status(now)}
+	}
+
+	return links, nil
+}
+
+// sendInvoiceLink issues a new signing link for an estimate and emails it to the customer. Any earlier link that
+// was still active stops working.
+func (app *application) sendInvoiceLink(estimate models.Estimate, userID int) error {
+	expiresAt := time.Now().Add(app.invoiceLinks.lifetime)
+	rawToken, err := app.invoiceToken.Insert(estimate.EstimateID, expiresAt, userID)
+	if err != nil {
+		return err
+	}
+
+	customer, err := app.users.Get(estimate.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	err = app.mailer.SendInvoiceLink(customer.Email, invoiceLinkData(customer, estimate.EstimateID, rawToken, expiresAt))
+	if err != nil {
+		app.logger.Error("email send failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// sendSigningReminder emails the customer the signing link again. Nothing is sent if the estimate is no longer
+// awaiting the customer's agreement or the link was used or revoked since it was listed. The reminder is recorded
+// before sending and taken back if the email fails.
+func (app *application) sendSigningReminder(rt models.ReminderToken) error {
+	estimate, err := app.estimates.Get(rt.EstimateID)
+	if err != nil {
+		return err
+	}
+	if estimate.Status != models.StatusAwaitingAgreement {
+		return nil
+	}
+
+	customer, err := app.users.Get(estimate.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	err = app.invoiceToken.MarkReminded(rt.InvoiceTokenID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+
+	err = app.mailer.SendInvoiceReminder(customer.Email, invoiceLinkData(customer, estimate.EstimateID, rt.RawToken, rt.ExpiresAt))
+	if err != nil {
+		// Leave the reminder due so the next run tries again.
+		if undoErr := app.invoiceToken.UnmarkReminded(rt.InvoiceTokenID, rt.RemindedAt); undoErr != nil {
+			return errors.Join(err, undoErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// invoiceLinkData fills in the email sending a customer their signing link.
+func invoiceLinkData(customer models.User, estimateID int, rawToken string, expiresAt time.Time) mailer.InvoiceLinkData {
+	return mailer.InvoiceLinkData{
+		CustomerName:   customer.Name,
+		EstimateNumber: estimateID,
+		SignURL:        os.Getenv("APP_BASE_URL") + "/invoice/sign?token=" + rawToken,
+		ExpiresAt:      expiresAt.Format("Jan 2, 2006 3:04 PM"),
+	}
+}
+
+// invoiceLinkIssue sends the customer a new signing link, revoking the links sent before it. Only estimates that
+// are awaiting the customer's agreement can be signed.
+func (app *application) invoiceLinkIssue(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimate, err := app.estimates.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/estimate/view/%d", id)
+
+	if estimate.Status != models.StatusAwaitingAgreement {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Signing links can only be sent while the estimate is awaiting the customer's agreement.",
+		})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	err = app.sendInvoiceLink(estimate, app.currentUser(r).UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "A new signing link was emailed to the customer. Earlier links no longer work.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// invoiceLinkRevoke stops a signing link from being used.
+func (app *application) invoiceLinkRevoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimateID, err := app.invoiceToken.Revoke(id, app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Signing link revoked.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/view/%d", estimateID), http.StatusSeeOther)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/mailer"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// invoiceRespond lets a customer decline the estimate or ask for changes from the signing page instead of signing.
+// Either uses up the signing link, moves the estimate on and emails whoever created the estimate.
+func (app *application) invoiceRespond(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	rawToken := r.PostForm.Get("token")
+	data := app.newTemplateData(r)
+
+	it, err := app.invoiceToken.GetByRawToken(rawToken)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if !it.Active(time.Now()) {
+		app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
+		return
+	}
+
+	estimate, err := app.estimates.Get(it.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if estimate.Status != models.StatusAwaitingAgreement || estimate.SignatureObjectKey.Valid {
+		app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
+		return
+	}
+
+	kind := models.ResponseKind(r.PostForm.Get("response"))
+	if !validator.PermittedValue(kind, models.ResponseKinds...) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	rejectResponse := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, "/invoice/sign?token="+url.QueryEscape(rawToken), http.StatusSeeOther)
+	}
+
+	reason := strings.TrimSpace(r.PostForm.Get("reason"))
+	if !validator.NotBlank(reason) {
+		rejectResponse("Please tell us why you are declining or what you would like changed.")
+		return
+	}
+	if !validator.MaxChars(reason, 2000) {
+		rejectResponse("Please keep your comments under 2000 characters.")
+		return
+	}
+
+	tx, err := app.estimates.DB.Begin()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Marking the link used first stops the customer from both signing and responding with the same link.
+	err = app.invoiceToken.MarkUsedTx(tx, it.InvoiceTokenID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	response := models.EstimateResponse{
+		EstimateID:     estimate.EstimateID,
+		InvoiceTokenID: it.InvoiceTokenID,
+		Kind:           kind,
+		Reason:         reason,
+	}
+	err = app.estimateResponses.InsertTx(tx, &response)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.estimates.UpdateStatusTx(tx, estimate.EstimateID, kind.Status())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Back in Draft the estimate is priced from the catalog again until it is resubmitted.
+	if kind.Status() == models.StatusDraft {
+		err = app.estimateItems.ClearPriceSnapshotTx(tx, estimate.EstimateID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.estimateItems.ClearBundleSnapshotTx(tx, estimate.EstimateID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// The response is already recorded, so a failed email is only logged; it is shown on the estimate either way.
+	err = app.notifyEstimateOwner(estimate, response)
+	if err != nil {
+		app.logger.Error("email send failed", "error", err, "estimate", estimate.EstimateID)
+	}
+
+	estimate.Status = kind.Status()
+	data.Estimate = estimate
+	app.render(w, r, http.StatusOK, "invoiceResponseSuccess.tmpl", data)
+}
+
+// notifyEstimateOwner emails whoever created an estimate the customer's response to it.
+func (app *application) notifyEstimateOwner(estimate models.Estimate, response models.EstimateResponse) error {
+	owner, err := app.users.Get(estimate.CreatedBy)
+	if err != nil {
+		return err
+	}
+
+	customer, err := app.users.Get(estimate.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	return app.mailer.SendEstimateResponse(owner.Email, mailer.EstimateResponseData{
+		OwnerName:      owner.Name,
+		CustomerName:   customer.Name,
+		EstimateNumber: estimate.EstimateID,
+		Response:       response.Kind.Label(),
+		Reason:         response.Reason,
+		EstimateURL:    os.Getenv("APP_BASE_URL") + fmt.Sprintf("/estimate/view/%d", estimate.EstimateID),
+	})
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"ezkitchen/internal/models"
 	"fmt"
+	"image/png"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 )
@@ -25,11 +30,17 @@ func (app *application) signInvoiceView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if time.Now().After(it.ExpiresAt) || it.UsedAt.Valid {
+	if !it.Active(time.Now()) {
 		app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
 		return
 	}
 
+	err = app.invoiceToken.MarkOpened(it.InvoiceTokenID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	estimate, err := app.estimates.Get(it.EstimateID)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -41,7 +52,17 @@ func (app *application) signInvoiceView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	estimateTotals := app.estimates.CalculateEstimateTotals(estimateProducts)
+	baseProducts, optionGroups, err := app.estimateOptions(estimate.EstimateID, estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	requiredProducts, addonProducts := models.SplitOptionalProducts(baseProducts)
+
+	// The page opens with no option chosen and no add-ons accepted; the customer must choose an option in every
+	// group before signing.
+	estimateTotals := app.invoiceTotals(requiredProducts, addonProducts, optionGroups, nil, nil)
 
 	customer, err := app.users.Get(estimate.CustomerID)
 	if err != nil {
@@ -51,9 +72,12 @@ func (app *application) signInvoiceView(w http.ResponseWriter, r *http.Request)
 
 	data.Estimate = estimate
 	data.Customer = customer
-	data.Products = estimateProducts
+	data.Products = requiredProducts
+	data.Addons = addonProducts
+	data.OptionGroups = optionGroups
 	data.EstimateTotals = estimateTotals
 	data.Token = rawToken
+	data.Consent = esignConsent
 
 	fmt.Printf("ESTIMATE OBJECT: %+v\n", estimate)
 
@@ -73,7 +97,7 @@ func (app *application) submitSignature(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if time.Now().After(it.ExpiresAt) || it.UsedAt.Valid {
+	if !it.Active(time.Now()) {
 		data := app.newTemplateData(r)
 		app.render(w, r, http.StatusGone, "invalidInvoice.tmpl", data)
 		return
@@ -96,6 +120,26 @@ func (app *application) submitSignature(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The customer must have agreed to the consent statement on the page, and it must be the one in force now.
+	if r.PostFormValue("consent") == "" {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if r.PostFormValue("consent_version") != esignConsent.Version {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "The signing terms were updated while this page was open. Please review them and sign again.",
+		})
+		http.Redirect(w, r, "/invoice/sign?token="+url.QueryEscape(rawToken), http.StatusSeeOther)
+		return
+	}
+
+	customer, err := app.users.Get(estimate.CustomerID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	file, header, err := r.FormFile("signature")
 	if err != nil {
 		app.serverError(w, r, err)
@@ -113,12 +157,54 @@ func (app *application) submitSignature(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.storage.UploadSignature(ctx, estimate.EstimateID, file, "image/png")
+	signaturePNG, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(signaturePNG)); err != nil {
+		app.clientError(w, r, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	optionGroups, err := app.estimateOptionGroups.GetGroupsByEstimateID(estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	selections, ok := chooseOptions(optionGroups, func(groupID int) (int, bool) {
+		optionID, err := strconv.Atoi(r.PostFormValue(fmt.Sprintf("option_%d", groupID)))
+		return optionID, err == nil
+	})
+	if !ok || !allChosen(optionGroups, selections) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	estimateProducts, err := app.estimateItems.GetByEstimateID(estimate.EstimateID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	var addonIDs []int
+	for _, val := range r.PostForm["addon"] {
+		lineItemID, err := strconv.Atoi(val)
+		if err != nil {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+		addonIDs = append(addonIDs, lineItemID)
+	}
+
+	requiredProducts, addonProducts := models.SplitOptionalProducts(models.SplitOptionProducts(optionGroups, estimateProducts))
+	accepted, ok := acceptedAddons(addonProducts, addonIDs)
+	if !ok {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
 	tx, err := app.estimates.DB.Begin()
 	if err != nil {
 		app.serverError(w, r, err)
@@ -126,12 +212,42 @@ func (app *application) submitSignature(w http.ResponseWriter, r *http.Request)
 	}
 	defer tx.Rollback()
 
+	err = app.estimateOptionGroups.SelectOptionsTx(tx, estimate.EstimateID, it.InvoiceTokenID, selections)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.estimateItems.AcceptAddonsTx(tx, estimate.EstimateID, accepted)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	err = app.estimates.SetSignatureKeyTx(tx, estimate.EstimateID, fmt.Sprintf("signatures/%d.png", estimate.EstimateID))
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	audit := models.SignatureAudit{
+		EstimateID:     estimate.EstimateID,
+		InvoiceTokenID: it.InvoiceTokenID,
+		SignerName:     customer.Name,
+		SignerEmail:    customer.Email,
+		IPAddress:      clientIP(r),
+		UserAgent:      r.UserAgent(),
+		LinkSentAt:     it.CreatedAt,
+		LinkOpenedAt:   it.OpenedAt,
+		ConsentVersion: esignConsent.Version,
+		ConsentText:    esignConsent.Text,
+	}
+	err = app.signatureAudits.InsertTx(tx, &audit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	err = app.estimates.UpdateStatusTx(tx, estimate.EstimateID, models.StatusInProgress)
 	if err != nil {
 		app.serverError(w, r, err)
@@ -144,16 +260,210 @@ func (app *application) submitSignature(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	err = app.inventory.ReserveTx(tx, estimate.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Orders are placed on behalf of the estimate's owner; line items without a vendor are ordered by hand.
+	_, skipped, err := app.purchaseOrders.GenerateForEstimateTx(tx, estimate.EstimateID, estimate.CreatedBy)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if skipped > 0 {
+		app.logger.Warn("line items left off purchase orders because their product has no vendor",
+			"estimate", estimate.EstimateID, "skipped", skipped)
+	}
+
+	products := chosenProducts(requiredProducts, addonProducts, optionGroups, selections, accepted)
+	err = app.recordAgreement(ctx, tx, estimate, customer, products, audit, signaturePNG)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	data.Token = rawToken
 	app.render(w, r, http.StatusOK, "invoiceSignatureSuccess.tmpl", data)
 
 }
 
+// chooseOptions validates the customer's chosen option for every option group. chosen returns the OptionID picked
+// for a group and false when nothing was picked, in which case the group is left out. It returns false if a chosen
+// option is not part of its group.
+func chooseOptions(groups []models.OptionGroup, chosen func(groupID int) (int, bool)) (map[int]int, bool) {
+	selections := make(map[int]int, len(groups))
+
+	for _, group := range groups {
+		optionID, ok := chosen(group.GroupID)
+		if !ok {
+			continue
+		}
+
+		found := false
+		for _, option := range group.Options {
+			if option.OptionID == optionID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+
+		selections[group.GroupID] = optionID
+	}
+
+	return selections, true
+}
+
+// allChosen reports whether the customer chose an option in every group that has one. An estimate can only be signed
+// once they have, so nothing is chosen on their behalf.
+func allChosen(groups []models.OptionGroup, selections map[int]int) bool {
+	for _, group := range groups {
+		if _, ok := selections[group.GroupID]; !ok && len(group.Options) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptedAddons validates that every accepted line item is one of the estimate's optional add-ons.
+func acceptedAddons(addons []models.EstimateProduct, lineItemIDs []int) ([]int, bool) {
+	optional := make(map[int]bool, len(addons))
+	for _, addon := range addons {
+		optional[addon.EstimateItem.LineItemID] = true
+	}
+
+	accepted := []int{}
+	for _, id := range lineItemIDs {
+		if !optional[id] {
+			return nil, false
+		}
+		accepted = append(accepted, id)
+	}
+
+	return accepted, true
+}
+
+// invoiceTotals calculates the estimate totals for the customer's current choices: the required line items,
+// the chosen option of each group and any accepted add-ons.
+func (app *application) invoiceTotals(required, addons []models.EstimateProduct, groups []models.OptionGroup, selections map[int]int, accepted []int) models.EstimateTotals {
+	return app.estimates.CalculateEstimateTotals(chosenProducts(required, addons, groups, selections, accepted))
+}
+
+// chosenProducts returns the products a customer's choices add up to: the required line items, the chosen option
+// of each group and the accepted add-ons, which are no longer optional.
+func chosenProducts(required, addons []models.EstimateProduct, groups []models.OptionGroup, selections map[int]int, accepted []int) []models.EstimateProduct {
+	products := append([]models.EstimateProduct{}, required...)
+
+	for _, group := range groups {
+		for _, option := range group.Options {
+			if selections[group.GroupID] == option.OptionID {
+				products = append(products, option.Products...)
+			}
+		}
+	}
+
+	isAccepted := make(map[int]bool, len(accepted))
+	for _, id := range accepted {
+		isAccepted[id] = true
+	}
+	for _, addon := range addons {
+		if isAccepted[addon.EstimateItem.LineItemID] {
+			addon.EstimateItem.IsOptional = false
+			products = append(products, addon)
+		}
+	}
+
+	return products
+}
+
+type invoiceTotalsRequest struct {
+	Token   string         `json:"token"`
+	Options map[string]int `json:"options"`
+	Addons  []int          `json:"addons"`
+}
+
+type invoiceTotalsResponse struct {
+	Subtotal      int `json:"subtotal"`
+	LaborTotal    int `json:"labor_total"`
+	SalesTax      int `json:"sales_tax"`
+	EstimateTotal int `json:"estimate_total"`
+}
+
+// invoiceTotalsJSON recalculates the invoice totals while the customer toggles options and add-ons on the
+// signing page. Nothing is persisted; the signed selections are only recorded by submitSignature.
+func (app *application) invoiceTotalsJSON(w http.ResponseWriter, r *http.Request) {
+	var req invoiceTotalsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	it, err := app.invoiceToken.GetByRawToken(req.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientError(w, r, http.StatusGone)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if !it.Active(time.Now()) {
+		app.clientError(w, r, http.StatusGone)
+		return
+	}
+
+	estimateProducts, err := app.estimateItems.GetByEstimateID(it.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	baseProducts, optionGroups, err := app.estimateOptions(it.EstimateID, estimateProducts)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	requiredProducts, addonProducts := models.SplitOptionalProducts(baseProducts)
+
+	selections, ok := chooseOptions(optionGroups, func(groupID int) (int, bool) {
+		optionID, ok := req.Options[strconv.Itoa(groupID)]
+		return optionID, ok
+	})
+	if !ok {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	accepted, ok := acceptedAddons(addonProducts, req.Addons)
+	if !ok {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	totals := app.invoiceTotals(requiredProducts, addonProducts, optionGroups, selections, accepted)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invoiceTotalsResponse{
+		Subtotal:      totals.Subtotal,
+		LaborTotal:    totals.LaborTotal,
+		SalesTax:      totals.SalesTax,
+		EstimateTotal: totals.EstimateTotal,
+	})
+}
+
 func (app *application) getInvoiceSignature(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/clearance"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type openingForm struct {
+	Name                string  `form:"name"`
+	Kind                string  `form:"kind"`
+	WidthInch           float32 `form:"width_inch"`
+	HeightInch          float32 `form:"height_inch"`
+	TurnWidthInch       float32 `form:"turn_width_inch"`
+	validator.Validator `form:"-"`
+}
+
+// deliveryCheck is the clearance report for a single line item shown on the edit page. A bundle gets a check for
+// each of its components, named by Component.
+type deliveryCheck struct {
+	Item      models.EstimateProduct
+	Component string
+	Report    clearance.Report
+}
+
+// kitchenDoorToleranceInch is how much larger than the kitchen door measured on the estimate an item may be and
+// still be carried through it.
+const kitchenDoorToleranceInch = 1
+
+// deliveryPath returns the openings an item is carried through on its way to the kitchen: the recorded openings,
+// followed by the kitchen door measured when the estimate was created.
+func (app *application) deliveryPath(estimate models.Estimate) ([]clearance.Opening, error) {
+	openings, err := app.estimateOpenings.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pathFromOpenings(estimate, openings), nil
+}
+
+// pathFromOpenings converts the recorded openings of an estimate into a delivery path ending at the kitchen door.
+func pathFromOpenings(estimate models.Estimate, openings []models.EstimateOpening) []clearance.Opening {
+	path := make([]clearance.Opening, 0, len(openings)+1)
+	for _, o := range openings {
+		path = append(path, clearance.Opening{
+			Name:      o.Name,
+			Kind:      clearance.Kind(o.Kind),
+			Width:     float64(o.WidthInch),
+			Height:    float64(o.HeightInch),
+			TurnWidth: float64(o.TurnWidthInch),
+		})
+	}
+
+	return append(path, clearance.Opening{
+		Name:   "Kitchen door",
+		Kind:   clearance.KindDoor,
+		Width:  float64(estimate.DoorWidthInch + kitchenDoorToleranceInch),
+		Height: float64(estimate.DoorHeightInch + kitchenDoorToleranceInch),
+	})
+}
+
+// productBox returns the outer dimensions of a product for clearance checks.
+func productBox(product models.Product) clearance.Box {
+	return clearance.Box{
+		Length: float64(product.Length),
+		Width:  float64(product.Width),
+		Height: float64(product.Height),
+	}
+}
+
+// deliveryMessage turns a failed clearance report into a validation message.
+func deliveryMessage(report clearance.Report) string {
+	return fmt.Sprintf("This product cannot be delivered: blocked by %s. %s", report.BlockedBy, report.Reason)
+}
+
+// deliveryProblem checks a product against the delivery path, or each component when the product is a bundle. It
+// returns the validation message for the first one that does not fit, or "" when everything can be delivered.
+func deliveryProblem(product models.Product, components []models.BundleComponent, path []clearance.Opening) string {
+	if len(components) == 0 {
+		if report := clearance.Check(productBox(product), path); !report.Fits {
+			return deliveryMessage(report)
+		}
+		return ""
+	}
+
+	for _, c := range components {
+		if report := clearance.Check(productBox(c.Product), path); !report.Fits {
+			return fmt.Sprintf("The %s in this bundle cannot be delivered: blocked by %s. %s",
+				c.Product.Name, report.BlockedBy, report.Reason)
+		}
+	}
+	return ""
+}
+
+// deliveryReport checks every catalog line item against the delivery path. Bundles are checked component by
+// component since that is how they are carried in. Custom line items have no dimensions and are left out.
+func deliveryReport(path []clearance.Opening, estimateProducts []models.EstimateProduct) []deliveryCheck {
+	var checks []deliveryCheck
+	for _, ep := range estimateProducts {
+		if ep.EstimateItem.IsCustom() {
+			continue
+		}
+		if len(ep.Components) == 0 {
+			checks = append(checks, deliveryCheck{
+				Item:   ep,
+				Report: clearance.Check(productBox(ep.Product), path),
+			})
+			continue
+		}
+		for _, c := range ep.Components {
+			checks = append(checks, deliveryCheck{
+				Item:      ep,
+				Component: c.Product.Name,
+				Report:    clearance.Check(productBox(c.Product), path),
+			})
+		}
+	}
+
+	return checks
+}
+
+func (app *application) openingCreate(w http.ResponseWriter, r *http.Request) {
+	estimateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || estimateID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimate, ok := app.editableEstimate(w, r, estimateID)
+	if !ok {
+		return
+	}
+
+	var form openingForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank.")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long.")
+	form.CheckField(validator.PermittedValue(clearance.Kind(form.Kind), clearance.Kinds...), "kind", "Please choose a door, hallway or stairs.")
+	form.CheckField(validator.GreaterThanN(form.WidthInch, float32(0)), "width_inch", "The width must be greater than 0.")
+	form.CheckField(validator.GreaterThanN(form.HeightInch, float32(0)), "height_inch", "The height must be greater than 0.")
+	form.CheckField(!validator.LessThanN(form.TurnWidthInch, float32(0)), "turn_width_inch", "The turn width cannot be negative.")
+
+	redirectURL := fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID)
+
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Openings need a name, a kind and a width and height in inches.",
+		})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	opening := models.EstimateOpening{
+		EstimateID:    estimate.EstimateID,
+		Name:          form.Name,
+		Kind:          form.Kind,
+		WidthInch:     form.WidthInch,
+		HeightInch:    form.HeightInch,
+		TurnWidthInch: form.TurnWidthInch,
+	}
+
+	// Doors never turn, so a turn width entered for one is ignored.
+	if opening.Kind == string(clearance.KindDoor) {
+		opening.TurnWidthInch = 0
+	}
+
+	err = app.estimateOpenings.Insert(&opening)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+func (app *application) openingDelete(w http.ResponseWriter, r *http.Request) {
+	openingID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || openingID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimateID, err := app.estimateOpenings.GetOpeningEstimateID(openingID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	estimate, ok := app.editableEstimate(w, r, estimateID)
+	if !ok {
+		return
+	}
+
+	err = app.estimateOpenings.Delete(openingID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Opening removed from the delivery path.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+}
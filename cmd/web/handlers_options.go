@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type optionNameForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+// estimateOptions loads the option groups of an estimate and splits the estimate's line items into the base items
+// and the alternates of each option. Each option's totals are calculated as the required base items plus that
+// option; optional add-ons are left out until the customer accepts them.
+func (app *application) estimateOptions(estimateID int, estimateProducts []models.EstimateProduct) ([]models.EstimateProduct, []models.OptionGroup, error) {
+	groups, err := app.estimateOptionGroups.GetGroupsByEstimateID(estimateID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := models.SplitOptionProducts(groups, estimateProducts)
+	required, _ := models.SplitOptionalProducts(base)
+
+	for i := range groups {
+		for j := range groups[i].Options {
+			o := &groups[i].Options[j]
+
+			combined := make([]models.EstimateProduct, 0, len(required)+len(o.Products))
+			combined = append(combined, required...)
+			combined = append(combined, o.Products...)
+			o.Totals = app.estimates.CalculateEstimateTotals(combined)
+		}
+	}
+
+	return base, groups, nil
+}
+
+// editableEstimate fetches an estimate for modification and applies the line item policy: the current user must
+// own the estimate, have it shared with them, or be an admin, and it must still be a Draft. It writes the error
+// response itself and returns false when the request should stop.
+func (app *application) editableEstimate(w http.ResponseWriter, r *http.Request, estimateID int) (models.Estimate, bool) {
+	err := app.estimates.AuthorizeItemChange(estimateID, app.currentUser(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrForbidden):
+			app.clientError(w, r, http.StatusForbidden)
+		case errors.Is(err, models.ErrEstimateLocked):
+			app.clientError(w, r, http.StatusConflict)
+		default:
+			app.serverError(w, r, err)
+		}
+		return models.Estimate{}, false
+	}
+
+	estimate, err := app.estimates.Get(estimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return models.Estimate{}, false
+	}
+
+	return estimate, true
+}
+
+func (app *application) optionGroupCreate(w http.ResponseWriter, r *http.Request) {
+	estimateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || estimateID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimate, ok := app.editableEstimate(w, r, estimateID)
+	if !ok {
+		return
+	}
+
+	var form optionNameForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank.")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long.")
+
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Option groups need a name of at most 100 characters.",
+		})
+		http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+		return
+	}
+
+	group := models.OptionGroup{
+		EstimateID: estimate.EstimateID,
+		Name:       form.Name,
+	}
+
+	err = app.estimateOptionGroups.InsertGroup(&group)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+}
+
+func (app *application) optionCreate(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || groupID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimateID, err := app.estimateOptionGroups.GetGroupEstimateID(groupID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	estimate, ok := app.editableEstimate(w, r, estimateID)
+	if !ok {
+		return
+	}
+
+	var form optionNameForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank.")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long.")
+
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Options need a name of at most 100 characters.",
+		})
+		http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+		return
+	}
+
+	option := models.EstimateOption{
+		GroupID: groupID,
+		Name:    form.Name,
+	}
+
+	err = app.estimateOptionGroups.InsertOption(&option)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+}
+
+func (app *application) optionGroupDelete(w http.ResponseWriter, r *http.Request) {
+	groupID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || groupID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	estimateID, err := app.estimateOptionGroups.GetGroupEstimateID(groupID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	estimate, ok := app.editableEstimate(w, r, estimateID)
+	if !ok {
+		return
+	}
+
+	err = app.estimateOptionGroups.DeleteGroup(groupID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Option group removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// productBundleSet adds a component to a bundle, or changes how many of it the bundle has. Any product becomes a
+// bundle once it has a component; its unit price is the price of the whole bundle.
+func (app *application) productBundleSet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+	rejectComponent := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	componentID, err := strconv.Atoi(r.PostForm.Get("componentID"))
+	if err != nil || componentID < 1 {
+		rejectComponent("Please choose a product to include.")
+		return
+	}
+
+	quantity, err := strconv.Atoi(r.PostForm.Get("quantity"))
+	if err != nil || quantity < 1 {
+		rejectComponent("The quantity must be at least 1.")
+		return
+	}
+
+	err = app.products.SetBundleComponent(id, componentID, quantity, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			rejectComponent("The selected product does not exist.")
+		case errors.Is(err, models.ErrInvalidBundle):
+			rejectComponent("Bundles cannot contain themselves or other bundles, and cannot be part of a bundle.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Bundle updated.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productBundleRemove takes a component out of a bundle.
+func (app *application) productBundleRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	componentID, err := strconv.Atoi(r.PathValue("componentID"))
+	if err != nil || componentID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.products.RemoveBundleComponent(id, componentID, app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Bundle updated.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", id), http.StatusSeeOther)
+}
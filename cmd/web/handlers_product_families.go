@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// productFamilyForm is used by both the create and edit family pages. Variants is only filled on the edit page.
+type productFamilyForm struct {
+	FamilyID            int              `form:"-"`
+	Name                string           `form:"name"`
+	Description         string           `form:"description"`
+	Category            string           `form:"category"`
+	Subcategory         string           `form:"subcategory"`
+	Length              float32          `form:"length"`
+	Width               float32          `form:"width"`
+	Height              float32          `form:"height"`
+	Variants            []models.Product `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+// validate checks every field of the family form.
+func (f *productFamilyForm) validate() {
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank.")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long.")
+	f.CheckField(validator.MaxChars(f.Description, 255), "description", "This field cannot be more than 255 characters long.")
+	f.CheckField(validator.PermittedValue(f.Category, models.ProductCategories...), "category", "Please choose a valid category.")
+	f.CheckField(validator.NotBlank(f.Subcategory), "subcategory", "This field cannot be blank.")
+	f.CheckField(validator.MaxChars(f.Subcategory, 50), "subcategory", "This field cannot be more than 50 characters long.")
+	f.CheckField(!validator.LessThanN(f.Length, float32(0)), "length", "This value cannot be negative.")
+	f.CheckField(!validator.LessThanN(f.Width, float32(0)), "width", "This value cannot be negative.")
+	f.CheckField(!validator.LessThanN(f.Height, float32(0)), "height", "This value cannot be negative.")
+}
+
+// family converts the form into a ProductFamily.
+func (f *productFamilyForm) family() models.ProductFamily {
+	return models.ProductFamily{
+		FamilyID:    f.FamilyID,
+		Name:        strings.TrimSpace(f.Name),
+		Description: strings.TrimSpace(f.Description),
+		Category:    f.Category,
+		Subcategory: strings.TrimSpace(f.Subcategory),
+		Length:      f.Length,
+		Width:       f.Width,
+		Height:      f.Height,
+	}
+}
+
+func (app *application) productFamilyListView(w http.ResponseWriter, r *http.Request) {
+	families, err := app.productFamilies.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ProductFamilies = families
+
+	app.render(w, r, http.StatusOK, "listProductFamilies.tmpl", data)
+}
+
+func (app *application) productFamilyCreateView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = productFamilyForm{}
+
+	app.render(w, r, http.StatusOK, "productFamilyForm.tmpl", data)
+}
+
+func (app *application) productFamilyCreate(w http.ResponseWriter, r *http.Request) {
+	var form productFamilyForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+
+		app.render(w, r, http.StatusUnprocessableEntity, "productFamilyForm.tmpl", data)
+		return
+	}
+
+	family := form.family()
+	err = app.productFamilies.Insert(&family)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("%s family created. Add variants by choosing it on a product.", family.Name),
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/family/edit/%d", family.FamilyID), http.StatusSeeOther)
+}
+
+func (app *application) productFamilyEditView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	family, err := app.productFamilies.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = productFamilyForm{
+		FamilyID:    family.FamilyID,
+		Name:        family.Name,
+		Description: family.Description,
+		Category:    family.Category,
+		Subcategory: family.Subcategory,
+		Length:      family.Length,
+		Width:       family.Width,
+		Height:      family.Height,
+		Variants:    family.Variants,
+	}
+
+	app.render(w, r, http.StatusOK, "productFamilyForm.tmpl", data)
+}
+
+// productFamilyUpdate saves a family and copies its shared fields onto every variant.
+func (app *application) productFamilyUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form productFamilyForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form.FamilyID = id
+
+	form.validate()
+
+	if !form.Valid() {
+		existing, err := app.productFamilies.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				http.NotFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+		form.Variants = existing.Variants
+
+		data := app.newTemplateData(r)
+		data.Form = form
+
+		app.render(w, r, http.StatusUnprocessableEntity, "productFamilyForm.tmpl", data)
+		return
+	}
+
+	family := form.family()
+	err = app.productFamilies.Update(&family, app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("%s family and its variants were updated.", family.Name),
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/family/edit/%d", id), http.StatusSeeOther)
+}
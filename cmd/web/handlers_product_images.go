@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"ezkitchen/internal/imaging"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/storage"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// maxProductImageSize is the largest product photo accepted for upload.
+const maxProductImageSize = 10 << 20
+
+// productImageUpload stores a new photo for a product. Every variant in imaging.Variants is made from the upload
+// and the files of the photo it replaces are removed.
+func (app *application) productImageUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+	rejectUpload := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxProductImageSize+(64<<10))
+
+	err = r.ParseMultipartForm(maxProductImageSize)
+	if err != nil {
+		rejectUpload("Photos must be 10 MB or smaller.")
+		return
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		rejectUpload("Please choose a photo to upload.")
+		return
+	}
+	defer file.Close()
+
+	img, err := imaging.Decode(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedFormat):
+			rejectUpload("Please upload a JPEG, PNG or GIF photo.")
+		case errors.Is(err, imaging.ErrTooLarge):
+			rejectUpload("That photo has too many pixels. Please resize it and try again.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	key := storage.ProductImageKey(id)
+	for _, v := range imaging.Variants {
+		var buf bytes.Buffer
+		err = imaging.Encode(&buf, imaging.Fit(img, v.MaxWidth, v.MaxHeight))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		err = app.storage.UploadProductImage(r.Context(), key, v.Name, bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	oldKey, err := app.products.SetImage(id, key, app.currentUser(r).UserID)
+	if err != nil {
+		app.deleteProductImage(r, key)
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.deleteProductImage(r, oldKey)
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Photo uploaded.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productImageDelete removes the photo of a product.
+func (app *application) productImageDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	oldKey, err := app.products.SetImage(id, "", app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	app.deleteProductImage(r, oldKey)
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Photo removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", id), http.StatusSeeOther)
+}
+
+// productImage serves one variant of a product photo. It is public so customers see the photos on their invoice
+// link. URLs carry the upload they belong to, so a replaced photo is never served from a cache.
+func (app *application) productImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	variant := r.PathValue("variant")
+	if err != nil || id < 1 || !imaging.IsVariant(variant) {
+		http.NotFound(w, r)
+		return
+	}
+
+	product, err := app.products.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+	if product.ImageKey == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	obj, err := app.storage.Get(r.Context(), storage.ProductImageVariantKey(product.ImageKey, variant))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	if r.URL.Query().Get("v") == productImageVersion(product.ImageKey) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+	}
+
+	_, err = io.Copy(w, obj.Body)
+	if err != nil {
+		app.logger.Error(err.Error(), "method", r.Method, "uri", r.URL.RequestURI())
+	}
+}
+
+// productImageURL returns the URL of one variant of a product's photo, or "" when the product has none.
+func productImageURL(p models.Product, variant string) string {
+	if p.ImageKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("/product/image/%d/%s?v=%s", p.ProductID, variant, productImageVersion(p.ImageKey))
+}
+
+// productImageVersion identifies the upload a photo came from.
+func productImageVersion(key string) string {
+	return path.Base(key)
+}
+
+// deleteProductImage removes every variant of a replaced photo. Failures are only logged; a leftover file is
+// never served again since the product no longer points at it.
+func (app *application) deleteProductImage(r *http.Request, key string) {
+	if key == "" {
+		return
+	}
+
+	for _, v := range imaging.Variants {
+		err := app.storage.Delete(r.Context(), storage.ProductImageVariantKey(key, v.Name))
+		if err != nil {
+			app.logger.Warn("could not delete product image", "key", key, "variant", v.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/storage"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxImportSize is the largest product import file accepted.
+const maxImportSize = 2 << 20
+
+// productImportUpload is kept in the session between the preview and the commit of an import. The file itself is
+// kept in storage under Key; Digest is the digest of the previewed plan.
+type productImportUpload struct {
+	Key     string
+	Mapping map[string]string
+	Digest  string
+}
+
+// importColumn maps a catalog field to the column header of an import file.
+type importColumn struct {
+	Field  string
+	Header string
+}
+
+// productImportForm is the upload page. Columns lists every catalog field with the header it is read from.
+type productImportForm struct {
+	Columns             []importColumn
+	validator.Validator `form:"-"`
+}
+
+// newProductImportForm builds the upload form from a column mapping. Unmapped fields default to their own name.
+func newProductImportForm(mapping map[string]string) productImportForm {
+	var f productImportForm
+	for _, field := range models.ProductCSVFields {
+		header := mapping[field]
+		if header == "" {
+			header = field
+		}
+		f.Columns = append(f.Columns, importColumn{Field: field, Header: header})
+	}
+
+	return f
+}
+
+// importMessage turns an ErrInvalidImport into a message for the user.
+func importMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), models.ErrInvalidImport.Error()+": ")
+}
+
+// productExport downloads the whole catalog as a CSV file.
+func (app *application) productExport(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := app.products.ExportCSV(&buf)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="products.csv"`)
+	buf.WriteTo(w)
+}
+
+func (app *application) productImportView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = newProductImportForm(nil)
+
+	app.render(w, r, http.StatusOK, "importProducts.tmpl", data)
+}
+
+// productImportPreview reads an uploaded file and shows what importing it would do. Nothing is written to the
+// catalog; the file is kept in storage until the import is committed.
+func (app *application) productImportPreview(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+(64<<10))
+
+	err := r.ParseMultipartForm(maxImportSize)
+	if err != nil {
+		app.clientError(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	mapping := make(map[string]string)
+	for _, field := range models.ProductCSVFields {
+		mapping[field] = strings.TrimSpace(r.PostFormValue("column_" + field))
+	}
+
+	form := newProductImportForm(mapping)
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		form.AddNonFieldError("Please choose a CSV file to import.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "importProducts.tmpl", data)
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	plan, err := app.products.PlanImport(bytes.NewReader(content), mapping)
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidImport) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		form.AddNonFieldError(importMessage(err))
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "importProducts.tmpl", data)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.ProductImport = plan
+
+	if !plan.Valid() {
+		app.render(w, r, http.StatusUnprocessableEntity, "previewProductImport.tmpl", data)
+		return
+	}
+
+	key := storage.ProductImportKey(app.currentUser(r).UserID)
+	err = app.storage.UploadProductImport(r.Context(), key, bytes.NewReader(content))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// A newer preview replaces any earlier one that was never committed.
+	if earlier, ok := app.sessionManager.Get(r.Context(), "productImport").(productImportUpload); ok {
+		app.deleteProductImport(r, earlier.Key)
+	}
+	app.sessionManager.Put(r.Context(), "productImport", productImportUpload{
+		Key:     key,
+		Mapping: mapping,
+		Digest:  plan.Digest(),
+	})
+
+	app.render(w, r, http.StatusOK, "previewProductImport.tmpl", data)
+}
+
+// productImportCommit applies the previewed import in a single transaction. Nothing is imported when the import
+// would no longer do what the preview showed.
+func (app *application) productImportCommit(w http.ResponseWriter, r *http.Request) {
+	upload, ok := app.sessionManager.Pop(r.Context(), "productImport").(productImportUpload)
+	if !ok {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "The import has expired. Please upload the file again.",
+		})
+		http.Redirect(w, r, "/product/import", http.StatusSeeOther)
+		return
+	}
+
+	defer app.deleteProductImport(r, upload.Key)
+
+	obj, err := app.storage.Get(r.Context(), upload.Key)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer obj.Body.Close()
+
+	plan, err := app.products.ApplyImport(obj.Body, upload.Mapping, upload.Digest, app.currentUser(r).UserID)
+	if err != nil {
+		if !errors.Is(err, models.ErrImportChanged) && !errors.Is(err, models.ErrInvalidImport) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Nothing was imported because the file or the catalog changed since the preview. Please preview the import again.",
+		})
+		http.Redirect(w, r, "/product/import", http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("Import complete: %d added, %d updated.", plan.Inserts(), plan.Updates()),
+	})
+
+	http.Redirect(w, r, "/product/list", http.StatusSeeOther)
+}
+
+// deleteProductImport removes an import file from storage. A failure only leaves the file behind.
+func (app *application) deleteProductImport(r *http.Request, key string) {
+	err := app.storage.Delete(r.Context(), key)
+	if err != nil {
+		app.logger.Warn("could not delete product import", "key", key, "error", err)
+	}
+}
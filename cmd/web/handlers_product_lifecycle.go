@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// discontinuedItem is a Draft line item with a discontinued product and the products suggested in its place.
+type discontinuedItem struct {
+	Item        models.DiscontinuedLineItem
+	Suggestions []models.Product
+}
+
+// productDiscontinue stops a product from being offered for new line items. The form may name a replacement.
+func (app *application) productDiscontinue(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	replacementID, err := strconv.Atoi(r.PostForm.Get("replacementID"))
+	if err != nil {
+		replacementID = 0
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+
+	err = app.products.Discontinue(id, replacementID, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrInvalidReplacement):
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "error",
+				Message: "The replacement must be a different product that is still active.",
+			})
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Product discontinued. Existing estimates keep it; it can no longer be added to new line items.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productReactivate offers a discontinued product for new line items again.
+func (app *application) productReactivate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.products.Reactivate(id, app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Product reactivated.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", id), http.StatusSeeOther)
+}
+
+// discontinuedItemsView lists the Draft line items that still use discontinued products, each with suggested
+// replacements.
+func (app *application) discontinuedItemsView(w http.ResponseWriter, r *http.Request) {
+	items, err := app.estimateItems.GetDiscontinuedDraftItems()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Many line items share the same discontinued product, so suggestions are looked up once per product.
+	suggestions := make(map[int][]models.Product)
+	var list []discontinuedItem
+	for _, item := range items {
+		s, ok := suggestions[item.Product.ProductID]
+		if !ok {
+			s, err = app.products.SuggestReplacements(item.Product, 5)
+			if err != nil {
+				app.serverError(w, r, err)
+				return
+			}
+			suggestions[item.Product.ProductID] = s
+		}
+
+		list = append(list, discontinuedItem{Item: item, Suggestions: s})
+	}
+
+	data := app.newTemplateData(r)
+	data.DiscontinuedItems = list
+
+	app.render(w, r, http.StatusOK, "discontinuedItems.tmpl", data)
+}
+
+// lineItemReplace swaps the discontinued product of a Draft line item for an active one. The replacement is checked
+// like a product being added: it must be active, every component of a bundle must be active and it must fit along
+// the delivery path. Compatibility problems do not block the swap; they are shown as a warning.
+func (app *application) lineItemReplace(w http.ResponseWriter, r *http.Request) {
+	lineItemID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || lineItemID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	productID, err := strconv.Atoi(r.PostForm.Get("productID"))
+	if err != nil || productID < 1 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := "/product/discontinued"
+	reject := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	item, err := app.estimateItems.GetByLineItemID(lineItemID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	product, err := app.products.Get(productID)
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+	if err != nil || product.Discontinued {
+		reject("The replacement must be an active product.")
+		return
+	}
+
+	estimate, err := app.estimates.Get(item.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	path, err := app.deliveryPath(estimate)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	bundles, err := app.products.GetBundleComponents([]int{product.ProductID})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	message := discontinuedComponent(bundles[product.ProductID])
+	if message == "" {
+		message = deliveryProblem(product, bundles[product.ProductID], path)
+	}
+	if message != "" {
+		reject(fmt.Sprintf("%s cannot replace this line item. %s", product.Name, message))
+		return
+	}
+
+	err = app.estimateItems.ReplaceProduct(lineItemID, productID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrInvalidReplacement):
+			reject("The replacement must be an active product.")
+		case errors.Is(err, models.ErrEstimateLocked):
+			reject("That estimate is no longer a draft, so its line items cannot change.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	findings, err := app.itemChangeWarnings(item.EstimateID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var warnings []string
+	for _, f := range findings {
+		if f.LineItemID == lineItemID {
+			warnings = append(warnings, f.Message)
+		}
+	}
+
+	flash := FlashMessage{Type: "success", Message: fmt.Sprintf("Line item now uses %s.", product.Name)}
+	if len(warnings) > 0 {
+		flash.Type = "warning"
+		flash.Message += " " + strings.Join(warnings, " ")
+	}
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// productPriceSchedule sets the price a product will have from a future day. The price is entered in dollars.
+func (app *application) productPriceSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+	rejectPrice := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	dollars, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("unitPrice")), 64)
+	if err != nil || dollars <= 0 {
+		rejectPrice("The new price must be greater than zero.")
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", r.PostForm.Get("effectiveFrom"))
+	if err != nil {
+		rejectPrice("Please choose the day the new price starts.")
+		return
+	}
+
+	err = app.products.SchedulePrice(id, int(math.Round(dollars*100)), effectiveFrom, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrInvalidPriceDate):
+			rejectPrice("Scheduled prices must start after today. Edit the product to change today's price.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("New price scheduled for %s.", effectiveFrom.Format("Jan 2, 2006")),
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productPriceCancel removes a scheduled price before it takes effect.
+func (app *application) productPriceCancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	productID, err := app.products.CancelScheduledPrice(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Scheduled price cancelled.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", productID), http.StatusSeeOther)
+}
+
+// productPriceUpcomingView lists the scheduled price changes and the open Draft estimates they will reprice.
+func (app *application) productPriceUpcomingView(w http.ResponseWriter, r *http.Request) {
+	scheduled, err := app.products.GetScheduledPrices()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	impacts, err := app.products.GetUpcomingPriceImpact()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.ScheduledPrices = scheduled
+	data.PriceImpacts = impacts
+
+	app.render(w, r, http.StatusOK, "upcomingPrices.tmpl", data)
+}
+
+// priceChart is a step chart of a product's price over time, drawn as SVG. History is the line of prices that have
+// taken effect and Scheduled continues it with the prices still to come.
+type priceChart struct {
+	Width, Height float64
+	// Left is where the plot starts; the price labels sit to its left.
+	Left        float64
+	History     string
+	Scheduled   string
+	Markers     []priceChartMarker
+	TodayX      float64
+	Top, Bottom float64
+	MaxLabel    string
+	MinLabel    string
+	StartLabel  string
+	EndLabel    string
+}
+
+// priceChartMarker is a point on the chart where the price changes.
+type priceChartMarker struct {
+	X, Y      float64
+	Label     string
+	Scheduled bool
+}
+
+// Chart size and the space left around the plot for labels, in SVG units.
+const (
+	priceChartWidth  = 640.0
+	priceChartHeight = 220.0
+	priceChartLeft   = 70.0
+	priceChartRight  = 20.0
+	priceChartTop    = 20.0
+	priceChartBottom = 30.0
+)
+
+// newPriceChart lays out the price history of a product, oldest price first. It returns the zero chart when there
+// is no history to draw.
+func newPriceChart(history []models.ProductPrice, today time.Time) priceChart {
+	if len(history) == 0 {
+		return priceChart{}
+	}
+
+	start := history[0].EffectiveFrom
+	end := history[len(history)-1].EffectiveFrom
+	if today.After(end) {
+		end = today
+	}
+	// Leave room after the last change so its price shows as a line rather than a point.
+	span := end.Sub(start)
+	end = end.Add(max(span/10, 7*24*time.Hour))
+	span = end.Sub(start)
+
+	low, high := history[0].UnitPrice, history[0].UnitPrice
+	for _, p := range history {
+		low, high = min(low, p.UnitPrice), max(high, p.UnitPrice)
+	}
+	pad := max((high-low)/10, high/20, 1)
+	low, high = max(low-pad, 0), high+pad
+
+	plotWidth := priceChartWidth - priceChartLeft - priceChartRight
+	plotHeight := priceChartHeight - priceChartTop - priceChartBottom
+	x := func(t time.Time) float64 {
+		return priceChartLeft + plotWidth*float64(t.Sub(start))/float64(span)
+	}
+	y := func(cents int) float64 {
+		return priceChartTop + plotHeight*float64(high-cents)/float64(high-low)
+	}
+	point := func(px, py float64) string {
+		return fmt.Sprintf("%.1f,%.1f ", px, py)
+	}
+
+	chart := priceChart{
+		Width:      priceChartWidth,
+		Height:     priceChartHeight,
+		Left:       priceChartLeft,
+		TodayX:     x(today),
+		Top:        priceChartTop,
+		Bottom:     priceChartTop + plotHeight,
+		MaxLabel:   fmt.Sprintf("$%.2f", float64(high)/100),
+		MinLabel:   fmt.Sprintf("$%.2f", float64(low)/100),
+		StartLabel: start.Format("Jan 2, 2006"),
+		EndLabel:   end.Format("Jan 2, 2006"),
+	}
+
+	var applied, scheduled strings.Builder
+	for i, p := range history {
+		px, py := x(p.EffectiveFrom), y(p.UnitPrice)
+		next := end
+		if i+1 < len(history) {
+			next = history[i+1].EffectiveFrom
+		}
+
+		line := &applied
+		if p.Scheduled() {
+			line = &scheduled
+			if scheduled.Len() == 0 && i > 0 {
+				// Start the scheduled line where the price in effect leaves off.
+				scheduled.WriteString(point(px, y(history[i-1].UnitPrice)))
+			}
+		}
+		line.WriteString(point(px, py))
+		line.WriteString(point(x(next), py))
+
+		chart.Markers = append(chart.Markers, priceChartMarker{
+			X:         px,
+			Y:         py,
+			Label:     fmt.Sprintf("$%.2f from %s", float64(p.UnitPrice)/100, p.EffectiveFrom.Format("Jan 2, 2006")),
+			Scheduled: p.Scheduled(),
+		})
+	}
+	chart.History = strings.TrimSpace(applied.String())
+	chart.Scheduled = strings.TrimSpace(scheduled.String())
+
+	return chart
+}
+
+// productTierCreate adds a price break to a product. The threshold is a quantity for quantity breaks and a dollar
+// amount for order value breaks; the unit price is entered in dollars.
+func (app *application) productTierCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+	rejectTier := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	tier := &models.PriceTier{ProductID: id, Basis: models.TierBasis(r.PostForm.Get("basis"))}
+
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("threshold")), 64)
+	switch {
+	case !validator.PermittedValue(tier.Basis, models.TierBases...):
+		rejectTier("Please choose a quantity or an order value break.")
+		return
+	case err != nil || threshold <= 0:
+		rejectTier("The break must start above zero.")
+		return
+	case tier.IsQuantity():
+		if threshold != math.Trunc(threshold) || threshold < 2 {
+			rejectTier("Quantity breaks must start at a whole number of at least 2.")
+			return
+		}
+		tier.Threshold = int(threshold)
+	default:
+		tier.Threshold = int(math.Round(threshold * 100))
+	}
+
+	dollars, err := strconv.ParseFloat(strings.TrimSpace(r.PostForm.Get("unitPrice")), 64)
+	if err != nil || dollars < 0 {
+		rejectTier("The price cannot be negative.")
+		return
+	}
+	tier.UnitPrice = int(math.Round(dollars * 100))
+
+	err = app.products.InsertPriceTier(tier, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrDuplicatePriceTier):
+			rejectTier("This product already has a price break there.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Price break added.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productTierDelete removes a price break.
+func (app *application) productTierDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	productID, err := app.products.DeletePriceTier(id, app.currentUser(r).UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Price break removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", productID), http.StatusSeeOther)
+}
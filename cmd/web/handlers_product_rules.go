@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/compat"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// productRuleForm is the form on the product page that adds a rule. The target is either a product or, when
+// TargetProductID is 0, a subcategory.
+type productRuleForm struct {
+	Kind              string `form:"kind"`
+	TargetProductID   int    `form:"targetProductID"`
+	TargetSubcategory string `form:"targetSubcategory"`
+	Note              string `form:"note"`
+}
+
+// siteCheck is a site requirement of the products on an estimate, shown as a checklist on the edit page.
+type siteCheck struct {
+	RequirementID int
+	Name          string
+	// Products are the names of the products on the estimate that need it.
+	Products  []string
+	Confirmed bool
+}
+
+// productRuleCreate adds a requires, excludes or recommends rule to a product.
+func (app *application) productRuleCreate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form productRuleForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+	rejectRule := func(message string) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{Type: "error", Message: message})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+
+	switch {
+	case !validator.PermittedValue(compat.Kind(form.Kind), compat.Kinds...):
+		rejectRule("Please choose requires, excludes or recommends.")
+		return
+	case form.TargetProductID == 0 && !validator.NotBlank(form.TargetSubcategory):
+		rejectRule("Please choose a product or a subcategory.")
+		return
+	case !validator.MaxChars(form.TargetSubcategory, 50):
+		rejectRule("The subcategory cannot be more than 50 characters long.")
+		return
+	case !validator.MaxChars(form.Note, 255):
+		rejectRule("The note cannot be more than 255 characters long.")
+		return
+	}
+
+	rule := &models.ProductRule{
+		ProductID:       id,
+		Kind:            form.Kind,
+		TargetProductID: form.TargetProductID,
+		Note:            form.Note,
+		CreatedBy:       app.currentUser(r).UserID,
+	}
+	if form.TargetProductID == 0 {
+		rule.TargetSubcategory = form.TargetSubcategory
+	}
+
+	err = app.productRules.Insert(rule)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			rejectRule("The selected product does not exist.")
+		case errors.Is(err, models.ErrInvalidRule):
+			rejectRule("A product cannot have a rule about itself.")
+		case errors.Is(err, models.ErrDuplicateRule):
+			rejectRule("This product already has that rule.")
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Rule added.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productRuleDelete removes a rule.
+func (app *application) productRuleDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	productID, err := app.productRules.Delete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Rule removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", productID), http.StatusSeeOther)
+}
+
+// productRequirementAdd records something a product needs at the site. Requirements are shared between products by
+// name, so a new name becomes a requirement other products can use.
+func (app *application) productRequirementAdd(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/product/view/%d", id)
+
+	name := strings.TrimSpace(r.PostForm.Get("name"))
+	if !validator.NotBlank(name) || !validator.MaxChars(name, 100) {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: "Site requirements must be between 1 and 100 characters long.",
+		})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	err = app.productRules.AddProductRequirement(id, name)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Site requirement added.",
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// productRequirementRemove removes a site requirement from a product.
+func (app *application) productRequirementRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	requirementID, err := strconv.Atoi(r.PathValue("requirementID"))
+	if err != nil || requirementID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.productRules.RemoveProductRequirement(id, requirementID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Site requirement removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", id), http.StatusSeeOther)
+}
+
+// estimateSiteConfirm saves the site requirements the surveyor has confirmed are in place for an estimate.
+// Requirements that are left unchecked are no longer confirmed.
+func (app *application) estimateSiteConfirm(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	_, ok := app.editableEstimate(w, r, id)
+	if !ok {
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var requirementIDs []int
+	for _, value := range r.PostForm["requirement"] {
+		requirementID, err := strconv.Atoi(value)
+		if err != nil || requirementID < 1 {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+		requirementIDs = append(requirementIDs, requirementID)
+	}
+
+	err = app.productRules.SetConfirmedRequirements(id, requirementIDs, app.currentUser(r).UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Site checklist saved.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", id), http.StatusSeeOther)
+}
+
+// compatibility checks the catalog line items of an estimate against the product rules and the site requirements
+// confirmed for it. It also returns the site checklist for the products on the estimate.
+func (app *application) compatibility(estimateID int, estimateProducts []models.EstimateProduct) ([]compat.Finding, []siteCheck, error) {
+	var productIDs []int
+	var items []compat.Item
+	for _, ep := range estimateProducts {
+		if ep.EstimateItem.IsCustom() {
+			continue
+		}
+		productIDs = append(productIDs, ep.EstimateItem.ProductID)
+		items = append(items, compat.Item{
+			LineItemID:  ep.EstimateItem.LineItemID,
+			ProductID:   ep.EstimateItem.ProductID,
+			Name:        ep.Product.Name,
+			Subcategory: ep.Product.Subcategory,
+			OptionID:    ep.EstimateItem.OptionID.Int64,
+			Optional:    ep.EstimateItem.IsOptional,
+		})
+
+		// Each component of a bundle is installed like any other product.
+		for _, c := range ep.Components {
+			productIDs = append(productIDs, c.Product.ProductID)
+			items = append(items, compat.Item{
+				LineItemID:  ep.EstimateItem.LineItemID,
+				ProductID:   c.Product.ProductID,
+				Name:        c.Product.Name,
+				Subcategory: c.Product.Subcategory,
+				OptionID:    ep.EstimateItem.OptionID.Int64,
+				Optional:    ep.EstimateItem.IsOptional,
+			})
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil, nil
+	}
+
+	productRules, err := app.productRules.GetForProducts(productIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	productRequirements, err := app.productRules.GetProductRequirements(productIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	confirmed, err := app.productRules.GetConfirmedRequirements(estimateID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := make([]compat.Rule, len(productRules))
+	for i, pr := range productRules {
+		rules[i] = compat.Rule{
+			ProductID:         pr.ProductID,
+			Kind:              compat.Kind(pr.Kind),
+			TargetProductID:   pr.TargetProductID,
+			TargetSubcategory: pr.TargetSubcategory,
+			TargetName:        pr.TargetLabel(),
+			Note:              pr.Note,
+		}
+	}
+
+	requirements := make([]compat.Requirement, len(productRequirements))
+	var checklist []siteCheck
+	index := make(map[int]int)
+	for i, pr := range productRequirements {
+		requirements[i] = compat.Requirement{ProductID: pr.ProductID, RequirementID: pr.RequirementID, Name: pr.Name}
+
+		n, ok := index[pr.RequirementID]
+		if !ok {
+			n = len(checklist)
+			index[pr.RequirementID] = n
+			checklist = append(checklist, siteCheck{
+				RequirementID: pr.RequirementID,
+				Name:          pr.Name,
+				Confirmed:     confirmed[pr.RequirementID],
+			})
+		}
+		for _, item := range items {
+			if item.ProductID == pr.ProductID {
+				checklist[n].Products = append(checklist[n].Products, item.Name)
+				break
+			}
+		}
+	}
+
+	return compat.Check(items, rules, requirements, confirmed), checklist, nil
+}
+
+// itemChangeWarnings checks the line items of an estimate against the product rules after a change. extra stands in
+// for items that have not been saved, such as a rejected item the surveyor is trying to add.
+func (app *application) itemChangeWarnings(estimateID int, extra ...models.EstimateProduct) ([]compat.Finding, error) {
+	estimateProducts, err := app.estimateItems.GetByEstimateID(estimateID)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, _, err := app.compatibility(estimateID, append(estimateProducts, extra...))
+	return findings, err
+}
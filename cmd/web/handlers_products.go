@@ -1,93 +1,626 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func (app *application) productCreate(w http.ResponseWriter, r *http.Request) {
+// productForm is used by both the create and edit product pages. UnitPrice and VendorCost are entered in dollars.
+type productForm struct {
+	ProductID           int     `form:"-"`
+	Name                string  `form:"name"`
+	Description         string  `form:"description"`
+	Category            string  `form:"category"`
+	Subcategory         string  `form:"subcategory"`
+	Color               string  `form:"color"`
+	UnitPrice           float64 `form:"unitPrice"`
+	Length              float32 `form:"length"`
+	Width               float32 `form:"width"`
+	Height              float32 `form:"height"`
+	FamilyID            int     `form:"familyID"`
+	Finish              string  `form:"finish"`
+	Size                string  `form:"size"`
+	SKU                 string  `form:"sku"`
+	ModelNumber         string  `form:"modelNumber"`
+	VendorID            int     `form:"vendorID"`
+	VendorCost          float64 `form:"vendorCost"`
+	LeadTimeDays        int     `form:"leadTimeDays"`
+	validator.Validator `form:"-"`
+}
 
-	currUser := app.currentUser(r)
+// productFilterForm holds the category filter of the product list page.
+type productFilterForm struct {
+	Category string
+}
+
+// newProductForm fills a productForm from an existing product for the edit page.
+func newProductForm(p models.Product) productForm {
+	return productForm{
+		ProductID:    p.ProductID,
+		Name:         p.Name,
+		Description:  p.Description,
+		Category:     p.Category,
+		Subcategory:  p.Subcategory,
+		Color:        p.Color,
+		UnitPrice:    float64(p.UnitPrice) / 100,
+		Length:       p.Length,
+		Width:        p.Width,
+		Height:       p.Height,
+		FamilyID:     p.FamilyID,
+		Finish:       p.Finish,
+		Size:         p.Size,
+		SKU:          p.SKU,
+		ModelNumber:  p.ModelNumber,
+		VendorID:     p.VendorID,
+		VendorCost:   float64(p.VendorCost) / 100,
+		LeadTimeDays: p.LeadTimeDays,
+	}
+}
+
+// validate checks every field of the product form. Variants take their name, description and category from
+// their family, so those are only checked for standalone products.
+func (f *productForm) validate(families []models.ProductFamily, vendors []models.Vendor) {
+	if f.FamilyID == 0 {
+		f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank.")
+		f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long.")
+		f.CheckField(validator.MaxChars(f.Description, 255), "description", "This field cannot be more than 255 characters long.")
+		f.CheckField(validator.PermittedValue(f.Category, models.ProductCategories...), "category", "Please choose a valid category.")
+		f.CheckField(validator.NotBlank(f.Subcategory), "subcategory", "This field cannot be blank.")
+		f.CheckField(validator.MaxChars(f.Subcategory, 50), "subcategory", "This field cannot be more than 50 characters long.")
+	} else {
+		known := slices.ContainsFunc(families, func(pf models.ProductFamily) bool { return pf.FamilyID == f.FamilyID })
+		f.CheckField(known, "familyID", "Please choose a valid family.")
+	}
+	f.CheckField(validator.MaxChars(f.Color, 20), "color", "This field cannot be more than 20 characters long.")
+	f.CheckField(validator.MaxChars(f.Finish, 50), "finish", "This field cannot be more than 50 characters long.")
+	f.CheckField(validator.MaxChars(f.Size, 50), "size", "This field cannot be more than 50 characters long.")
+	f.CheckField(validator.GreaterThanN(f.UnitPrice, float64(0)), "unitPrice", "The price must be greater than zero.")
+	f.CheckField(!validator.LessThanN(f.Length, float32(0)), "length", "This value cannot be negative.")
+	f.CheckField(!validator.LessThanN(f.Width, float32(0)), "width", "This value cannot be negative.")
+	f.CheckField(!validator.LessThanN(f.Height, float32(0)), "height", "This value cannot be negative.")
+	f.CheckField(validator.MaxChars(f.SKU, 50), "sku", "This field cannot be more than 50 characters long.")
+	f.CheckField(validator.MaxChars(f.ModelNumber, 50), "modelNumber", "This field cannot be more than 50 characters long.")
+	if f.VendorID != 0 {
+		known := slices.ContainsFunc(vendors, func(v models.Vendor) bool { return v.VendorID == f.VendorID })
+		f.CheckField(known, "vendorID", "Please choose a valid vendor.")
+	}
+	f.CheckField(!validator.LessThanN(f.VendorCost, float64(0)), "vendorCost", "This value cannot be negative.")
+	f.CheckField(!validator.LessThanN(f.LeadTimeDays, 0), "leadTimeDays", "This value cannot be negative.")
+}
 
-	if currUser.Role != models.RoleAdmin {
-		app.clientError(w, r, http.StatusNotFound)
+// product converts the form into a Product, converting the price to cents.
+func (f *productForm) product() models.Product {
+	return models.Product{
+		ProductID:    f.ProductID,
+		Name:         strings.TrimSpace(f.Name),
+		Description:  strings.TrimSpace(f.Description),
+		Category:     f.Category,
+		Subcategory:  strings.TrimSpace(f.Subcategory),
+		Color:        strings.TrimSpace(f.Color),
+		UnitPrice:    int(math.Round(f.UnitPrice * 100)),
+		Length:       f.Length,
+		Width:        f.Width,
+		Height:       f.Height,
+		FamilyID:     f.FamilyID,
+		Finish:       strings.TrimSpace(f.Finish),
+		Size:         strings.TrimSpace(f.Size),
+		SKU:          strings.TrimSpace(f.SKU),
+		ModelNumber:  strings.TrimSpace(f.ModelNumber),
+		VendorID:     f.VendorID,
+		VendorCost:   int(math.Round(f.VendorCost * 100)),
+		LeadTimeDays: f.LeadTimeDays,
+	}
+}
+
+// renderProductForm renders the create and edit product page, which offers every product family and vendor to
+// choose from.
+func (app *application) renderProductForm(w http.ResponseWriter, r *http.Request, status int, form productForm) {
+	families, err := app.productFamilies.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
 		return
 	}
 
-	p := models.Product{
-		Name:        "32 Inch LG Fridge",
-		Description: "A fridge from lg that is 32 inches",
-		UnitPrice:   120099,
-		CreatedBy:   2,
+	vendors, err := app.vendors.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	err := app.products.Insert(&p)
+	data := app.newTemplateData(r)
+	data.Form = form
+	data.ProductFamilies = families
+	data.Vendors = vendors
+
+	app.render(w, r, status, "productForm.tmpl", data)
+}
+
+func (app *application) productListView(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+
+	products, err := app.products.List(category)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	fmt.Printf("product inserted, new ID is : %v", p.ProductID)
+	data := app.newTemplateData(r)
+	data.ProductList = products
+	data.Form = productFilterForm{Category: category}
 
+	app.render(w, r, http.StatusOK, "listProducts.tmpl", data)
 }
 
-func (app *application) productUpdate(w http.ResponseWriter, r *http.Request) {
+func (app *application) productView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	p, err := app.products.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	audit, err := app.products.GetAudit(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var replacements []models.Product
+	if !p.Discontinued {
+		replacements, err = app.products.SuggestReplacements(p, 20)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	var vendors []models.Vendor
+	if p.VendorID != 0 {
+		vendor, err := app.vendors.Get(p.VendorID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		vendors = []models.Vendor{vendor}
+	}
+
+	prices, err := app.products.GetPriceHistory(id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	p := models.Product{
-		ProductID:   1,
-		Name:        "28 Inch GE Fridge",
-		Description: "A fridge from GE that is 28 inches",
-		UnitPrice:   50099,
-		CreatedBy:   2,
+	tiers, err := app.products.GetPriceTiers([]int{id})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	bundles, err := app.products.GetBundleComponents([]int{id})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	err := app.products.Update(&p)
+	rules, err := app.productRules.GetInvolving(id)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	fmt.Printf("product updated, new ID is : %v", p.ProductID)
+	productRequirements, err := app.productRules.GetProductRequirements([]int{id})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
+	siteRequirements, err := app.productRules.GetRequirements()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	catalog, err := app.products.List("")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var subcategories []string
+	for _, c := range catalog {
+		if c.Subcategory != "" && !slices.Contains(subcategories, c.Subcategory) {
+			subcategories = append(subcategories, c.Subcategory)
+		}
+	}
+
+	data := app.newTemplateData(r)
+	data.Product = p
+	data.ProductAudit = audit
+	data.BundleComponents = bundles[id]
+	data.ProductRules = rules
+	data.ProductRequirements = productRequirements
+	data.SiteRequirements = siteRequirements
+	data.ProductList = catalog
+	data.Subcategories = subcategories
+	data.PriceHistory = prices
+	data.PriceTiers = tiers[id]
+	data.PriceChart = newPriceChart(prices, time.Now())
+	data.ReplacementProducts = replacements
+	data.Vendors = vendors
+
+	app.render(w, r, http.StatusOK, "viewProduct.tmpl", data)
 }
 
-func (app *application) productGet(w http.ResponseWriter, r *http.Request) {
+func (app *application) productCreateView(w http.ResponseWriter, r *http.Request) {
+	app.renderProductForm(w, r, http.StatusOK, productForm{})
+}
+
+func (app *application) productCreate(w http.ResponseWriter, r *http.Request) {
+	var form productForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	families, err := app.productFamilies.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	vendors, err := app.vendors.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate(families, vendors)
+
+	if !form.Valid() {
+		app.renderProductForm(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
 
 	currUser := app.currentUser(r)
 
-	if currUser.Role != models.RoleAdmin {
-		app.clientError(w, r, http.StatusNotFound)
+	p := form.product()
+	p.CreatedBy = currUser.UserID
+
+	err = app.products.CreateAudited(&p, currUser.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSKU) {
+			form.AddFieldError("sku", "Another product already has this SKU.")
+			app.renderProductForm(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("%s was added to the catalog.", p.Name),
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", p.ProductID), http.StatusSeeOther)
+}
+
+func (app *application) productEditView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
 		return
 	}
 
-	id := 2
-	var p models.Product
 	p, err := app.products.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.renderProductForm(w, r, http.StatusOK, newProductForm(p))
+}
+
+func (app *application) productUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form productForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form.ProductID = id
+
+	families, err := app.productFamilies.GetAll()
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	fmt.Printf("GOT PRODUCT ! : %+v", p)
+	vendors, err := app.vendors.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.validate(families, vendors)
+
+	if !form.Valid() {
+		app.renderProductForm(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	p := form.product()
+
+	err = app.products.UpdateAudited(&p, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrDuplicateSKU):
+			form.AddFieldError("sku", "Another product already has this SKU.")
+			app.renderProductForm(w, r, http.StatusUnprocessableEntity, form)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("%s was updated.", p.Name),
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/product/view/%d", p.ProductID), http.StatusSeeOther)
+}
+
+// productGet returns a single product as JSON.
+func (app *application) productGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		app.errorJSON(w, http.StatusNotFound, "product not found")
+		return
+	}
+
+	p, err := app.products.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "product not found")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// productLookupSKU returns the product with a SKU as JSON. Vendor costs are only included for admins.
+func (app *application) productLookupSKU(w http.ResponseWriter, r *http.Request) {
+	p, err := app.products.GetBySKU(r.PathValue("sku"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "no product has this SKU")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	resp := productSKUJSON{productJSON: newProductJSON(p), Discontinued: p.Discontinued}
+	if app.currentUser(r).Role == models.RoleAdmin {
+		resp.VendorID = p.VendorID
+		resp.VendorCost = p.VendorCost
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (app *application) productDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
 
-	currUser := app.currentUser(r)
+	product, err := app.products.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
 
-	if currUser.Role != models.RoleAdmin {
-		app.clientError(w, r, http.StatusNotFound)
+	err = app.products.DeleteAudited(id, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrProductInUse):
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "error",
+				Message: "This product is on at least one estimate or bundle and cannot be removed. Discontinue it instead.",
+			})
+			http.Redirect(w, r, fmt.Sprintf("/product/view/%d", id), http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
 		return
 	}
+	app.deleteProductImage(r, product.ImageKey)
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Product removed from the catalog.",
+	})
 
-	id := 2
+	http.Redirect(w, r, "/product/list", http.StatusSeeOther)
+}
+
+// Paging limits of the product search API.
+const (
+	defaultProductPageSize = 24
+	maxProductPageSize     = 100
+)
 
-	err := app.products.Delete(id)
+// productJSON is a product as returned by the product search and SKU lookup APIs. Vendor details are left out since
+// the APIs are open to every surveyor.
+type productJSON struct {
+	ProductID    int     `json:"product_id"`
+	Name         string  `json:"name"`
+	Description  string  `json:"description"`
+	Category     string  `json:"category"`
+	Subcategory  string  `json:"subcategory"`
+	Color        string  `json:"color"`
+	UnitPrice    int     `json:"unit_price"`
+	Length       float32 `json:"length"`
+	Width        float32 `json:"width"`
+	Height       float32 `json:"height"`
+	FamilyID     int     `json:"family_id"`
+	VariantLabel string  `json:"variant_label"`
+	SKU          string  `json:"sku"`
+	ImageURL     string  `json:"image_url"`
+}
+
+// newProductJSON converts a product for the product APIs.
+func newProductJSON(p models.Product) productJSON {
+	return productJSON{
+		ProductID:    p.ProductID,
+		Name:         p.Name,
+		Description:  p.Description,
+		Category:     p.Category,
+		Subcategory:  p.Subcategory,
+		Color:        p.Color,
+		UnitPrice:    p.UnitPrice,
+		Length:       p.Length,
+		Width:        p.Width,
+		Height:       p.Height,
+		FamilyID:     p.FamilyID,
+		VariantLabel: p.VariantLabel(),
+		SKU:          p.SKU,
+		ImageURL:     productImageURL(p, "thumb"),
+	}
+}
+
+// productSKUJSON is a product as returned by the SKU lookup. The vendor and vendor cost are only filled in for
+// admins.
+type productSKUJSON struct {
+	productJSON
+	Discontinued bool `json:"discontinued"`
+	VendorID     int  `json:"vendor_id,omitempty"`
+	VendorCost   int  `json:"vendor_cost,omitempty"`
+}
+
+// facetJSON is a facet value and the number of matching products that have it.
+type facetJSON struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// productSearchResponse is one page of product search results with the facets of every match.
+type productSearchResponse struct {
+	Products []productJSON `json:"products"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PerPage  int           `json:"per_page"`
+	Pages    int           `json:"pages"`
+	Facets   struct {
+		Categories    []facetJSON `json:"categories"`
+		Subcategories []facetJSON `json:"subcategories"`
+		Colors        []facetJSON `json:"colors"`
+	} `json:"facets"`
+}
+
+// productSearch returns a page of the active products matching the q, category, subcategory and color parameters as
+// JSON, with counts of the categories, subcategories and colors across every match. page starts at 1 and per_page
+// defaults to defaultProductPageSize.
+func (app *application) productSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	pageNumber, perPage := 1, defaultProductPageSize
+	if v := query.Get("page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			app.errorJSON(w, http.StatusBadRequest, "page must be a positive number")
+			return
+		}
+		pageNumber = n
+	}
+	if v := query.Get("per_page"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxProductPageSize {
+			app.errorJSON(w, http.StatusBadRequest, fmt.Sprintf("per_page must be between 1 and %d", maxProductPageSize))
+			return
+		}
+		perPage = n
+	}
+
+	search := models.ParseProductSearch(strings.TrimSpace(query.Get("q")))
+	search.Category = query.Get("category")
+	search.Subcategory = query.Get("subcategory")
+	search.Color = query.Get("color")
+
+	page, err := app.products.SearchPage(search, perPage, (pageNumber-1)*perPage)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	fmt.Printf("PRODUCT WITH ID %v HAS BEEN DELETED", id)
+	resp := productSearchResponse{
+		Products: make([]productJSON, len(page.Products)),
+		Total:    page.Total,
+		Page:     pageNumber,
+		PerPage:  perPage,
+		Pages:    (page.Total + perPage - 1) / perPage,
+	}
+	for i, p := range page.Products {
+		resp.Products[i] = newProductJSON(p)
+	}
+	resp.Facets.Categories = facetsJSON(page.Facets.Categories)
+	resp.Facets.Subcategories = facetsJSON(page.Facets.Subcategories)
+	resp.Facets.Colors = facetsJSON(page.Facets.Colors)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// facetsJSON converts facets for the product search API. It never returns nil so empty lists encode as [].
+func facetsJSON(facets []models.Facet) []facetJSON {
+	out := make([]facetJSON, len(facets))
+	for i, f := range facets {
+		out[i] = facetJSON{Value: f.Value, Count: f.Count}
+	}
+	return out
 }
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/pdf"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// purchaseOrderFilterForm is the status filter on the purchase order list. Statuses maps each status value to
+// its label.
+type purchaseOrderFilterForm struct {
+	Status   string
+	Statuses [][2]string
+}
+
+// purchaseOrderGenerate creates purchase orders for the line items of a signed estimate that have not been ordered.
+// Orders are generated when the customer signs; this regenerates them, ex. after a vendor is set on a product that
+// had none.
+func (app *application) purchaseOrderGenerate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	poIDs, skipped, err := app.purchaseOrders.GenerateForEstimate(id, app.currentUser(r).UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrEstimateNotSigned):
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "error",
+				Message: "Purchase orders can only be generated once the customer has signed.",
+			})
+			http.Redirect(w, r, fmt.Sprintf("/estimate/view/%d", id), http.StatusSeeOther)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	flash := FlashMessage{Type: "success", Message: fmt.Sprintf("%d purchase order(s) created.", len(poIDs))}
+	if len(poIDs) == 0 {
+		flash = FlashMessage{Type: "info", Message: "Every line item is already on a purchase order."}
+	}
+	if skipped > 0 {
+		flash.Type = "warning"
+		flash.Message += fmt.Sprintf(" %d line item(s) were skipped because their product has no vendor.", skipped)
+	}
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/view/%d", id), http.StatusSeeOther)
+}
+
+func (app *application) purchaseOrderListView(w http.ResponseWriter, r *http.Request) {
+	status := models.PurchaseOrderStatus(r.URL.Query().Get("status"))
+	if !slices.Contains(models.PurchaseOrderStatuses, status) {
+		status = ""
+	}
+
+	orders, err := app.purchaseOrders.List(status)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.PurchaseOrders = orders
+	form := purchaseOrderFilterForm{Status: string(status)}
+	for _, s := range models.PurchaseOrderStatuses {
+		form.Statuses = append(form.Statuses, [2]string{string(s), s.String()})
+	}
+	data.Form = form
+
+	app.render(w, r, http.StatusOK, "listPurchaseOrders.tmpl", data)
+}
+
+func (app *application) purchaseOrderView(w http.ResponseWriter, r *http.Request) {
+	po, ok := app.purchaseOrderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.PurchaseOrder = po
+
+	app.render(w, r, http.StatusOK, "viewPurchaseOrder.tmpl", data)
+}
+
+// purchaseOrderSend records that a draft purchase order went out to its vendor.
+func (app *application) purchaseOrderSend(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.purchaseOrders.MarkSent(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrPurchaseOrderStatus):
+			app.clientError(w, r, http.StatusConflict)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Purchase order marked as sent.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-order/view/%d", id), http.StatusSeeOther)
+}
+
+// purchaseOrderReceive records the quantities that arrived for each line, posted as "received_<POLineID>".
+func (app *application) purchaseOrderReceive(w http.ResponseWriter, r *http.Request) {
+	po, ok := app.purchaseOrderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	received := make(map[int]int)
+	for _, line := range po.Lines {
+		value := r.PostForm.Get(fmt.Sprintf("received_%d", line.POLineID))
+		if value == "" {
+			continue
+		}
+
+		quantity, err := strconv.Atoi(value)
+		if err != nil || quantity < 0 || quantity > line.Outstanding() {
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "error",
+				Message: fmt.Sprintf("Enter between 0 and %d for %s.", line.Outstanding(), line.Description),
+			})
+			http.Redirect(w, r, fmt.Sprintf("/purchase-order/view/%d", po.POID), http.StatusSeeOther)
+			return
+		}
+		received[line.POLineID] = quantity
+	}
+
+	err = app.purchaseOrders.Receive(po.POID, received)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+		case errors.Is(err, models.ErrPurchaseOrderStatus):
+			app.clientError(w, r, http.StatusConflict)
+		default:
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Received quantities saved.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-order/view/%d", po.POID), http.StatusSeeOther)
+}
+
+// purchaseOrderExpected changes the expected delivery date. An empty date clears it.
+func (app *application) purchaseOrderExpected(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	var expected sql.NullTime
+	if value := r.PostForm.Get("expectedDelivery"); value != "" {
+		expected.Time, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+		expected.Valid = true
+	}
+
+	err = app.purchaseOrders.SetExpectedDelivery(id, expected)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Expected delivery saved.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-order/view/%d", id), http.StatusSeeOther)
+}
+
+// purchaseOrderCSV downloads the lines of a purchase order as a CSV file.
+func (app *application) purchaseOrderCSV(w http.ResponseWriter, r *http.Request) {
+	po, ok := app.purchaseOrderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	err := po.WriteCSV(&buf)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="po-%d.csv"`, po.POID))
+	buf.WriteTo(w)
+}
+
+// purchaseOrderPDF downloads a purchase order as a PDF to send to the vendor.
+func (app *application) purchaseOrderPDF(w http.ResponseWriter, r *http.Request) {
+	po, ok := app.purchaseOrderFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	_, err := purchaseOrderDocument(po).WriteTo(&buf)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="po-%d.pdf"`, po.POID))
+	buf.WriteTo(w)
+}
+
+// purchaseOrderFromPath loads the purchase order named by the {id} path value. It writes the error response and
+// returns false when the purchase order cannot be loaded.
+func (app *application) purchaseOrderFromPath(w http.ResponseWriter, r *http.Request) (models.PurchaseOrder, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return models.PurchaseOrder{}, false
+	}
+
+	po, err := app.purchaseOrders.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.PurchaseOrder{}, false
+	}
+
+	return po, true
+}
+
+// purchaseOrderDocument lays out a purchase order for printing.
+func purchaseOrderDocument(po models.PurchaseOrder) *pdf.Document {
+	dollars := func(cents int) string { return fmt.Sprintf("$%.2f", float64(cents)/100) }
+
+	doc := pdf.New(fmt.Sprintf("Purchase Order #%d", po.POID))
+	doc.Text(18, pdf.Bold, fmt.Sprintf("Purchase Order #%d", po.POID))
+	doc.Text(10, pdf.Regular, fmt.Sprintf("Date: %s", po.CreatedAt.Format("Jan 2, 2006")))
+	doc.Text(10, pdf.Regular, fmt.Sprintf("Estimate: #%d", po.EstimateID))
+	if po.ExpectedDelivery.Valid {
+		doc.Text(10, pdf.Regular, fmt.Sprintf("Expected delivery: %s", po.ExpectedDelivery.Time.Format("Jan 2, 2006")))
+	}
+	doc.Space(10)
+
+	doc.Text(11, pdf.Bold, "Vendor")
+	doc.Text(10, pdf.Regular, po.VendorName)
+	for _, line := range []string{po.VendorContact, po.VendorEmail, po.VendorPhone} {
+		if line != "" {
+			doc.Text(10, pdf.Regular, line)
+		}
+	}
+	doc.Space(14)
+
+	cols := func(sku, description, quantity, cost, total string) []pdf.Column {
+		return []pdf.Column{
+			{X: 0, Width: 90, Text: sku},
+			{X: 96, Width: 214, Text: description},
+			{X: 316, Width: 40, Text: quantity, Right: true},
+			{X: 362, Width: 70, Text: cost, Right: true},
+			{X: 438, Width: 66, Text: total, Right: true},
+		}
+	}
+
+	doc.Row(10, pdf.Bold, cols("SKU", "Description", "Qty", "Unit Cost", "Total")...)
+	doc.Rule()
+	for _, l := range po.Lines {
+		description := l.Description
+		if l.ModelNumber != "" {
+			description += " (" + l.ModelNumber + ")"
+		}
+		doc.Row(10, pdf.Regular, cols(l.SKU, description, strconv.Itoa(l.Quantity), dollars(l.UnitCost), dollars(l.Total()))...)
+	}
+	doc.Rule()
+	doc.Row(11, pdf.Bold, cols("", "", "", "Total", dollars(po.Total))...)
+
+	return doc
+}
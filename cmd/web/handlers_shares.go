@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type estimateShareForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// shareableEstimate fetches an estimate whose sharing is being changed. Only the surveyor who created the estimate
+// and admins may share it; users it was shared with cannot pass it on.
+func (app *application) shareableEstimate(w http.ResponseWriter, r *http.Request) (models.Estimate, bool) {
+	estimateID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || estimateID < 1 {
+		http.NotFound(w, r)
+		return models.Estimate{}, false
+	}
+
+	estimate, err := app.estimates.Get(estimateID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Estimate{}, false
+	}
+
+	currUser := app.currentUser(r)
+
+	if currUser.UserID != estimate.CreatedBy && currUser.Role != models.RoleAdmin {
+		app.clientError(w, r, http.StatusForbidden)
+		return models.Estimate{}, false
+	}
+
+	return estimate, true
+}
+
+func (app *application) estimateShareCreate(w http.ResponseWriter, r *http.Request) {
+	estimate, ok := app.shareableEstimate(w, r)
+	if !ok {
+		return
+	}
+
+	var form estimateShareForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID)
+
+	user, err := app.users.GetByEmail(strings.TrimSpace(form.Email))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form.CheckField(err == nil, "email", "No user with that email exists.")
+	form.CheckField(user.Role == models.RoleSurveyor || user.Role == models.RoleAdmin, "email", "Estimates can only be shared with surveyors and admins.")
+	form.CheckField(user.UserID != estimate.CreatedBy, "email", "The estimate already belongs to this user.")
+
+	if !form.Valid() {
+		app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+			Type:    "error",
+			Message: form.FieldErrors["email"],
+		})
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	err = app.estimates.Share(estimate.EstimateID, user.UserID, app.currentUser(r).UserID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: fmt.Sprintf("Estimate shared with %s.", user.Name),
+	})
+
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+func (app *application) estimateShareDelete(w http.ResponseWriter, r *http.Request) {
+	estimate, ok := app.shareableEstimate(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil || userID < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	err = app.estimates.Unshare(estimate.EstimateID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+		Type:    "success",
+		Message: "Access removed.",
+	})
+
+	http.Redirect(w, r, fmt.Sprintf("/estimate/edit/%d", estimate.EstimateID), http.StatusSeeOther)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"ezkitchen/internal/validator"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// vendorForm is used by both the create and edit vendor pages.
+type vendorForm struct {
+	VendorID            int    `form:"-"`
+	Name                string `form:"name"`
+	ContactName         string `form:"contactName"`
+	Email               string `form:"email"`
+	Phone               string `form:"phone"`
+	validator.Validator `form:"-"`
+}
+
+// validate checks every field of the vendor form.
+func (f *vendorForm) validate() {
+	f.CheckField(validator.NotBlank(f.Name), "name", "This field cannot be blank.")
+	f.CheckField(validator.MaxChars(f.Name, 100), "name", "This field cannot be more than 100 characters long.")
+	f.CheckField(validator.MaxChars(f.ContactName, 100), "contactName", "This field cannot be more than 100 characters long.")
+	if strings.TrimSpace(f.Email) != "" {
+		f.CheckField(validator.IsValidEmail(strings.TrimSpace(f.Email)), "email", "Please enter a valid email address.")
+	}
+	f.CheckField(validator.MaxChars(f.Email, 255), "email", "This field cannot be more than 255 characters long.")
+	f.CheckField(validator.MaxChars(f.Phone, 30), "phone", "This field cannot be more than 30 characters long.")
+}
+
+// vendor converts the form into a Vendor.
+func (f *vendorForm) vendor() models.Vendor {
+	return models.Vendor{
+		VendorID:    f.VendorID,
+		Name:        strings.TrimSpace(f.Name),
+		ContactName: strings.TrimSpace(f.ContactName),
+		Email:       strings.TrimSpace(f.Email),
+		Phone:       strings.TrimSpace(f.Phone),
+	}
+}
+
+func (app *application) vendorListView(w http.ResponseWriter, r *http.Request) {
+	vendors, err := app.vendors.GetAll()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Vendors = vendors
+
+	app.render(w, r, http.StatusOK, "listVendors.tmpl", data)
+}
+
+func (app *application) vendorCreateView(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = vendorForm{}
+
+	app.render(w, r, http.StatusOK, "vendorForm.tmpl", data)
+}
+
+func (app *application) vendorCreate(w http.ResponseWriter, r *http.Request) {
+	var form vendorForm
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if form.Valid() {
+		vendor := form.vendor()
+		err = app.vendors.Insert(&vendor)
+		if err == nil {
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "success",
+				Message: fmt.Sprintf("%s was added as a vendor.", vendor.Name),
+			})
+
+			http.Redirect(w, r, "/product/vendor/list", http.StatusSeeOther)
+			return
+		}
+		if !errors.Is(err, models.ErrDuplicateVendor) {
+			app.serverError(w, r, err)
+			return
+		}
+		form.AddFieldError("name", "A vendor with this name already exists.")
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	app.render(w, r, http.StatusUnprocessableEntity, "vendorForm.tmpl", data)
+}
+
+func (app *application) vendorEditView(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	vendor, err := app.vendors.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = vendorForm{
+		VendorID:    vendor.VendorID,
+		Name:        vendor.Name,
+		ContactName: vendor.ContactName,
+		Email:       vendor.Email,
+		Phone:       vendor.Phone,
+	}
+
+	app.render(w, r, http.StatusOK, "vendorForm.tmpl", data)
+}
+
+func (app *application) vendorUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	var form vendorForm
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+	form.VendorID = id
+
+	form.validate()
+
+	if form.Valid() {
+		vendor := form.vendor()
+		err = app.vendors.Update(&vendor)
+		switch {
+		case err == nil:
+			app.sessionManager.Put(r.Context(), "flash", FlashMessage{
+				Type:    "success",
+				Message: fmt.Sprintf("%s was updated.", vendor.Name),
+			})
+
+			http.Redirect(w, r, "/product/vendor/list", http.StatusSeeOther)
+			return
+		case errors.Is(err, models.ErrNoRecord):
+			http.NotFound(w, r)
+			return
+		case !errors.Is(err, models.ErrDuplicateVendor):
+			app.serverError(w, r, err)
+			return
+		}
+		form.AddFieldError("name", "A vendor with this name already exists.")
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = form
+
+	app.render(w, r, http.StatusUnprocessableEntity, "vendorForm.tmpl", data)
+}
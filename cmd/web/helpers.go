@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"ezkitchen/internal/compat"
 	"ezkitchen/internal/models"
 	"fmt"
 	"log"
@@ -34,11 +35,88 @@ func (app *application) clientError(w http.ResponseWriter, r *http.Request, stat
 	http.Error(w, http.StatusText(status), status)
 }
 
-func (app *application) failedValidationJSON(w http.ResponseWriter, errors map[string]string) {
+// failedValidationJSON writes the field errors of a rejected request. Any product rule warnings for the estimate
+// are sent alongside so the surveyor sees them before trying again.
+func (app *application) failedValidationJSON(w http.ResponseWriter, errors map[string]string, warnings ...compat.Finding) {
+	body := map[string]any{
+		"errors": errors,
+	}
+	if len(warnings) > 0 {
+		body["warnings"] = warnings
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(body)
+}
+
+// warningsJSON answers a line item change that was saved with the product rule warnings the estimate now has.
+func (app *application) warningsJSON(w http.ResponseWriter, warnings []compat.Finding) {
+	if warnings == nil {
+		warnings = []compat.Finding{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]any{
-		"errors": errors,
+		"warnings": warnings,
+	})
+}
+
+// authorizeItemChange applies the estimate line item policy for the current user. When the change is not allowed it
+// writes a JSON error (404 unknown estimate, 403 no access, 409 no longer a Draft) and returns false.
+func (app *application) authorizeItemChange(w http.ResponseWriter, r *http.Request, estimateID int) bool {
+	err := app.estimates.AuthorizeItemChange(estimateID, app.currentUser(r))
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrNoRecord):
+		app.errorJSON(w, http.StatusNotFound, "estimate not found")
+	case errors.Is(err, models.ErrForbidden):
+		app.errorJSON(w, http.StatusForbidden, "you do not have access to this estimate")
+	case errors.Is(err, models.ErrEstimateLocked):
+		app.errorJSON(w, http.StatusConflict, "line items can only be changed while the estimate is a draft")
+	default:
+		app.serverError(w, r, err)
+	}
+	return false
+}
+
+// itemChangeError writes the JSON error for a line item change refused by the model: 404 if the line item is gone
+// and 409 if the estimate left Draft after the change was authorized.
+func (app *application) itemChangeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.errorJSON(w, http.StatusNotFound, "line item not found")
+	case errors.Is(err, models.ErrEstimateLocked):
+		app.errorJSON(w, http.StatusConflict, "line items can only be changed while the estimate is a draft")
+	default:
+		app.serverError(w, r, err)
+	}
+}
+
+// lineItemEstimateID looks up the estimate a line item belongs to. When the line item does not exist it writes a
+// JSON 404 and returns false.
+func (app *application) lineItemEstimateID(w http.ResponseWriter, r *http.Request, lineItemID int) (int, bool) {
+	estimateID, err := app.estimateItems.GetEstimateIDByLineItemID(lineItemID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.errorJSON(w, http.StatusNotFound, "line item not found")
+		} else {
+			app.serverError(w, r, err)
+		}
+		return 0, false
+	}
+
+	return estimateID, true
+}
+
+// errorJSON writes a JSON error body of the form {"error": message} with the given status.
+func (app *application) errorJSON(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": message,
 	})
 }
 
@@ -127,7 +205,7 @@ func (app *application) currentUser(r *http.Request) models.User {
 }
 
 // newTemplateData generates a struct of templateData. This should be used in all renders.
-// This function also instantiates the session flash, IsAuthenticated, and CSRFToken properties to template data.
+// This function also instantiates the session flash, IsAuthenticated, IsAdmin, Categories and CSRFToken properties to template data.
 func (app *application) newTemplateData(r *http.Request) templateData {
 	var flash FlashMessage
 	val := app.sessionManager.Pop(r.Context(), "flash")
@@ -138,6 +216,8 @@ func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
 		Flash:           flash,
 		IsAuthenticated: app.isAuthenticated(r),
+		IsAdmin:         app.currentUser(r).Role == models.RoleAdmin,
+		Categories:      models.ProductCategories,
 		CSRFToken:       nosurf.Token(r),
 	}
 }
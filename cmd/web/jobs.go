@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// runEvery runs job straight away and then once every interval for as long as the server runs. A failed run is
+// logged and tried again at the next interval.
+func (app *application) runEvery(name string, interval time.Duration, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := job()
+		if err != nil {
+			app.logger.Error(err.Error(), "job", name)
+		}
+		<-ticker.C
+	}
+}
+
+// applyScheduledPrices puts scheduled product prices into effect once their day comes.
+func (app *application) applyScheduledPrices() error {
+	n, err := app.products.ApplyScheduledPrices()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		app.logger.Info("applied scheduled prices", "products", n)
+	}
+	return nil
+}
+
+// sendSigningReminders emails customers whose signing links have a reminder due. Links stop being reminded once
+// they are used, revoked or expire.
+func (app *application) sendSigningReminders() error {
+	tokens, unreadable, err := app.invoiceToken.GetRemindable()
+	if err != nil {
+		return err
+	}
+	for _, id := range unreadable {
+		app.logger.Warn("signing link cannot be unsealed and will not be reminded", "invoice_token_id", id)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, rt := range tokens {
+		if !app.invoiceLinks.reminderDue(rt.InvoiceToken, now) {
+			continue
+		}
+		err := app.sendSigningReminder(rt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("reminding signing link %d: %w", rt.InvoiceTokenID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
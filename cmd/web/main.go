@@ -34,17 +34,27 @@ import (
 )
 
 type application struct {
-	logger         *slog.Logger
-	estimates      *models.EstimateModel
-	products       *models.ProductModel
-	estimateItems  *models.EstimateItemModel
-	users          *models.UserModel
-	invoiceToken   *models.InvoiceTokenModel
-	storage        *storage.R2Storage
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
-	mailer         *mailer.Mailer
+	logger               *slog.Logger
+	estimates            *models.EstimateModel
+	products             *models.ProductModel
+	estimateItems        *models.EstimateItemModel
+	estimateOptionGroups *models.EstimateOptionModel
+	estimateOpenings     *models.EstimateOpeningModel
+	productFamilies      *models.ProductFamilyModel
+	vendors              *models.VendorModel
+	inventory            *models.InventoryModel
+	purchaseOrders       *models.PurchaseOrderModel
+	productRules         *models.ProductRuleModel
+	users                *models.UserModel
+	invoiceToken         *models.InvoiceTokenModel
+	estimateResponses    *models.EstimateResponseModel
+	signatureAudits      *models.SignatureAuditModel
+	storage              *storage.R2Storage
+	templateCache        map[string]*template.Template
+	formDecoder          *form.Decoder
+	sessionManager       *scs.SessionManager
+	mailer               *mailer.Mailer
+	invoiceLinks         invoiceLinkConfig
 }
 
 func main() {
@@ -108,6 +118,7 @@ func main() {
 	sessionManager.Store = postgresstore.New(db)
 	sessionManager.Lifetime = 12 * time.Hour
 	gob.Register(FlashMessage{})
+	gob.Register(productImportUpload{})
 
 	mailer, err := mailer.NewMailer()
 	if err != nil {
@@ -115,18 +126,44 @@ func main() {
 		os.Exit(1)
 	}
 
+	invoiceLinks, err := invoiceLinkConfigFromEnv()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	invoiceTokenKey, err := invoiceTokenKeyFromEnv()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	if invoiceTokenKey == nil && invoiceLinks.reminders() {
+		logger.Error("INVOICE_TOKEN_KEY must be set to send signing reminders, or INVOICE_REMINDERS_AFTER and INVOICE_REMINDERS_BEFORE set empty to send none")
+		os.Exit(1)
+	}
+
 	app := &application{
-		estimates:      &models.EstimateModel{DB: db},
-		products:       &models.ProductModel{DB: db},
-		estimateItems:  &models.EstimateItemModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		invoiceToken:   &models.InvoiceTokenModel{DB: db},
-		storage:        storage.NewR2Storage(client, r2Bucket),
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-		mailer:         mailer,
-		logger:         logger,
+		estimates:            &models.EstimateModel{DB: db},
+		products:             &models.ProductModel{DB: db},
+		estimateItems:        &models.EstimateItemModel{DB: db},
+		estimateOptionGroups: &models.EstimateOptionModel{DB: db},
+		estimateOpenings:     &models.EstimateOpeningModel{DB: db},
+		productFamilies:      &models.ProductFamilyModel{DB: db},
+		vendors:              &models.VendorModel{DB: db},
+		inventory:            &models.InventoryModel{DB: db},
+		purchaseOrders:       &models.PurchaseOrderModel{DB: db},
+		productRules:         &models.ProductRuleModel{DB: db},
+		users:                &models.UserModel{DB: db},
+		invoiceToken:         &models.InvoiceTokenModel{DB: db, Key: invoiceTokenKey},
+		estimateResponses:    &models.EstimateResponseModel{DB: db},
+		signatureAudits:      &models.SignatureAuditModel{DB: db},
+		storage:              storage.NewR2Storage(client, r2Bucket),
+		templateCache:        templateCache,
+		formDecoder:          formDecoder,
+		sessionManager:       sessionManager,
+		mailer:               mailer,
+		logger:               logger,
+		invoiceLinks:         invoiceLinks,
 	}
 
 	srv := &http.Server{
@@ -155,6 +192,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	go app.runEvery("scheduled prices", 15*time.Minute, app.applyScheduledPrices)
+
+	if invoiceLinks.reminders() {
+		go app.runEvery("signing reminders", 15*time.Minute, app.sendSigningReminders)
+	}
+
 	logger.Info("Starting server", "addr", *addr)
 
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"ezkitchen/internal/models"
 	"fmt"
 	"net/http"
 
@@ -68,6 +69,18 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 
 }
 
+// requireAdmin only lets admins through. Everyone else gets a 404 so admin pages are not advertised.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.currentUser(r).Role != models.RoleAdmin {
+			app.clientError(w, r, http.StatusNotFound)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
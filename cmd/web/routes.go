@@ -15,6 +15,7 @@ func (app *application) routes() http.Handler {
 
 	dynamic := alice.New(app.sessionManager.LoadAndSave, app.authenticate)
 	protected := dynamic.Append(app.requireAuthentication)
+	admin := protected.Append(app.requireAdmin)
 
 	// --------------- Estimates ---------------
 	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
@@ -24,26 +25,100 @@ func (app *application) routes() http.Handler {
 	mux.Handle("POST /estimate/create", protected.ThenFunc(app.estimateCreatePost))
 	mux.Handle("POST /estimate/update", protected.ThenFunc(app.estimateUpdate))
 	mux.Handle("POST /estimate/{id}/items/", protected.ThenFunc(app.estimateAddItem))
+	mux.Handle("POST /estimate/{id}/items/batch", protected.ThenFunc(app.estimateBatchItems))
+	mux.Handle("POST /estimate/{id}/items/custom", protected.ThenFunc(app.estimateAddCustomItem))
 	mux.Handle("POST /estimate/{id}/progress", protected.ThenFunc(app.progressEstimate))
+	mux.Handle("GET /estimate/agreement/{id}", protected.ThenFunc(app.estimateAgreement))
+	mux.Handle("GET /estimate/certificate/{id}", protected.ThenFunc(app.estimateCertificate))
 	mux.Handle("PUT /estimate/items/{id}", protected.ThenFunc(app.estimateUpdateItem))
 	mux.Handle("DELETE /estimate/items/{id}", protected.ThenFunc(app.estimateDeleteItem))
+	mux.Handle("POST /estimate/{id}/options/groups", protected.ThenFunc(app.optionGroupCreate))
+	mux.Handle("POST /estimate/options/groups/{id}/options", protected.ThenFunc(app.optionCreate))
+	mux.Handle("POST /estimate/options/groups/{id}/delete", protected.ThenFunc(app.optionGroupDelete))
+	mux.Handle("POST /estimate/{id}/delivery/openings", protected.ThenFunc(app.openingCreate))
+	mux.Handle("POST /estimate/delivery/openings/{id}/delete", protected.ThenFunc(app.openingDelete))
+	mux.Handle("POST /estimate/{id}/site", protected.ThenFunc(app.estimateSiteConfirm))
+	mux.Handle("POST /estimate/{id}/shares", protected.ThenFunc(app.estimateShareCreate))
+	mux.Handle("POST /estimate/{id}/shares/{userID}/delete", protected.ThenFunc(app.estimateShareDelete))
 
 	mux.Handle("GET /estimate/list", protected.ThenFunc(app.estimateListView))
 
 	mux.Handle("DELETE /estimate/delete/{id}", protected.ThenFunc(app.estimateDelete))
 
 	// --------------- Products ---------------
-	mux.Handle("GET /product/get/{id}", protected.ThenFunc(app.productGet))
+	mux.Handle("GET /product/get/{id}", admin.ThenFunc(app.productGet))
 	mux.Handle("GET /product/get/", protected.ThenFunc(app.fetchProductsByFilters))
-	mux.Handle("POST /product/create", protected.ThenFunc(app.productCreate))
-	mux.Handle("POST /product/update", protected.ThenFunc(app.productUpdate))
-	mux.Handle("DELETE /product/delete", protected.ThenFunc(app.productDelete))
+	mux.Handle("GET /product/list", admin.ThenFunc(app.productListView))
+	mux.Handle("GET /product/export", admin.ThenFunc(app.productExport))
+	mux.Handle("GET /product/family/list", admin.ThenFunc(app.productFamilyListView))
+	mux.Handle("GET /product/family/create", admin.ThenFunc(app.productFamilyCreateView))
+	mux.Handle("POST /product/family/create", admin.ThenFunc(app.productFamilyCreate))
+	mux.Handle("GET /product/family/edit/{id}", admin.ThenFunc(app.productFamilyEditView))
+	mux.Handle("POST /product/family/update/{id}", admin.ThenFunc(app.productFamilyUpdate))
+	mux.Handle("GET /product/sku/{sku}", protected.ThenFunc(app.productLookupSKU))
+	mux.Handle("GET /product/search", protected.ThenFunc(app.productSearch))
+	mux.Handle("GET /product/vendor/list", admin.ThenFunc(app.vendorListView))
+	mux.Handle("GET /product/vendor/create", admin.ThenFunc(app.vendorCreateView))
+	mux.Handle("POST /product/vendor/create", admin.ThenFunc(app.vendorCreate))
+	mux.Handle("GET /product/vendor/edit/{id}", admin.ThenFunc(app.vendorEditView))
+	mux.Handle("POST /product/vendor/update/{id}", admin.ThenFunc(app.vendorUpdate))
+	mux.Handle("GET /product/import", admin.ThenFunc(app.productImportView))
+	mux.Handle("POST /product/import", admin.ThenFunc(app.productImportPreview))
+	mux.Handle("POST /product/import/commit", admin.ThenFunc(app.productImportCommit))
+	mux.Handle("GET /product/view/{id}", admin.ThenFunc(app.productView))
+	mux.Handle("GET /product/create", admin.ThenFunc(app.productCreateView))
+	mux.Handle("POST /product/create", admin.ThenFunc(app.productCreate))
+	mux.Handle("GET /product/edit/{id}", admin.ThenFunc(app.productEditView))
+	mux.Handle("POST /product/update/{id}", admin.ThenFunc(app.productUpdate))
+	mux.Handle("POST /product/delete/{id}", admin.ThenFunc(app.productDelete))
+	mux.Handle("POST /product/discontinue/{id}", admin.ThenFunc(app.productDiscontinue))
+	mux.Handle("POST /product/reactivate/{id}", admin.ThenFunc(app.productReactivate))
+	mux.Handle("POST /product/price/schedule/{id}", admin.ThenFunc(app.productPriceSchedule))
+	mux.Handle("POST /product/price/cancel/{id}", admin.ThenFunc(app.productPriceCancel))
+	mux.Handle("GET /product/price/upcoming", admin.ThenFunc(app.productPriceUpcomingView))
+	mux.Handle("POST /product/tier/create/{id}", admin.ThenFunc(app.productTierCreate))
+	mux.Handle("POST /product/tier/delete/{id}", admin.ThenFunc(app.productTierDelete))
+	mux.Handle("POST /product/bundle/set/{id}", admin.ThenFunc(app.productBundleSet))
+	mux.Handle("POST /product/bundle/remove/{id}/{componentID}", admin.ThenFunc(app.productBundleRemove))
+	mux.Handle("POST /product/rule/create/{id}", admin.ThenFunc(app.productRuleCreate))
+	mux.Handle("POST /product/rule/delete/{id}", admin.ThenFunc(app.productRuleDelete))
+	mux.Handle("POST /product/requirement/add/{id}", admin.ThenFunc(app.productRequirementAdd))
+	mux.Handle("POST /product/requirement/remove/{id}/{requirementID}", admin.ThenFunc(app.productRequirementRemove))
+	mux.Handle("POST /product/image/upload/{id}", admin.ThenFunc(app.productImageUpload))
+	mux.Handle("POST /product/image/delete/{id}", admin.ThenFunc(app.productImageDelete))
+	mux.Handle("GET /product/image/{id}/{variant}", dynamic.ThenFunc(app.productImage))
+	mux.Handle("GET /product/discontinued", admin.ThenFunc(app.discontinuedItemsView))
+	mux.Handle("POST /product/discontinued/items/{id}/replace", admin.ThenFunc(app.lineItemReplace))
+
+	// --------------- Inventory ---------------
+	mux.Handle("GET /inventory", admin.ThenFunc(app.inventoryView))
+	mux.Handle("POST /inventory/adjust", admin.ThenFunc(app.inventoryAdjust))
+	mux.Handle("POST /inventory/reorder", admin.ThenFunc(app.inventoryReorderPoint))
+	mux.Handle("POST /inventory/locations", admin.ThenFunc(app.inventoryLocationCreate))
+	mux.Handle("GET /inventory/ledger", admin.ThenFunc(app.inventoryLedgerView))
+
+	// --------------- Purchase Orders ---------------
+	mux.Handle("POST /estimate/{id}/purchase-orders", admin.ThenFunc(app.purchaseOrderGenerate))
+	mux.Handle("GET /purchase-order/list", admin.ThenFunc(app.purchaseOrderListView))
+	mux.Handle("GET /purchase-order/view/{id}", admin.ThenFunc(app.purchaseOrderView))
+	mux.Handle("POST /purchase-order/send/{id}", admin.ThenFunc(app.purchaseOrderSend))
+	mux.Handle("POST /purchase-order/receive/{id}", admin.ThenFunc(app.purchaseOrderReceive))
+	mux.Handle("POST /purchase-order/expected/{id}", admin.ThenFunc(app.purchaseOrderExpected))
+	mux.Handle("GET /purchase-order/csv/{id}", admin.ThenFunc(app.purchaseOrderCSV))
+	mux.Handle("GET /purchase-order/pdf/{id}", admin.ThenFunc(app.purchaseOrderPDF))
+
+	// --------------- Signing links ---------------
+	mux.Handle("POST /estimate/{id}/links", admin.ThenFunc(app.invoiceLinkIssue))
+	mux.Handle("POST /estimate/link/revoke/{id}", admin.ThenFunc(app.invoiceLinkRevoke))
 
 	// --------------- Invoices ---------------
 
 	mux.Handle("GET /invoice/sign", dynamic.ThenFunc(app.signInvoiceView))
 	mux.Handle("POST /invoice/sign", dynamic.ThenFunc(app.submitSignature))
+	mux.Handle("POST /invoice/totals", dynamic.ThenFunc(app.invoiceTotalsJSON))
+	mux.Handle("POST /invoice/respond", dynamic.ThenFunc(app.invoiceRespond))
 	mux.Handle("GET /invoice/signature/{id}", protected.ThenFunc(app.getInvoiceSignature))
+	mux.Handle("GET /invoice/agreement", dynamic.ThenFunc(app.invoiceAgreement))
 
 	// --------------- Users ---------------
 	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLoginView))
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"ezkitchen/internal/compat"
 	"ezkitchen/internal/models"
 	"fmt"
 	"io/fs"
@@ -19,11 +20,62 @@ type templateData struct {
 	Customer        models.User
 	Products        []models.EstimateProduct
 	EstimateTotals  models.EstimateTotals
-	Form            any
-	Token           string
-	Flash           FlashMessage
-	IsAuthenticated bool
-	CSRFToken       string
+	OptionGroups    []models.OptionGroup
+	Addons          []models.EstimateProduct
+	Shares          []models.EstimateShare
+	CanShare        bool
+	Openings        []models.EstimateOpening
+	DeliveryChecks  []deliveryCheck
+	Product         models.Product
+	ProductList     []models.Product
+	ProductAudit    []models.ProductAuditEntry
+	ProductImport   models.ProductImportPlan
+	ProductFamilies []models.ProductFamily
+	// ReplacementProducts are the products offered in place of one being discontinued.
+	ReplacementProducts []models.Product
+	DiscontinuedItems   []discontinuedItem
+	Vendors             []models.Vendor
+	// Margins is only filled for admins.
+	Margins       models.EstimateMargin
+	StockWarnings []stockWarning
+	StockLevels   []models.StockLevel
+	// InventoryLocations are the places stock is kept.
+	InventoryLocations []models.InventoryLocation
+	Reservations       []models.InventoryReservation
+	InventoryLedger    []models.InventoryAdjustment
+	PurchaseOrders     []models.PurchaseOrder
+	// CustomerResponses are the customer's reasons for declining an estimate or the changes they asked for.
+	CustomerResponses []models.EstimateResponse
+	// SignatureAudit is the audit record of the estimate's signature, when it has one.
+	SignatureAudit *models.SignatureAudit
+	// Consent is the statement customers agree to before signing electronically.
+	Consent consentStatement
+	// SigningLinks are the invoice signing links issued for an estimate, shown to admins.
+	SigningLinks  []signingLink
+	PurchaseOrder models.PurchaseOrder
+	// PriceHistory lists every price of a product, scheduled ones included.
+	PriceHistory    []models.ProductPrice
+	PriceChart      priceChart
+	ScheduledPrices []models.ProductPrice
+	PriceImpacts    []models.PriceChangeImpact
+	// PriceTiers are the price breaks of a product.
+	PriceTiers []models.PriceTier
+	// BundleComponents are the products a bundle is made of.
+	BundleComponents []models.BundleComponent
+	// ProductRules are the rules of a product and the rules of other products that target it.
+	ProductRules        []models.ProductRule
+	ProductRequirements []models.ProductRequirement
+	SiteRequirements    []models.SiteRequirement
+	Subcategories       []string
+	CompatFindings      []compat.Finding
+	SiteChecklist       []siteCheck
+	Categories          []string
+	Form                any
+	Token               string
+	Flash               FlashMessage
+	IsAuthenticated     bool
+	IsAdmin             bool
+	CSRFToken           string
 }
 
 type FlashMessage struct {
@@ -43,6 +95,7 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		"list": func(vals ...string) []string {
 			return vals
 		},
+		"productImage": productImageURL,
 	}
 
 	pages, err := filepath.Glob("./ui/html/pages/**/*.tmpl")
@@ -0,0 +1,190 @@
+// Package clearance checks whether a boxed item can be carried from the street to the kitchen.
+// The delivery path is modelled as an ordered list of openings (front door, hallways, stairs, the kitchen door).
+// Movers may reorient the box between openings, so each opening is checked on its own and the first opening the
+// box cannot pass is reported.
+package clearance
+
+import (
+	"fmt"
+	"math"
+)
+
+// Kind is the type of an opening along the delivery path.
+type Kind string
+
+const (
+	// KindDoor is a doorway or other thin opening. Only the box's cross-section matters.
+	KindDoor Kind = "door"
+	// KindHallway is a corridor. Width is the corridor width, Height the ceiling height and TurnWidth the width of
+	// the corridor it turns 90 degrees into (0 when the hallway is straight).
+	KindHallway Kind = "hallway"
+	// KindStairs is a staircase. Width is the stair width, Height the headroom measured square to the pitch of the
+	// stairs and TurnWidth the width of the landing turn (0 when the stairs are straight).
+	KindStairs Kind = "stairs"
+)
+
+// Kinds lists every opening kind in the order they are offered to users.
+var Kinds = []Kind{KindDoor, KindHallway, KindStairs}
+
+// Opening is a single obstacle along the delivery path. All measurements are in inches.
+type Opening struct {
+	Name      string
+	Kind      Kind
+	Width     float64
+	Height    float64
+	TurnWidth float64
+}
+
+// Box is the outer dimensions of an item in inches, in no particular orientation.
+type Box struct {
+	Length float64
+	Width  float64
+	Height float64
+}
+
+// Report is the result of checking a box against a delivery path.
+type Report struct {
+	Fits bool
+	// Blocked is the index within the path of the first opening the box cannot pass, or -1 when it fits.
+	Blocked int
+	// BlockedBy is the name of the blocking opening.
+	BlockedBy string
+	// Reason explains why the box does not fit.
+	Reason string
+}
+
+// Check reports whether a box can be carried through every opening of a path in order.
+// Boxes without dimensions (ex. flooring sold by the square foot) always fit.
+func Check(box Box, path []Opening) Report {
+	if box.Length <= 0 || box.Width <= 0 || box.Height <= 0 {
+		return Report{Fits: true, Blocked: -1}
+	}
+
+	for i, o := range path {
+		if passes(box, o) {
+			continue
+		}
+
+		return Report{
+			Blocked:   i,
+			BlockedBy: o.Name,
+			Reason:    reason(box, o),
+		}
+	}
+
+	return Report{Fits: true, Blocked: -1}
+}
+
+// passes reports whether a box can be moved through a single opening in any orientation.
+func passes(box Box, o Opening) bool {
+	dims := [3]float64{box.Length, box.Width, box.Height}
+
+	// faces lists each pair of box dimensions that can face the opening along with the remaining dimension,
+	// which is the one the box travels along.
+	faces := [3][3]float64{
+		{dims[0], dims[1], dims[2]},
+		{dims[0], dims[2], dims[1]},
+		{dims[1], dims[2], dims[0]},
+	}
+
+	turns := (o.Kind == KindHallway || o.Kind == KindStairs) && o.TurnWidth > 0
+
+	for _, f := range faces {
+		if !turns {
+			// The box slides straight through, so only its cross-section has to fit, tilted if need be.
+			if fitsRectangle(f[0], f[1], o.Width, o.Height) {
+				return true
+			}
+			continue
+		}
+
+		// Turning a corner happens in the horizontal plane: one face dimension stands vertically under the
+		// ceiling and the other face dimension is the width of the footprint that is swung around the corner.
+		for _, v := range [2][2]float64{{f[0], f[1]}, {f[1], f[0]}} {
+			vertical, footprintWidth, footprintLength := v[0], v[1], f[2]
+			if vertical > o.Height {
+				continue
+			}
+			if footprintLength <= MaxTurnLength(o.Width, o.TurnWidth, footprintWidth) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fitsRectangle reports whether a p by q rectangle fits inside an a by b rectangle, allowing it to be rotated
+// (tilted diagonally). This is Carver's condition: with p >= q and a >= b, the rectangle fits when q <= b and
+// either p <= a or b(p²+q²) >= 2pqa + (p²-q²)√(p²+q²-a²).
+func fitsRectangle(p, q, a, b float64) bool {
+	if p < q {
+		p, q = q, p
+	}
+	if a < b {
+		a, b = b, a
+	}
+
+	if q > b {
+		return false
+	}
+	if p <= a {
+		return true
+	}
+
+	d := p*p + q*q - a*a
+	if d < 0 {
+		return true
+	}
+
+	return b*(p*p+q*q) >= 2*p*q*a+(p*p-q*q)*math.Sqrt(d)
+}
+
+// MaxTurnLength returns the longest footprint of the given width that can be swung around a right-angle corner
+// from a corridor of width a into a corridor of width b. It is the minimum over θ of
+// L(θ) = (a·sinθ + b·cosθ − w) / (sinθ·cosθ). Returns 0 when the footprint is wider than either corridor.
+func MaxTurnLength(a, b, w float64) float64 {
+	if w >= a || w >= b {
+		return 0
+	}
+
+	length := func(theta float64) float64 {
+		s, c := math.Sin(theta), math.Cos(theta)
+		return (a*s + b*c - w) / (s * c)
+	}
+
+	// L(θ) tends to infinity at both ends of (0, π/2) and has a single minimum in between,
+	// so a golden-section search converges on it.
+	lo, hi := 1e-6, math.Pi/2-1e-6
+	ratio := (math.Sqrt(5) - 1) / 2
+	x1 := hi - ratio*(hi-lo)
+	x2 := lo + ratio*(hi-lo)
+	f1, f2 := length(x1), length(x2)
+
+	for i := 0; i < 100; i++ {
+		if f1 < f2 {
+			hi, x2, f2 = x2, x1, f1
+			x1 = hi - ratio*(hi-lo)
+			f1 = length(x1)
+		} else {
+			lo, x1, f1 = x1, x2, f2
+			x2 = lo + ratio*(hi-lo)
+			f2 = length(x2)
+		}
+	}
+
+	return math.Min(f1, f2)
+}
+
+// reason describes why a box cannot pass an opening.
+func reason(box Box, o Opening) string {
+	size := fmt.Sprintf("%.1f x %.1f x %.1f in.", box.Length, box.Width, box.Height)
+
+	if (o.Kind == KindHallway || o.Kind == KindStairs) && o.TurnWidth > 0 {
+		return fmt.Sprintf("A %s item cannot make the turn from %.1f in. into %.1f in. with %.1f in. of height.",
+			size, o.Width, o.TurnWidth, o.Height)
+	}
+
+	return fmt.Sprintf("A %s item does not fit through the %.1f x %.1f in. opening, even when tilted.",
+		size, o.Width, o.Height)
+}
@@ -0,0 +1,75 @@
+package clearance
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	frontDoor := Opening{Name: "Front door", Kind: KindDoor, Width: 36, Height: 80}
+	kitchenDoor := Opening{Name: "Kitchen door", Kind: KindDoor, Width: 30, Height: 80}
+	hallway := Opening{Name: "Hallway", Kind: KindHallway, Width: 36, Height: 96, TurnWidth: 36}
+
+	tests := []struct {
+		name    string
+		box     Box
+		path    []Opening
+		blocked int
+	}{
+		{
+			name:    "fridge fits upright",
+			box:     Box{Length: 30, Width: 28, Height: 70},
+			path:    []Opening{frontDoor, kitchenDoor},
+			blocked: -1,
+		},
+		{
+			name:    "wide range blocked by the kitchen door",
+			box:     Box{Length: 36, Width: 34, Height: 40},
+			path:    []Opening{frontDoor, kitchenDoor},
+			blocked: 1,
+		},
+		{
+			name:    "thin panel passes only when tilted diagonally",
+			box:     Box{Length: 100, Width: 84, Height: 4},
+			path:    []Opening{frontDoor},
+			blocked: -1,
+		},
+		{
+			name:    "long countertop cannot make the hallway turn",
+			box:     Box{Length: 120, Width: 26, Height: 2},
+			path:    []Opening{frontDoor, hallway},
+			blocked: 1,
+		},
+		{
+			name:    "dimensionless items always fit",
+			box:     Box{},
+			path:    []Opening{kitchenDoor},
+			blocked: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Check(tt.box, tt.path)
+			if report.Blocked != tt.blocked {
+				t.Errorf("expected blocked=%d, got %d (%s)", tt.blocked, report.Blocked, report.Reason)
+			}
+			if report.Fits != (tt.blocked == -1) {
+				t.Errorf("expected fits=%v, got %v", tt.blocked == -1, report.Fits)
+			}
+		})
+	}
+}
+
+func TestMaxTurnLength(t *testing.T) {
+	// The classic ladder problem: a stick (w=0) turning between two 36 in. corridors is at most 2·36·√2 long.
+	got := MaxTurnLength(36, 36, 0)
+	want := 72 * math.Sqrt2
+	if math.Abs(got-want) > 0.01 {
+		t.Errorf("expected %.2f, got %.2f", want, got)
+	}
+
+	if MaxTurnLength(36, 30, 31) != 0 {
+		t.Errorf("expected footprints wider than a corridor to never turn")
+	}
+}
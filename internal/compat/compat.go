@@ -0,0 +1,181 @@
+// Package compat checks the products on an estimate against the installation rules of the catalog. A rule links a
+// product to another product, or to any product of a subcategory, by one of three relationships: it requires it,
+// cannot be installed with it, or is recommended with it. Products can also need something at the site, such as a
+// 240V circuit, that the surveyor confirms per estimate.
+//
+// Any two items on an estimate can exclude each other, add-ons included, except items in two different options
+// since the customer only picks one of them. A requirement or recommendation is only met by an item certain to be
+// installed alongside: a required item, an item in the same option or another component of the same bundle. Add-ons
+// the customer can decline and alternatives in other options do not count.
+package compat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind is the relationship a rule describes.
+type Kind string
+
+const (
+	// KindRequires means the product cannot be installed unless the target is also on the estimate.
+	KindRequires Kind = "requires"
+	// KindExcludes means the product cannot be installed together with the target.
+	KindExcludes Kind = "excludes"
+	// KindRecommends means the target is usually sold with the product.
+	KindRecommends Kind = "recommends"
+)
+
+// Kinds lists every rule kind in the order they are offered to users.
+var Kinds = []Kind{KindRequires, KindExcludes, KindRecommends}
+
+// Rule links a product to a target. The target is either a single product (TargetProductID) or any product of a
+// subcategory (TargetSubcategory). TargetName describes the target in messages. Note is added to the message, ex.
+// "Farmhouse sinks sit on a 36 in sink base.".
+type Rule struct {
+	ProductID         int
+	Kind              Kind
+	TargetProductID   int
+	TargetSubcategory string
+	TargetName        string
+	Note              string
+}
+
+// Requirement is something a product needs at the site, ex. a 240V circuit.
+type Requirement struct {
+	ProductID     int
+	RequirementID int
+	Name          string
+}
+
+// Item is a product on an estimate. OptionID is 0 for items outside option groups and Optional is set for add-ons
+// the customer can decline. The components of a bundle are items of their own that share the bundle's LineItemID.
+type Item struct {
+	LineItemID  int
+	ProductID   int
+	Name        string
+	Subcategory string
+	OptionID    int64
+	Optional    bool
+}
+
+// Severity tells problems that stop an installation from suggestions.
+type Severity string
+
+const (
+	SeverityWarning    Severity = "warning"
+	SeveritySuggestion Severity = "suggestion"
+)
+
+// Finding is a rule or requirement that an item on the estimate does not meet.
+type Finding struct {
+	LineItemID int      `json:"line_item_id"`
+	ProductID  int      `json:"product_id"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	// RequirementID is set when the finding is a site requirement that has not been confirmed.
+	RequirementID int `json:"requirement_id,omitempty"`
+}
+
+// Check returns every rule and site requirement the items do not meet, in item order. confirmed holds the site
+// requirements the surveyor confirmed for the estimate. Each problem is reported once per product.
+func Check(items []Item, rules []Rule, requirements []Requirement, confirmed map[int]bool) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+	add := func(item Item, severity Severity, message string, requirementID int) {
+		key := fmt.Sprintf("%d|%s", item.ProductID, message)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		findings = append(findings, Finding{
+			LineItemID:    item.LineItemID,
+			ProductID:     item.ProductID,
+			Severity:      severity,
+			Message:       message,
+			RequirementID: requirementID,
+		})
+	}
+
+	for _, item := range items {
+		for _, rule := range rules {
+			if rule.ProductID != item.ProductID {
+				continue
+			}
+
+			switch rule.Kind {
+			case KindRequires:
+				if !present(items, item, rule) {
+					add(item, SeverityWarning, withNote(fmt.Sprintf("%s requires %s.", item.Name, rule.TargetName), rule.Note), 0)
+				}
+			case KindExcludes:
+				if other, ok := find(items, item, rule); ok {
+					add(item, SeverityWarning, withNote(fmt.Sprintf("%s cannot be installed with %s.", item.Name, other.Name), rule.Note), 0)
+				}
+			case KindRecommends:
+				if !present(items, item, rule) {
+					add(item, SeveritySuggestion, withNote(fmt.Sprintf("%s is usually paired with %s.", item.Name, rule.TargetName), rule.Note), 0)
+				}
+			}
+		}
+
+		for _, req := range requirements {
+			if req.ProductID == item.ProductID && !confirmed[req.RequirementID] {
+				message := fmt.Sprintf("%s needs %s at the site. Confirm it is there or add the work to the estimate.", item.Name, req.Name)
+				add(item, SeverityWarning, message, req.RequirementID)
+			}
+		}
+	}
+
+	return findings
+}
+
+// present reports whether the target of a rule is certain to be installed along with item: a required item, an
+// item in the same option or another component of the same bundle.
+func present(items []Item, item Item, rule Rule) bool {
+	for _, other := range items {
+		if other.LineItemID == item.LineItemID && other.ProductID == item.ProductID {
+			continue
+		}
+
+		certain := other.LineItemID == item.LineItemID ||
+			(other.OptionID == 0 && !other.Optional) ||
+			(item.OptionID != 0 && other.OptionID == item.OptionID)
+		if certain && matches(rule, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the first other item that is the target of a rule. Items in a different option than item are passed
+// over since only one of the two is installed.
+func find(items []Item, item Item, rule Rule) (Item, bool) {
+	for _, other := range items {
+		if other.LineItemID == item.LineItemID && other.ProductID == item.ProductID {
+			continue
+		}
+		if item.OptionID != 0 && other.OptionID != 0 && item.OptionID != other.OptionID {
+			continue
+		}
+		if matches(rule, other) {
+			return other, true
+		}
+	}
+	return Item{}, false
+}
+
+// matches reports whether an item is the target of a rule.
+func matches(rule Rule, item Item) bool {
+	if rule.TargetProductID != 0 {
+		return item.ProductID == rule.TargetProductID
+	}
+	return rule.TargetSubcategory != "" && strings.EqualFold(strings.TrimSpace(item.Subcategory), strings.TrimSpace(rule.TargetSubcategory))
+}
+
+func withNote(message, note string) string {
+	if note = strings.TrimSpace(note); note != "" {
+		return message + " " + note
+	}
+	return message
+}
@@ -0,0 +1,133 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	farmhouseSink := Item{LineItemID: 1, ProductID: 10, Name: "Farmhouse Sink", Subcategory: "Sink"}
+	sinkBase := Item{LineItemID: 2, ProductID: 20, Name: "36 in Sink Base", Subcategory: "Sink Base"}
+	induction := Item{LineItemID: 3, ProductID: 30, Name: "Induction Range", Subcategory: "Range"}
+	gasRange := Item{LineItemID: 4, ProductID: 40, Name: "Gas Range", Subcategory: "Range"}
+	dishwasher := Item{LineItemID: 5, ProductID: 50, Name: "Panel-Ready Dishwasher", Subcategory: "Dishwasher"}
+
+	rules := []Rule{
+		{ProductID: 10, Kind: KindRequires, TargetSubcategory: "sink base", TargetName: "a sink base"},
+		{ProductID: 30, Kind: KindExcludes, TargetProductID: 40, TargetName: "Gas Range"},
+		{ProductID: 50, Kind: KindRequires, TargetProductID: 60, TargetName: "Cabinet Panel", Note: "Order the panel with the cabinets."},
+		{ProductID: 30, Kind: KindRecommends, TargetProductID: 70, TargetName: "Induction Cookware Set"},
+	}
+	requirements := []Requirement{{ProductID: 30, RequirementID: 1, Name: "a 240V circuit"}}
+
+	tests := []struct {
+		name      string
+		items     []Item
+		confirmed map[int]bool
+		want      []string
+	}{
+		{
+			name:  "sink without a base",
+			items: []Item{farmhouseSink},
+			want:  []string{"Farmhouse Sink requires a sink base."},
+		},
+		{
+			name:  "sink base by subcategory satisfies the rule",
+			items: []Item{farmhouseSink, sinkBase},
+		},
+		{
+			name:      "excluded ranges",
+			items:     []Item{induction, gasRange},
+			confirmed: map[int]bool{1: true},
+			want:      []string{"Induction Range cannot be installed with Gas Range.", "Induction Range is usually paired with Induction Cookware Set."},
+		},
+		{
+			name: "ranges in different options are alternatives",
+			items: []Item{
+				{LineItemID: 3, ProductID: 30, Name: "Induction Range", OptionID: 1},
+				{LineItemID: 4, ProductID: 40, Name: "Gas Range", OptionID: 2},
+			},
+			confirmed: map[int]bool{1: true},
+			want:      []string{"Induction Range is usually paired with Induction Cookware Set."},
+		},
+		{
+			name:  "unconfirmed site requirement",
+			items: []Item{induction},
+			want:  []string{"Induction Range is usually paired with Induction Cookware Set.", "Induction Range needs a 240V circuit at the site."},
+		},
+		{
+			name:  "note is added to the message",
+			items: []Item{dishwasher},
+			want:  []string{"Panel-Ready Dishwasher requires Cabinet Panel. Order the panel with the cabinets."},
+		},
+		{
+			name:  "same product twice is reported once",
+			items: []Item{farmhouseSink, {LineItemID: 9, ProductID: 10, Name: "Farmhouse Sink", Subcategory: "Sink"}},
+			want:  []string{"Farmhouse Sink requires a sink base."},
+		},
+		{
+			name: "a sink base in another option does not satisfy the rule",
+			items: []Item{
+				{LineItemID: 1, ProductID: 10, Name: "Farmhouse Sink", Subcategory: "Sink", OptionID: 1},
+				{LineItemID: 2, ProductID: 20, Name: "36 in Sink Base", Subcategory: "Sink Base", OptionID: 2},
+			},
+			want: []string{"Farmhouse Sink requires a sink base."},
+		},
+		{
+			name: "a sink base in the same option satisfies the rule",
+			items: []Item{
+				{LineItemID: 1, ProductID: 10, Name: "Farmhouse Sink", Subcategory: "Sink", OptionID: 1},
+				{LineItemID: 2, ProductID: 20, Name: "36 in Sink Base", Subcategory: "Sink Base", OptionID: 1},
+			},
+		},
+		{
+			name: "a declinable add-on does not satisfy the rule",
+			items: []Item{
+				farmhouseSink,
+				{LineItemID: 2, ProductID: 20, Name: "36 in Sink Base", Subcategory: "Sink Base", Optional: true},
+			},
+			want: []string{"Farmhouse Sink requires a sink base."},
+		},
+		{
+			name: "components of one bundle satisfy each other",
+			items: []Item{
+				{LineItemID: 7, ProductID: 10, Name: "Farmhouse Sink", Subcategory: "Sink"},
+				{LineItemID: 7, ProductID: 20, Name: "36 in Sink Base", Subcategory: "Sink Base"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := Check(tt.items, rules, requirements, tt.confirmed)
+			if len(findings) != len(tt.want) {
+				t.Fatalf("expected %d findings, got %+v", len(tt.want), findings)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(findings[i].Message, want) {
+					t.Errorf("finding %d: expected %q, got %q", i, want, findings[i].Message)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSeverity(t *testing.T) {
+	items := []Item{{LineItemID: 1, ProductID: 1, Name: "Range"}}
+	rules := []Rule{
+		{ProductID: 1, Kind: KindRecommends, TargetProductID: 2, TargetName: "Hood"},
+		{ProductID: 1, Kind: KindRequires, TargetProductID: 3, TargetName: "Outlet"},
+	}
+	requirements := []Requirement{{ProductID: 1, RequirementID: 7, Name: "a gas line"}}
+
+	findings := Check(items, rules, requirements, nil)
+	if len(findings) != 3 {
+		t.Fatalf("expected 3 findings, got %+v", findings)
+	}
+	if findings[0].Severity != SeveritySuggestion || findings[1].Severity != SeverityWarning {
+		t.Errorf("unexpected severities: %+v", findings)
+	}
+	if findings[2].RequirementID != 7 || findings[2].LineItemID != 1 {
+		t.Errorf("expected the site requirement finding to name the requirement and line item, got %+v", findings[2])
+	}
+}
@@ -0,0 +1,122 @@
+// Package imaging makes the resized copies of uploaded product photos. Photos are decoded from JPEG, PNG or GIF,
+// scaled down with a box filter and re-encoded as JPEG so every variant is small and displays the same everywhere.
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Register the formats accepted for upload.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels is the largest photo, in pixels, that will be decoded. It keeps a single upload from using
+// gigabytes of memory.
+const MaxPixels = 40_000_000
+
+var (
+	// ErrUnsupportedFormat is returned when an upload is not a JPEG, PNG or GIF image.
+	ErrUnsupportedFormat = errors.New("imaging: unsupported image format")
+
+	// ErrTooLarge is returned when an upload has more than MaxPixels pixels.
+	ErrTooLarge = errors.New("imaging: image too large")
+)
+
+// Variant is a resized copy of a photo. Photos are scaled to fit within MaxWidth by MaxHeight and never enlarged.
+type Variant struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Variants lists every copy made of an uploaded photo.
+var Variants = []Variant{
+	{Name: "thumb", MaxWidth: 160, MaxHeight: 160},
+	{Name: "medium", MaxWidth: 480, MaxHeight: 480},
+	{Name: "full", MaxWidth: 1600, MaxHeight: 1600},
+}
+
+// IsVariant reports whether name is one of Variants.
+func IsVariant(name string) bool {
+	for _, v := range Variants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode reads an uploaded photo. Transparent areas are filled with white since the variants are JPEGs.
+func Decode(r io.ReadSeeker) (*image.RGBA, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Over)
+
+	return img, nil
+}
+
+// Fit scales img down to fit within maxWidth by maxHeight, keeping its aspect ratio. Images that already fit are
+// returned unchanged.
+func Fit(img *image.RGBA, maxWidth, maxHeight int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+
+	dw, dh := maxWidth, h*maxWidth/w
+	if dh > maxHeight {
+		dw, dh = w*maxHeight/h, maxHeight
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	// Each destination pixel is the average of the source pixels it covers.
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := range dw {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := img.Pix[sy*img.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// Encode writes img as a JPEG variant.
+func Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+}
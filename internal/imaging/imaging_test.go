@@ -0,0 +1,66 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestDecodeAndFit(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			if x < 200 {
+				src.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got := img.RGBAAt(300, 100); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("expected transparent pixels on white, got %v", got)
+	}
+
+	thumb := Fit(img, 160, 160)
+	if b := thumb.Bounds(); b.Dx() != 160 || b.Dy() != 80 {
+		t.Fatalf("expected a 160x80 thumbnail, got %dx%d", b.Dx(), b.Dy())
+	}
+	if got := thumb.RGBAAt(40, 40); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the left half to stay red, got %v", got)
+	}
+
+	if Fit(img, 1600, 1600) != img {
+		t.Errorf("expected an image that fits to be returned unchanged")
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, thumb); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if _, err := jpeg.DecodeConfig(&buf); err != nil {
+		t.Errorf("expected a JPEG variant: %v", err)
+	}
+}
+
+func TestDecodeRejectsOtherFiles(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("%PDF-1.4 not an image"))); err != ErrUnsupportedFormat {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestIsVariant(t *testing.T) {
+	if !IsVariant("thumb") || IsVariant("../signatures/1.png") {
+		t.Errorf("IsVariant accepted the wrong names")
+	}
+}
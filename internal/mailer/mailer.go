@@ -19,6 +19,17 @@ type InvoiceLinkData struct {
 	ExpiresAt      string
 }
 
+// EstimateResponseData fills in the email telling the owner of an estimate that the customer declined it or asked
+// for changes.
+type EstimateResponseData struct {
+	OwnerName      string
+	CustomerName   string
+	EstimateNumber int
+	Response       string
+	Reason         string
+	EstimateURL    string
+}
+
 type Mailer struct {
 	dialer    *gomail.Dialer
 	fromEmail string
@@ -75,3 +86,51 @@ func (m *Mailer) SendInvoiceLink(to string, data InvoiceLinkData) error {
 
 	return m.dialer.DialAndSend(msg)
 }
+
+// SendInvoiceReminder reminds a customer that their invoice agreement is waiting to be signed, resending the link.
+func (m *Mailer) SendInvoiceReminder(to string, data InvoiceLinkData) error {
+	log.Println("SendInvoiceReminder called for:", to)
+
+	var body bytes.Buffer
+	t, err := template.ParseFiles("./ui/html/mail/customerInvoiceReminder.tmpl")
+	if err != nil {
+		return err
+	}
+	err = t.Execute(&body, data)
+	if err != nil {
+		return err
+	}
+
+	msg := gomail.NewMessage()
+
+	msg.SetHeader("From", os.Getenv("SMTP_USER"))
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", fmt.Sprintf("Reminder: EzKitchen Invoice Agreement #%d", data.EstimateNumber))
+	msg.SetBody("text/html", body.String())
+
+	return m.dialer.DialAndSend(msg)
+}
+
+// SendEstimateResponse tells the owner of an estimate how the customer responded instead of signing.
+func (m *Mailer) SendEstimateResponse(to string, data EstimateResponseData) error {
+	log.Println("SendEstimateResponse called for:", to)
+
+	var body bytes.Buffer
+	t, err := template.ParseFiles("./ui/html/mail/estimateResponse.tmpl")
+	if err != nil {
+		return err
+	}
+	err = t.Execute(&body, data)
+	if err != nil {
+		return err
+	}
+
+	msg := gomail.NewMessage()
+
+	msg.SetHeader("From", os.Getenv("SMTP_USER"))
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", fmt.Sprintf("EzKitchen Estimate #%d: Customer %s", data.EstimateNumber, data.Response))
+	msg.SetBody("text/html", body.String())
+
+	return m.dialer.DialAndSend(msg)
+}
@@ -4,3 +4,59 @@ import "errors"
 
 var ErrNoRecord = errors.New("models: no matching record found")
 var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrEstimateLocked is returned when line items are changed on an estimate that is no longer a Draft.
+var ErrEstimateLocked = errors.New("models: estimate is no longer a draft")
+
+// ErrForbidden is returned when a user tries to change an estimate they do not own and that was not shared with them.
+var ErrForbidden = errors.New("models: user may not access this estimate")
+
+// ErrProductInUse is returned when deleting a product that is still a line item on an estimate or part of a bundle.
+var ErrProductInUse = errors.New("models: product is used on an estimate")
+
+// ErrInvalidImport is returned when a product import file cannot be read or has rows that failed validation.
+var ErrInvalidImport = errors.New("models: invalid product import")
+
+// ErrImportChanged is returned when a product import no longer does what its preview showed, because either the
+// file or the catalog changed in between.
+var ErrImportChanged = errors.New("models: product import changed since preview")
+
+// ErrInvalidReplacement is returned when a discontinued product is given a replacement that is itself, missing
+// or also discontinued, or when a line item is switched to a product that is missing or discontinued.
+var ErrInvalidReplacement = errors.New("models: invalid replacement product")
+
+// ErrDuplicateSKU is returned when a product is saved with a SKU another product already has.
+var ErrDuplicateSKU = errors.New("models: duplicate SKU")
+
+// ErrDuplicateVendor is returned when a vendor is saved with the name of another vendor.
+var ErrDuplicateVendor = errors.New("models: duplicate vendor name")
+
+// ErrDuplicateLocation is returned when an inventory location is added with the name of another location.
+var ErrDuplicateLocation = errors.New("models: duplicate inventory location")
+
+// ErrEstimateNotSigned is returned when purchase orders are generated for an estimate the customer has not signed.
+var ErrEstimateNotSigned = errors.New("models: estimate has not been signed")
+
+// ErrPurchaseOrderStatus is returned when a purchase order cannot make a change in its current status, ex. receiving
+// an order that was never sent.
+var ErrPurchaseOrderStatus = errors.New("models: purchase order status does not allow this change")
+
+// ErrInvalidPriceDate is returned when a price is scheduled for today or a day that has passed. Prices for today
+// are changed by editing the product.
+var ErrInvalidPriceDate = errors.New("models: scheduled price must start after today")
+
+// ErrDuplicateRule is returned when a product already has the same rule for the same target.
+var ErrDuplicateRule = errors.New("models: duplicate product rule")
+
+// ErrInvalidRule is returned when a rule targets the product it belongs to.
+var ErrInvalidRule = errors.New("models: invalid product rule")
+
+// ErrInvalidBundle is returned when a bundle component would put a bundle inside a bundle, or a bundle inside itself.
+var ErrInvalidBundle = errors.New("models: bundles cannot contain bundles")
+
+// ErrDuplicatePriceTier is returned when a product already has a price break at the same quantity or order value.
+var ErrDuplicatePriceTier = errors.New("models: duplicate price tier")
+
+// ErrAgreementRecorded is returned when the hash of a signed agreement is recorded a second time. The hash is
+// evidence of what the customer signed, so it is never replaced.
+var ErrAgreementRecorded = errors.New("models: agreement hash already recorded")
@@ -17,6 +17,7 @@ import (
 // StatusAwaitingPayment	-	2
 // StatusInProgress 		- 	3
 // StatusCompleted			- 	4
+// StatusDeclined			- 	5
 type EstimateStatus int
 
 const (
@@ -27,6 +28,8 @@ const (
 	StatusInProgress
 	// StatusCompleted - all things complete job is done.
 	StatusCompleted
+	// StatusDeclined - the customer declined the estimate instead of signing it.
+	StatusDeclined
 )
 
 func (s EstimateStatus) String() string {
@@ -39,13 +42,15 @@ func (s EstimateStatus) String() string {
 		return "In Progress"
 	case StatusCompleted:
 		return "Completed"
+	case StatusDeclined:
+		return "Declined"
 	default:
 		return "Unknown"
 	}
 }
 
 func (s EstimateStatus) Next() EstimateStatus {
-	if s != StatusCompleted {
+	if s != StatusCompleted && s != StatusDeclined {
 		s++
 		return s
 	}
@@ -70,6 +75,9 @@ type Estimate struct {
 	State              string
 	Zip                string
 	SignatureObjectKey sql.NullString
+	SignedAt           sql.NullTime
+	// AgreementObjectKey is the PDF agreement generated once the customer signed.
+	AgreementObjectKey sql.NullString
 }
 
 type EstimateTotals struct {
@@ -131,12 +139,14 @@ func (m *EstimateModel) Get(id int) (Estimate, error) {
 
 	stmt := `SELECT estimate_id, customer_id, created_by, status, created_at,
        	kitchen_length_inch, kitchen_width_inch, kitchen_height_inch,
-    	door_width_inch, door_height_inch, street, city, state, zip, signature_object_key 
+    	door_width_inch, door_height_inch, street, city, state, zip, signature_object_key,
+		signed_at, agreement_object_key
 	   	FROM estimates WHERE estimate_id=$1;`
 
 	var statusInt int
 	row := m.DB.QueryRow(stmt, id)
-	err := row.Scan(&estimate.EstimateID, &estimate.CustomerID, &estimate.CreatedBy, &statusInt, &estimate.CreatedAt, &estimate.KitchenLengthInch, &estimate.KitchenWidthInch, &estimate.KitchenHeightInch, &estimate.DoorWidthInch, &estimate.DoorHeightInch, &estimate.Street, &estimate.City, &estimate.State, &estimate.Zip, &estimate.SignatureObjectKey)
+	err := row.Scan(&estimate.EstimateID, &estimate.CustomerID, &estimate.CreatedBy, &statusInt, &estimate.CreatedAt, &estimate.KitchenLengthInch, &estimate.KitchenWidthInch, &estimate.KitchenHeightInch, &estimate.DoorWidthInch, &estimate.DoorHeightInch, &estimate.Street, &estimate.City, &estimate.State, &estimate.Zip, &estimate.SignatureObjectKey,
+		&estimate.SignedAt, &estimate.AgreementObjectKey)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -191,7 +201,7 @@ func (m *EstimateModel) GetAll() ([]EstimateListItem, error) {
 	return estimates, nil
 }
 
-// GetSurveyorsEstimates retrieves up to 10 estimates created by a specific surveyor (CreatedBy field).
+// GetSurveyorsEstimates retrieves the estimates created by a specific surveyor (CreatedBy field) or shared with them.
 // Returns ErrNoRecord or an empty slice if no estimates are found.
 func (m *EstimateModel) GetSurveyorsEstimates(surveyorID int) ([]EstimateListItem, error) {
 	stmt := `SELECT 
@@ -203,7 +213,8 @@ func (m *EstimateModel) GetSurveyorsEstimates(surveyorID int) ([]EstimateListIte
 	e.state
 	FROM estimates e
 	JOIN users c on c.user_id = e.customer_id 
-	WHERE created_by = $1 
+	WHERE created_by = $1
+	OR e.estimate_id IN (SELECT estimate_id FROM estimate_shares WHERE user_id = $1)
 	ORDER BY e.created_at DESC`
 
 	rows, err := m.DB.Query(stmt, surveyorID)
@@ -270,17 +281,27 @@ func (m *EstimateModel) SetSignatureKey(id int, key string) error {
 }
 
 func (m *EstimateModel) SetSignatureKeyTx(tx *sql.Tx, id int, key string) error {
-	return m.setSignatureKey(m.DB, id, key)
+	return m.setSignatureKey(tx, id, key)
 }
 
+// setSignatureKey records the customer's signature and stamps when they signed.
 func (m *EstimateModel) setSignatureKey(exec executor, id int, key string) error {
-	stmt := `UPDATE estimates set signature_object_key=$1 WHERE estimate_id=$2`
+	stmt := `UPDATE estimates set signature_object_key=$1, signed_at=NOW() WHERE estimate_id=$2`
 
 	_, err := exec.Exec(stmt, key, id)
 	return err
 
 }
 
+// SetAgreementKeyTx records where the PDF agreement of a signed estimate is stored, in the transaction that
+// records the signature.
+func (m *EstimateModel) SetAgreementKeyTx(tx *sql.Tx, id int, key string) error {
+	stmt := `UPDATE estimates SET agreement_object_key=$1 WHERE estimate_id=$2`
+
+	_, err := tx.Exec(stmt, key, id)
+	return err
+}
+
 func (m *EstimateModel) UpdateStatus(id int, status EstimateStatus) error {
 	return m.updateStatus(m.DB, id, status)
 }
@@ -318,13 +339,22 @@ func (m *EstimateModel) Delete(id int) error {
 }
 
 // CalculateEstimateTotals computes the subtotal, labor, sales tax, and total for a given set of EstimateProducts.
-// Labor cost logic is based on product categories, and Michigan’s 6% sales tax is applied.
+// Price tiers are applied for the value of the set at list prices, so the same line item can be cheaper on an option
+// or once add-ons are accepted. Labor cost logic is based on product categories, and Michigan’s 6% sales tax is
+// applied to taxable line items.
 // Returns an EstimateTotals struct with all calculated fields.
 func (m *EstimateModel) CalculateEstimateTotals(estimateProducts []EstimateProduct) EstimateTotals {
+	estimateProducts = append([]EstimateProduct(nil), estimateProducts...)
+	ApplyPriceTiers(estimateProducts, ListValue(estimateProducts))
 
 	var totals EstimateTotals
+	var taxableSubtotal int
 	for i := 0; i < len(estimateProducts); i++ {
-		totals.Subtotal += estimateProducts[i].Product.UnitPrice * estimateProducts[i].EstimateItem.Quantity
+		lineTotal := estimateProducts[i].LineTotal()
+		totals.Subtotal += lineTotal
+		if estimateProducts[i].EstimateItem.Taxable {
+			taxableSubtotal += lineTotal
+		}
 
 		switch estimateProducts[i].Product.Category {
 		case "Appliances":
@@ -341,7 +371,7 @@ func (m *EstimateModel) CalculateEstimateTotals(estimateProducts []EstimateProdu
 	}
 
 	totals.LaborTotal += 30000
-	totals.SalesTax = totals.Subtotal / 6
+	totals.SalesTax = taxableSubtotal / 6
 	totals.EstimateTotal = totals.Subtotal + totals.SalesTax + totals.LaborTotal
 
 	return totals
@@ -2,7 +2,11 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
 )
 
 // EstimateItem represents a single product entry within an estimate.
@@ -11,12 +15,62 @@ type EstimateItem struct {
 	EstimateID int
 	ProductID  int
 	Quantity   int
+	OptionID   sql.NullInt64 // set when the item is an alternate inside an option group.
+	IsOptional bool          // optional add-ons are only kept if the customer accepts them when signing.
+
+	// Custom line items (ex. drywall repair, moving a gas line) are not in the product catalog. They have no
+	// ProductID and carry their own description, unit price (in cents) and category for labor rules instead.
+	CustomDescription string
+	CustomUnitPrice   int
+	CustomCategory    string
+	// Taxable is always true for catalog products; custom line items may be tax exempt.
+	Taxable bool
 }
 
-// EstimateProduct combines an EstimateItem with its associated Product data.
+// IsCustom reports whether the line item is an ad-hoc item rather than a catalog product.
+func (i EstimateItem) IsCustom() bool {
+	return i.ProductID == 0
+}
+
+// insertArgs returns the column values used to insert the line item into an estimate. Catalog products store only
+// their ProductID while custom line items store their own description, price and category.
+func (i EstimateItem) insertArgs(estimateID int) []any {
+	if !i.IsCustom() {
+		return []any{estimateID, i.ProductID, i.Quantity, i.OptionID, i.IsOptional, nil, nil, nil, true}
+	}
+	return []any{estimateID, nil, i.Quantity, i.OptionID, i.IsOptional, i.CustomDescription, i.CustomUnitPrice, i.CustomCategory, i.Taxable}
+}
+
+const insertEstimateItemStmt = `INSERT INTO estimate_items
+	(estimate_id, product_id, quantity, option_id, is_optional, custom_description, custom_unit_price, custom_category, taxable)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING line_item_id`
+
+// EstimateProduct combines an EstimateItem with its associated Product data. Components lists what a bundle
+// product is made of and is empty for every other line item. PriceTiers are the price breaks of the product and
+// AppliedTier is the one the line item sells at, if any; see ApplyPriceTiers.
 type EstimateProduct struct {
 	Product      Product
 	EstimateItem EstimateItem
+	Components   []BundleComponent
+	PriceTiers   []PriceTier
+	AppliedTier  *PriceTier
+}
+
+// ItemOperationKind is the kind of change a batch ItemOperation makes to an estimate's line items.
+type ItemOperationKind string
+
+const (
+	ItemOperationAdd    ItemOperationKind = "add"
+	ItemOperationUpdate ItemOperationKind = "update"
+	ItemOperationDelete ItemOperationKind = "delete"
+)
+
+// ItemOperation is a single add, update or delete within a batch of line item changes.
+// Add uses ProductID, Quantity, OptionID and IsOptional. Update uses LineItemID, Quantity and IsOptional.
+// Delete only uses LineItemID.
+type ItemOperation struct {
+	Kind ItemOperationKind
+	Item EstimateItem
 }
 
 // EstimateItemModel wraps database operations for estimate_items.
@@ -25,27 +79,89 @@ type EstimateItemModel struct {
 }
 
 // Insert adds a new EstimateItem to the database.
-// The provided EstimateItem must have valid EstimateID and ProductID fields.
-// Returns an error if the insert operation or Scan fails.
+// The provided EstimateItem must have a valid EstimateID and either a ProductID or, for custom line items,
+// a CustomDescription and CustomUnitPrice.
+// Returns ErrNoRecord if the estimate does not exist and ErrEstimateLocked if it is no longer a Draft.
 func (m *EstimateItemModel) Insert(estimateItem *EstimateItem) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	stmt := `INSERT INTO estimate_items (estimate_id, product_id, quantity)
-	VALUES ($1,$2,$3) RETURNING line_item_id`
+	err = lockDraft(tx, estimateItem.EstimateID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(insertEstimateItemStmt, estimateItem.insertArgs(estimateItem.EstimateID)...).Scan(&estimateItem.LineItemID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	err := m.DB.QueryRow(stmt, estimateItem.EstimateID, estimateItem.ProductID, estimateItem.Quantity).Scan(&estimateItem.LineItemID)
+// lockDraft locks an estimate until tx ends so it cannot leave Draft while its line items change. Line items can
+// only change on a Draft: returns ErrNoRecord if the estimate does not exist and ErrEstimateLocked if it is no
+// longer a Draft.
+func lockDraft(tx *sql.Tx, estimateID int) error {
+	var status EstimateStatus
+	err := tx.QueryRow(`SELECT status FROM estimates WHERE estimate_id=$1 FOR SHARE`, estimateID).Scan(&status)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
 		return err
 	}
+	if status != StatusDraft {
+		return ErrEstimateLocked
+	}
+
 	return nil
+}
+
+// changeDraftItem runs a statement that changes the line item with the ID in its first argument, with the line
+// item's estimate locked as a Draft. Returns ErrNoRecord if there is no such line item and ErrEstimateLocked if the
+// estimate is no longer a Draft.
+func (m *EstimateItemModel) changeDraftItem(stmt string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	var estimateID int
+	err = tx.QueryRow(`SELECT estimate_id FROM estimate_items WHERE line_item_id=$1`, args[0]).Scan(&estimateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	err = lockDraft(tx, estimateID)
+	if err != nil {
+		return err
+	}
+
+	err = execAffectingOne(tx, stmt, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // GetByLineItemID retrieves an EstimateItem by its LineItemID.
 // Returns the EstimateItem object, or ErrNoRecord if no matching record exists.
 func (m *EstimateItemModel) GetByLineItemID(id int) (EstimateItem, error) {
 	var estimateItem EstimateItem
-	stmt := `SELECT line_item_id, estimate_id, product_id, quantity FROM estimate_items WHERE line_item_id=$1`
-	err := m.DB.QueryRow(stmt, id).Scan(&estimateItem.LineItemID, &estimateItem.EstimateID, &estimateItem.ProductID, &estimateItem.Quantity)
+	stmt := `SELECT line_item_id, estimate_id, COALESCE(product_id, 0), quantity, option_id, is_optional,
+	COALESCE(custom_description, ''), COALESCE(custom_unit_price, 0), COALESCE(custom_category, ''), taxable
+	FROM estimate_items WHERE line_item_id=$1`
+	err := m.DB.QueryRow(stmt, id).Scan(&estimateItem.LineItemID, &estimateItem.EstimateID, &estimateItem.ProductID, &estimateItem.Quantity, &estimateItem.OptionID, &estimateItem.IsOptional,
+		&estimateItem.CustomDescription, &estimateItem.CustomUnitPrice, &estimateItem.CustomCategory, &estimateItem.Taxable)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return EstimateItem{}, ErrNoRecord
@@ -72,26 +188,44 @@ func (m *EstimateItemModel) GetEstimateIDByLineItemID(lineItemID int) (int, erro
 }
 
 // GetByEstimateID returns all EstimateItems that belong to the specified EstimateID.
-// Each returned record includes associated Product information.
+// Each returned record includes associated Product information, including whether it has been discontinued.
+// Custom line items have their description, category and unit price filled into the Product so they render and
+// total like catalog products. Once an estimate has left Draft its products are priced from the snapshot taken by
+// SnapshotPricesTx, and its bundles made of the components frozen by SnapshotBundlesTx, rather than the catalog.
 // Returns a slice of EstimateProduct or an error.
 func (m *EstimateItemModel) GetByEstimateID(estimateID int) ([]EstimateProduct, error) {
 	var estimateProducts []EstimateProduct
-	stmt := `SELECT ei.line_item_id, ei.product_id, ei.quantity, p.name, p.description, p.category, p.subcategory, p.color,  p.unit_price FROM estimate_items ei INNER JOIN products p on ei.product_id = p.product_id WHERE estimate_id=$1`
+	stmt := `SELECT ei.line_item_id, COALESCE(ei.product_id, 0), ei.quantity, ei.option_id, ei.is_optional,
+	COALESCE(ei.custom_description, ''), COALESCE(ei.custom_unit_price, 0), COALESCE(ei.custom_category, ''), ei.taxable,
+	COALESCE(p.name, ei.custom_description), COALESCE(p.description, ''), COALESCE(p.category, ei.custom_category), COALESCE(p.subcategory, ''), COALESCE(p.color, ''), COALESCE(ei.snapshot_unit_price, p.unit_price, ei.custom_unit_price),
+	COALESCE(p.length, 0), COALESCE(p.width, 0), COALESCE(p.height, 0), p.discontinued_at IS NOT NULL,
+	COALESCE(p.sku, ''), COALESCE(p.vendor_cost, 0), COALESCE(p.product_id, 0), COALESCE(p.image_key, ''),
+	ei.snapshot_price_tiers
+	FROM estimate_items ei LEFT JOIN products p on ei.product_id = p.product_id WHERE estimate_id=$1 ORDER BY ei.line_item_id`
 	rows, err := m.DB.Query(stmt, estimateID)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
+
+	// snapshotTiers holds the price tiers frozen for each line item, nil while the estimate is a Draft.
+	var snapshotTiers [][]byte
 	for rows.Next() {
 		var estimateProduct EstimateProduct
+		var tiers []byte
 
-		err := rows.Scan(&estimateProduct.EstimateItem.LineItemID, &estimateProduct.EstimateItem.ProductID, &estimateProduct.EstimateItem.Quantity, &estimateProduct.Product.Name, &estimateProduct.Product.Description, &estimateProduct.Product.Category, &estimateProduct.Product.Subcategory, &estimateProduct.Product.Color, &estimateProduct.Product.UnitPrice)
+		err := rows.Scan(&estimateProduct.EstimateItem.LineItemID, &estimateProduct.EstimateItem.ProductID, &estimateProduct.EstimateItem.Quantity, &estimateProduct.EstimateItem.OptionID, &estimateProduct.EstimateItem.IsOptional,
+			&estimateProduct.EstimateItem.CustomDescription, &estimateProduct.EstimateItem.CustomUnitPrice, &estimateProduct.EstimateItem.CustomCategory, &estimateProduct.EstimateItem.Taxable, &estimateProduct.Product.Name, &estimateProduct.Product.Description, &estimateProduct.Product.Category, &estimateProduct.Product.Subcategory, &estimateProduct.Product.Color, &estimateProduct.Product.UnitPrice,
+			&estimateProduct.Product.Length, &estimateProduct.Product.Width, &estimateProduct.Product.Height, &estimateProduct.Product.Discontinued,
+			&estimateProduct.Product.SKU, &estimateProduct.Product.VendorCost, &estimateProduct.Product.ProductID, &estimateProduct.Product.ImageKey,
+			&tiers)
 		if err != nil {
 			return nil, err
 		}
 
 		estimateProducts = append(estimateProducts, estimateProduct)
+		snapshotTiers = append(snapshotTiers, tiers)
 
 	}
 
@@ -99,34 +233,203 @@ func (m *EstimateItemModel) GetByEstimateID(estimateID int) ([]EstimateProduct,
 		return nil, err
 	}
 
+	var productIDs []int
+	for _, ep := range estimateProducts {
+		if !ep.EstimateItem.IsCustom() {
+			productIDs = append(productIDs, ep.EstimateItem.ProductID)
+		}
+	}
+	if len(productIDs) == 0 {
+		return estimateProducts, nil
+	}
+
+	components, err := getBundleComponents(m.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	frozen, err := getFrozenComponents(m.DB, estimateID)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers, err := getPriceTiers(m.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Order value tiers are applied for the items every version of the estimate includes. The totals of each
+	// option and of the add-ons a customer accepts are worked out again by CalculateEstimateTotals.
+	var base []EstimateProduct
+	for i := range estimateProducts {
+		estimateProducts[i].Components = components[estimateProducts[i].EstimateItem.ProductID]
+		estimateProducts[i].PriceTiers = tiers[estimateProducts[i].EstimateItem.ProductID]
+		if snapshotTiers[i] != nil {
+			estimateProducts[i].Components = frozen[estimateProducts[i].EstimateItem.LineItemID]
+			estimateProducts[i].PriceTiers = nil
+			err = json.Unmarshal(snapshotTiers[i], &estimateProducts[i].PriceTiers)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !estimateProducts[i].EstimateItem.IsOptional && !estimateProducts[i].EstimateItem.OptionID.Valid {
+			base = append(base, estimateProducts[i])
+		}
+	}
+	ApplyPriceTiers(estimateProducts, ListValue(base))
+
 	return estimateProducts, nil
 }
 
+// SnapshotPricesTx freezes the unit price and price tiers of every catalog product on an estimate, so later catalog
+// price changes leave it alone. It is run as the estimate leaves Draft.
+func (m *EstimateItemModel) SnapshotPricesTx(tx *sql.Tx, estimateID int) error {
+	stmt := `UPDATE estimate_items ei
+	SET snapshot_unit_price = p.unit_price,
+		snapshot_price_tiers = COALESCE((
+			SELECT jsonb_agg(jsonb_build_object('TierID', pt.tier_id, 'ProductID', pt.product_id, 'Basis', pt.basis,
+				'Threshold', pt.threshold, 'UnitPrice', pt.unit_price) ORDER BY pt.basis, pt.threshold)
+			FROM product_price_tiers pt WHERE pt.product_id = p.product_id), '[]'::jsonb)
+	FROM products p
+	WHERE p.product_id = ei.product_id AND ei.estimate_id = $1`
+
+	_, err := tx.Exec(stmt, estimateID)
+	return err
+}
+
+// ClearPriceSnapshotTx prices an estimate from the catalog again. It is run when an estimate goes back to Draft.
+func (m *EstimateItemModel) ClearPriceSnapshotTx(tx *sql.Tx, estimateID int) error {
+	stmt := `UPDATE estimate_items SET snapshot_unit_price = NULL, snapshot_price_tiers = NULL WHERE estimate_id = $1`
+
+	_, err := tx.Exec(stmt, estimateID)
+	return err
+}
+
+// SnapshotBundlesTx freezes the components of every bundle on an estimate, so later edits to a bundle leave what
+// the estimate delivers, reserves and orders alone. It is run as the estimate leaves Draft.
+func (m *EstimateItemModel) SnapshotBundlesTx(tx *sql.Tx, estimateID int) error {
+	stmt := `INSERT INTO estimate_item_components (line_item_id, component_id, quantity)
+	SELECT ei.line_item_id, bc.component_id, bc.quantity
+	FROM estimate_items ei
+	JOIN product_bundle_components bc ON bc.bundle_id = ei.product_id
+	WHERE ei.estimate_id = $1
+	ON CONFLICT DO NOTHING`
+
+	_, err := tx.Exec(stmt, estimateID)
+	return err
+}
+
+// ClearBundleSnapshotTx makes the bundles on an estimate follow the catalog again. It is run when an estimate goes
+// back to Draft.
+func (m *EstimateItemModel) ClearBundleSnapshotTx(tx *sql.Tx, estimateID int) error {
+	stmt := `DELETE FROM estimate_item_components ec USING estimate_items ei
+	WHERE ei.line_item_id = ec.line_item_id AND ei.estimate_id = $1`
+
+	_, err := tx.Exec(stmt, estimateID)
+	return err
+}
+
 // Update modifies the quantity of an existing EstimateItem.
 // The provided EstimateItem must include a valid LineItemID.
-// Returns ErrNoRecord if no record was updated.
+// Returns ErrNoRecord if no record was updated and ErrEstimateLocked if the estimate is no longer a Draft.
 func (m *EstimateItemModel) Update(estimateItem EstimateItem) error {
 	stmt := `UPDATE estimate_items SET quantity=$2 WHERE line_item_id=$1`
-	result, err := m.DB.Exec(stmt, estimateItem.LineItemID, estimateItem.Quantity)
+
+	return m.changeDraftItem(stmt, estimateItem.LineItemID, estimateItem.Quantity)
+}
+
+// SetOptional marks an EstimateItem as an optional add-on (or back to a required item).
+// Returns ErrNoRecord if no record was updated and ErrEstimateLocked if the estimate is no longer a Draft.
+func (m *EstimateItemModel) SetOptional(lineItemID int, optional bool) error {
+	stmt := `UPDATE estimate_items SET is_optional=$2 WHERE line_item_id=$1`
+
+	return m.changeDraftItem(stmt, lineItemID, optional)
+}
+
+// AcceptAddonsTx keeps only the optional add-ons the customer accepted when signing. Accepted add-ons become
+// regular line items and every declined add-on is removed, so the signed estimate only contains what was agreed to.
+func (m *EstimateItemModel) AcceptAddonsTx(tx *sql.Tx, estimateID int, accepted []int) error {
+	stmt := `DELETE FROM estimate_items
+	WHERE estimate_id=$1 AND is_optional AND NOT (line_item_id = ANY($2))`
+
+	_, err := tx.Exec(stmt, estimateID, pq.Array(accepted))
 	if err != nil {
 		return err
 	}
-	rows, err := result.RowsAffected()
+
+	stmt = `UPDATE estimate_items SET is_optional=FALSE WHERE estimate_id=$1 AND line_item_id = ANY($2)`
+
+	_, err = tx.Exec(stmt, estimateID, pq.Array(accepted))
+	return err
+}
+
+// ApplyBatch applies a list of line item operations to an estimate in a single transaction. The estimate row is
+// locked for the duration so it cannot leave Draft while the batch is applied. Either every operation is applied or
+// none are. Returns the line item ID affected by each operation (the new ID for adds), ErrEstimateLocked if the
+// estimate is not a Draft, and ErrNoRecord if an update or delete targets a line item outside the estimate.
+func (m *EstimateItemModel) ApplyBatch(estimateID int, ops []ItemOperation) ([]int, error) {
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if rows == 0 {
-		return ErrNoRecord
+	defer tx.Rollback()
+
+	var status EstimateStatus
+	err = tx.QueryRow(`SELECT status FROM estimates WHERE estimate_id=$1 FOR UPDATE`, estimateID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+	if status != StatusDraft {
+		return nil, ErrEstimateLocked
 	}
 
-	return nil
+	lineItemIDs := make([]int, len(ops))
+
+	for i, op := range ops {
+		switch op.Kind {
+		case ItemOperationAdd:
+			err = tx.QueryRow(insertEstimateItemStmt, op.Item.insertArgs(estimateID)...).Scan(&lineItemIDs[i])
+			if err != nil {
+				return nil, err
+			}
+
+		case ItemOperationUpdate:
+			stmt := `UPDATE estimate_items SET quantity=$3, is_optional=$4 WHERE line_item_id=$1 AND estimate_id=$2`
+
+			err = execAffectingOne(tx, stmt, op.Item.LineItemID, estimateID, op.Item.Quantity, op.Item.IsOptional)
+			if err != nil {
+				return nil, err
+			}
+			lineItemIDs[i] = op.Item.LineItemID
+
+		case ItemOperationDelete:
+			stmt := `DELETE FROM estimate_items WHERE line_item_id=$1 AND estimate_id=$2`
+
+			err = execAffectingOne(tx, stmt, op.Item.LineItemID, estimateID)
+			if err != nil {
+				return nil, err
+			}
+			lineItemIDs[i] = op.Item.LineItemID
+
+		default:
+			return nil, fmt.Errorf("unknown line item operation %q", op.Kind)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return lineItemIDs, nil
 }
 
-// Delete removes an EstimateItem by its LineItemID.
-// Returns ErrNoRecord if the record does not exist.
-func (m *EstimateItemModel) Delete(id int) error {
-	stmt := `DELETE FROM estimate_items WHERE line_item_id=$1`
-	result, err := m.DB.Exec(stmt, id)
+// execAffectingOne runs a statement that must change exactly one row and returns ErrNoRecord when it changes none.
+func execAffectingOne(exec executor, stmt string, args ...any) error {
+	result, err := exec.Exec(stmt, args...)
 	if err != nil {
 		return err
 	}
@@ -141,3 +444,24 @@ func (m *EstimateItemModel) Delete(id int) error {
 
 	return nil
 }
+
+// SplitOptionalProducts separates required line items from optional add-ons.
+func SplitOptionalProducts(estimateProducts []EstimateProduct) (required, optional []EstimateProduct) {
+	for _, ep := range estimateProducts {
+		if ep.EstimateItem.IsOptional {
+			optional = append(optional, ep)
+			continue
+		}
+		required = append(required, ep)
+	}
+
+	return required, optional
+}
+
+// Delete removes an EstimateItem by its LineItemID.
+// Returns ErrNoRecord if the record does not exist and ErrEstimateLocked if the estimate is no longer a Draft.
+func (m *EstimateItemModel) Delete(id int) error {
+	stmt := `DELETE FROM estimate_items WHERE line_item_id=$1`
+
+	return m.changeDraftItem(stmt, id)
+}
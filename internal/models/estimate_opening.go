@@ -0,0 +1,101 @@
+// models/estimate_opening.go contains the openings (doors, hallways, stairs) along an estimate's delivery path.
+// They are listed in the order items are carried through them, from the street to the kitchen.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// EstimateOpening is one opening along the delivery path of an estimate. Measurements are in inches.
+// Kind is one of the clearance package's opening kinds ("door", "hallway" or "stairs").
+type EstimateOpening struct {
+	OpeningID     int
+	EstimateID    int
+	Position      int
+	Name          string
+	Kind          string
+	WidthInch     float32
+	HeightInch    float32
+	TurnWidthInch float32
+}
+
+// EstimateOpeningModel wraps database operations for estimate_openings.
+type EstimateOpeningModel struct {
+	DB *sql.DB
+}
+
+// Insert adds an opening to the end of an estimate's delivery path and assigns the generated OpeningID.
+func (m *EstimateOpeningModel) Insert(o *EstimateOpening) error {
+	stmt := `INSERT INTO estimate_openings (estimate_id, position, name, kind, width_inch, height_inch, turn_width_inch)
+	VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM estimate_openings WHERE estimate_id=$1), $2, $3, $4, $5, $6)
+	RETURNING opening_id, position`
+
+	return m.DB.QueryRow(stmt, o.EstimateID, o.Name, o.Kind, o.WidthInch, o.HeightInch, o.TurnWidthInch).Scan(&o.OpeningID, &o.Position)
+}
+
+// GetByEstimateID returns the delivery path of an estimate in order.
+func (m *EstimateOpeningModel) GetByEstimateID(estimateID int) ([]EstimateOpening, error) {
+	stmt := `SELECT opening_id, estimate_id, position, name, kind, width_inch, height_inch, turn_width_inch
+	FROM estimate_openings WHERE estimate_id=$1 ORDER BY position, opening_id`
+
+	rows, err := m.DB.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var openings []EstimateOpening
+	for rows.Next() {
+		var o EstimateOpening
+		err := rows.Scan(&o.OpeningID, &o.EstimateID, &o.Position, &o.Name, &o.Kind, &o.WidthInch, &o.HeightInch, &o.TurnWidthInch)
+		if err != nil {
+			return nil, err
+		}
+		openings = append(openings, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return openings, nil
+}
+
+// GetOpeningEstimateID returns the estimate that owns the given opening.
+// Returns ErrNoRecord if the opening does not exist.
+func (m *EstimateOpeningModel) GetOpeningEstimateID(openingID int) (int, error) {
+	stmt := `SELECT estimate_id FROM estimate_openings WHERE opening_id=$1`
+
+	var estimateID int
+	err := m.DB.QueryRow(stmt, openingID).Scan(&estimateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return estimateID, nil
+}
+
+// Delete removes an opening from a delivery path.
+// Returns ErrNoRecord if the opening does not exist.
+func (m *EstimateOpeningModel) Delete(openingID int) error {
+	stmt := `DELETE FROM estimate_openings WHERE opening_id=$1`
+	result, err := m.DB.Exec(stmt, openingID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+// models/estimate_option.go contains the good/better/best option groups that can be attached to an estimate.
+// An option group (ex. "Countertops") holds several options (ex. "Laminate", "Quartz", "Granite") and each option
+// holds its own alternate line items. The customer picks exactly one option per group before signing.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// OptionGroup is a set of mutually exclusive options on an estimate.
+type OptionGroup struct {
+	GroupID    int
+	EstimateID int
+	Name       string
+	Options    []EstimateOption
+	// SelectedOptionID is the option the customer chose when signing, if any.
+	SelectedOptionID sql.NullInt64
+}
+
+// IsSelected reports whether the customer chose the given option for this group when signing.
+func (g OptionGroup) IsSelected(optionID int) bool {
+	return g.SelectedOptionID.Valid && int(g.SelectedOptionID.Int64) == optionID
+}
+
+// EstimateOption is a single choice within an OptionGroup along with its alternate line items.
+// Totals is the estimate total when this option is chosen on top of the base line items.
+type EstimateOption struct {
+	OptionID  int
+	GroupID   int
+	Name      string
+	SortOrder int
+	Products  []EstimateProduct
+	Totals    EstimateTotals
+}
+
+// EstimateOptionModel wraps database operations for estimate_option_groups, estimate_options and their selections.
+type EstimateOptionModel struct {
+	DB *sql.DB
+}
+
+// InsertGroup creates a new option group on an estimate and assigns the generated GroupID.
+func (m *EstimateOptionModel) InsertGroup(g *OptionGroup) error {
+	stmt := `INSERT INTO estimate_option_groups (estimate_id, name) VALUES ($1, $2) RETURNING group_id`
+
+	return m.DB.QueryRow(stmt, g.EstimateID, g.Name).Scan(&g.GroupID)
+}
+
+// InsertOption creates a new option within a group and assigns the generated OptionID.
+// The option is placed after any existing options in the group.
+func (m *EstimateOptionModel) InsertOption(o *EstimateOption) error {
+	stmt := `INSERT INTO estimate_options (group_id, name, sort_order)
+	VALUES ($1, $2, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM estimate_options WHERE group_id=$1))
+	RETURNING option_id, sort_order`
+
+	return m.DB.QueryRow(stmt, o.GroupID, o.Name).Scan(&o.OptionID, &o.SortOrder)
+}
+
+// GetOptionEstimateID returns the estimate that owns the given option.
+// Returns ErrNoRecord if the option does not exist.
+func (m *EstimateOptionModel) GetOptionEstimateID(optionID int) (int, error) {
+	stmt := `SELECT g.estimate_id FROM estimate_options o
+	JOIN estimate_option_groups g ON g.group_id = o.group_id
+	WHERE o.option_id=$1`
+
+	var estimateID int
+	err := m.DB.QueryRow(stmt, optionID).Scan(&estimateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return estimateID, nil
+}
+
+// GetGroupEstimateID returns the estimate that owns the given option group.
+// Returns ErrNoRecord if the group does not exist.
+func (m *EstimateOptionModel) GetGroupEstimateID(groupID int) (int, error) {
+	stmt := `SELECT estimate_id FROM estimate_option_groups WHERE group_id=$1`
+
+	var estimateID int
+	err := m.DB.QueryRow(stmt, groupID).Scan(&estimateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return estimateID, nil
+}
+
+// GetGroupsByEstimateID returns every option group on an estimate with its options (without line items)
+// and the customer's selection if one has been recorded.
+func (m *EstimateOptionModel) GetGroupsByEstimateID(estimateID int) ([]OptionGroup, error) {
+	stmt := `SELECT g.group_id, g.name, s.option_id, o.option_id, o.name, o.sort_order
+	FROM estimate_option_groups g
+	LEFT JOIN estimate_options o ON o.group_id = g.group_id
+	LEFT JOIN estimate_option_selections s ON s.group_id = g.group_id
+	WHERE g.estimate_id=$1
+	ORDER BY g.group_id, o.sort_order, o.option_id`
+
+	rows, err := m.DB.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []OptionGroup
+	for rows.Next() {
+		var (
+			groupID    int
+			groupName  string
+			selected   sql.NullInt64
+			optionID   sql.NullInt64
+			optionName sql.NullString
+			sortOrder  sql.NullInt64
+		)
+
+		err := rows.Scan(&groupID, &groupName, &selected, &optionID, &optionName, &sortOrder)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(groups) == 0 || groups[len(groups)-1].GroupID != groupID {
+			groups = append(groups, OptionGroup{
+				GroupID:          groupID,
+				EstimateID:       estimateID,
+				Name:             groupName,
+				SelectedOptionID: selected,
+			})
+		}
+
+		if optionID.Valid {
+			g := &groups[len(groups)-1]
+			g.Options = append(g.Options, EstimateOption{
+				OptionID:  int(optionID.Int64),
+				GroupID:   groupID,
+				Name:      optionName.String,
+				SortOrder: int(sortOrder.Int64),
+			})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// DeleteGroup removes an option group along with its options and their alternate line items.
+// Returns ErrNoRecord if the group does not exist.
+func (m *EstimateOptionModel) DeleteGroup(groupID int) error {
+	stmt := `DELETE FROM estimate_option_groups WHERE group_id=$1`
+	result, err := m.DB.Exec(stmt, groupID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// SelectOptionsTx records the customer's choice for every option group on an estimate inside the signing
+// transaction. The alternate line items of the chosen options become regular line items and the alternates
+// of every other option are removed, so the signed estimate only contains what the customer agreed to.
+// selections maps GroupID to the chosen OptionID.
+func (m *EstimateOptionModel) SelectOptionsTx(tx *sql.Tx, estimateID, invoiceTokenID int, selections map[int]int) error {
+	for groupID, optionID := range selections {
+		stmt := `INSERT INTO estimate_option_selections (estimate_id, group_id, option_id, invoice_token_id)
+		SELECT $1, o.group_id, o.option_id, $4 FROM estimate_options o
+		JOIN estimate_option_groups g ON g.group_id = o.group_id
+		WHERE g.estimate_id=$1 AND o.group_id=$2 AND o.option_id=$3`
+
+		result, err := tx.Exec(stmt, estimateID, groupID, optionID, invoiceTokenID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return fmt.Errorf("option %d does not belong to group %d on estimate %d", optionID, groupID, estimateID)
+		}
+	}
+
+	stmt := `DELETE FROM estimate_items ei
+	WHERE ei.estimate_id=$1 AND ei.option_id IS NOT NULL
+	AND ei.option_id NOT IN (SELECT option_id FROM estimate_option_selections WHERE estimate_id=$1)`
+
+	_, err := tx.Exec(stmt, estimateID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE estimate_items SET option_id=NULL WHERE estimate_id=$1 AND option_id IS NOT NULL`
+
+	_, err = tx.Exec(stmt, estimateID)
+	return err
+}
+
+// SplitOptionProducts separates an estimate's line items into the base line items and the alternates of each
+// option, attaching the alternates to their option within groups. The returned base slice is what every
+// customer pays for regardless of their choices.
+func SplitOptionProducts(groups []OptionGroup, estimateProducts []EstimateProduct) []EstimateProduct {
+	var base []EstimateProduct
+	byOption := map[int][]EstimateProduct{}
+
+	for _, ep := range estimateProducts {
+		if ep.EstimateItem.OptionID.Valid {
+			optionID := int(ep.EstimateItem.OptionID.Int64)
+			byOption[optionID] = append(byOption[optionID], ep)
+			continue
+		}
+		base = append(base, ep)
+	}
+
+	for i := range groups {
+		for j := range groups[i].Options {
+			groups[i].Options[j].Products = byOption[groups[i].Options[j].OptionID]
+		}
+	}
+
+	return base
+}
@@ -0,0 +1,127 @@
+// models/estimate_policy.go holds the rules for who may work on an estimate and when its line items may change.
+// Every handler that touches line items goes through AuthorizeItemChange so the rules live in one place.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EstimateShare grants a user who did not create an estimate the same access as its creator.
+type EstimateShare struct {
+	EstimateID int
+	UserID     int
+	Name       string
+	Email      string
+	SharedBy   int
+	CreatedAt  time.Time
+}
+
+// CanAccess reports whether a user may view and work on an estimate. Admins can access every estimate,
+// everyone else only the estimates they created or that were shared with them.
+// Returns ErrNoRecord if the estimate does not exist.
+func (m *EstimateModel) CanAccess(estimateID int, user User) (bool, error) {
+	_, allowed, err := m.accessStatus(estimateID, user)
+	return allowed, err
+}
+
+// AuthorizeItemChange checks that a user may add, change or remove line items on an estimate.
+// Returns ErrNoRecord if the estimate does not exist, ErrForbidden if the user cannot access it and
+// ErrEstimateLocked if it is no longer a Draft.
+func (m *EstimateModel) AuthorizeItemChange(estimateID int, user User) error {
+	status, allowed, err := m.accessStatus(estimateID, user)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	if status != StatusDraft {
+		return ErrEstimateLocked
+	}
+
+	return nil
+}
+
+// accessStatus returns the status of an estimate and whether the user may access it.
+func (m *EstimateModel) accessStatus(estimateID int, user User) (EstimateStatus, bool, error) {
+	stmt := `SELECT e.status,
+	e.created_by = $2 OR EXISTS (SELECT 1 FROM estimate_shares s WHERE s.estimate_id = e.estimate_id AND s.user_id = $2)
+	FROM estimates e WHERE e.estimate_id=$1`
+
+	var (
+		status  EstimateStatus
+		allowed bool
+	)
+	err := m.DB.QueryRow(stmt, estimateID, user.UserID).Scan(&status, &allowed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, ErrNoRecord
+		}
+		return 0, false, err
+	}
+
+	return status, allowed || user.Role == RoleAdmin, nil
+}
+
+// Share gives a user access to an estimate. Sharing an estimate with the same user twice is a no-op.
+func (m *EstimateModel) Share(estimateID, userID, sharedBy int) error {
+	stmt := `INSERT INTO estimate_shares (estimate_id, user_id, shared_by) VALUES ($1, $2, $3)
+	ON CONFLICT (estimate_id, user_id) DO NOTHING`
+
+	_, err := m.DB.Exec(stmt, estimateID, userID, sharedBy)
+	return err
+}
+
+// Unshare removes a user's access to an estimate.
+// Returns ErrNoRecord if the estimate was not shared with the user.
+func (m *EstimateModel) Unshare(estimateID, userID int) error {
+	stmt := `DELETE FROM estimate_shares WHERE estimate_id=$1 AND user_id=$2`
+	result, err := m.DB.Exec(stmt, estimateID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// GetShares returns the users an estimate has been shared with, oldest first.
+func (m *EstimateModel) GetShares(estimateID int) ([]EstimateShare, error) {
+	stmt := `SELECT s.estimate_id, s.user_id, u.name, u.email, s.shared_by, s.created_at
+	FROM estimate_shares s
+	JOIN users u ON u.user_id = s.user_id
+	WHERE s.estimate_id=$1
+	ORDER BY s.created_at, s.user_id`
+
+	rows, err := m.DB.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []EstimateShare
+	for rows.Next() {
+		var s EstimateShare
+		err := rows.Scan(&s.EstimateID, &s.UserID, &s.Name, &s.Email, &s.SharedBy, &s.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
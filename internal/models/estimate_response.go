@@ -0,0 +1,91 @@
+// models/estimate_response.go records what a customer said when they declined an estimate or asked for changes
+// from the signing page instead of signing it.
+
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ResponseKind is how a customer answered an estimate without signing it.
+type ResponseKind string
+
+const (
+	// ResponseDeclined responses move the estimate to StatusDeclined.
+	ResponseDeclined ResponseKind = "declined"
+	// ResponseChangesRequested responses move the estimate back to StatusDraft for the surveyor to revise.
+	ResponseChangesRequested ResponseKind = "changes_requested"
+)
+
+// ResponseKinds lists every ResponseKind.
+var ResponseKinds = []ResponseKind{ResponseDeclined, ResponseChangesRequested}
+
+// Label names the response for people.
+func (k ResponseKind) Label() string {
+	if k == ResponseDeclined {
+		return "Declined"
+	}
+	return "Requested Changes"
+}
+
+// Status returns the status an estimate moves to once the customer responds.
+func (k ResponseKind) Status() EstimateStatus {
+	if k == ResponseDeclined {
+		return StatusDeclined
+	}
+	return StatusDraft
+}
+
+// EstimateResponse is a customer's reason for declining an estimate or the changes they asked for.
+type EstimateResponse struct {
+	ResponseID     int
+	EstimateID     int
+	InvoiceTokenID int
+	Kind           ResponseKind
+	Reason         string
+	CreatedAt      time.Time
+}
+
+type EstimateResponseModel struct {
+	DB *sql.DB
+}
+
+// InsertTx records a customer's response given with a signing link. The caller marks the link used and moves the
+// estimate to the response's status in the same transaction.
+func (m *EstimateResponseModel) InsertTx(tx *sql.Tx, er *EstimateResponse) error {
+	stmt := `INSERT INTO estimate_responses (estimate_id, invoice_token_id, kind, reason)
+	VALUES ($1, $2, $3, $4) RETURNING response_id, created_at`
+
+	return tx.QueryRow(stmt, er.EstimateID, er.InvoiceTokenID, er.Kind, er.Reason).Scan(&er.ResponseID, &er.CreatedAt)
+}
+
+// GetByEstimateID returns the responses given to an estimate, newest first.
+func (m *EstimateResponseModel) GetByEstimateID(estimateID int) ([]EstimateResponse, error) {
+	stmt := `SELECT response_id, estimate_id, COALESCE(invoice_token_id, 0), kind, reason, created_at
+	FROM estimate_responses
+	WHERE estimate_id = $1
+	ORDER BY created_at DESC, response_id DESC`
+
+	rows, err := m.DB.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var responses []EstimateResponse
+	for rows.Next() {
+		var er EstimateResponse
+		err := rows.Scan(&er.ResponseID, &er.EstimateID, &er.InvoiceTokenID, &er.Kind, &er.Reason, &er.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, er)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
@@ -133,3 +133,53 @@ func TestEstimateDelete(t *testing.T) {
 		t.Fatalf("Expected ErrNoRecord, got %v", err)
 	}
 }
+
+func TestEstimateAuthorizeItemChange(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	owner := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+	colleague := createTestUser(t, "Jane Surveyor", "jane@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, owner.ID)
+
+	ownerUser := models.User{UserID: owner.ID, Role: models.RoleSurveyor}
+	colleagueUser := models.User{UserID: colleague.ID, Role: models.RoleSurveyor}
+	adminUser := models.User{UserID: customer.ID, Role: models.RoleAdmin}
+
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID, ownerUser); err != nil {
+		t.Errorf("Expected the owner to be allowed, got %v", err)
+	}
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID, adminUser); err != nil {
+		t.Errorf("Expected admins to be allowed, got %v", err)
+	}
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID, colleagueUser); !errors.Is(err, models.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden before sharing, got %v", err)
+	}
+
+	if err := estimateModel.Share(estimate.EstimateID, colleague.ID, owner.ID); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID, colleagueUser); err != nil {
+		t.Errorf("Expected a shared user to be allowed, got %v", err)
+	}
+
+	shares, err := estimateModel.GetShares(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetShares failed: %v", err)
+	}
+	if len(shares) != 1 || shares[0].UserID != colleague.ID {
+		t.Errorf("Expected the estimate to be shared with the colleague, got %+v", shares)
+	}
+
+	if err := estimateModel.UpdateStatus(estimate.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID, ownerUser); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("Expected ErrEstimateLocked once the estimate leaves Draft, got %v", err)
+	}
+
+	if err := estimateModel.AuthorizeItemChange(estimate.EstimateID+1000, ownerUser); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("Expected ErrNoRecord for a missing estimate, got %v", err)
+	}
+}
@@ -145,3 +145,208 @@ func TestEstimateItemGetByEstimateID(t *testing.T) {
 		t.Errorf("Expected 2 estimate items, got %d", len(items))
 	}
 }
+
+func TestEstimateItemCustomLineItems(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+	product := createTestProduct(t, surveyor.ID)
+
+	catalog := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	drywall := &models.EstimateItem{
+		EstimateID:        estimate.EstimateID,
+		Quantity:          2,
+		CustomDescription: "Drywall repair",
+		CustomUnitPrice:   6000,
+		CustomCategory:    "Misc",
+		Taxable:           false,
+	}
+	for _, item := range []*models.EstimateItem{catalog, drywall} {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	got, err := estimateItemModel.GetByLineItemID(drywall.LineItemID)
+	if err != nil {
+		t.Fatalf("GetByLineItemID failed: %v", err)
+	}
+	if !got.IsCustom() || got.CustomDescription != "Drywall repair" || got.Taxable {
+		t.Errorf("Unexpected custom line item: %+v", got)
+	}
+
+	items, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 estimate items, got %d", len(items))
+	}
+	if !items[0].EstimateItem.Taxable {
+		t.Errorf("Expected catalog products to be taxable")
+	}
+	if items[1].Product.Name != "Drywall repair" || items[1].Product.UnitPrice != 6000 || items[1].Product.Category != "Misc" {
+		t.Errorf("Expected custom item to fill in product details, got %+v", items[1].Product)
+	}
+
+	totals := estimateModel.CalculateEstimateTotals(items)
+	if want := product.UnitPrice / 6; totals.SalesTax != want {
+		t.Errorf("Expected sales tax only on taxable items (%d), got %d", want, totals.SalesTax)
+	}
+}
+
+func TestEstimateItemAcceptAddons(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+	product := createTestProduct(t, surveyor.ID)
+
+	required := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	accepted := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1, IsOptional: true}
+	declined := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1, IsOptional: true}
+	for _, item := range []*models.EstimateItem{required, accepted, declined} {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	items, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	req, opt := models.SplitOptionalProducts(items)
+	if len(req) != 1 || len(opt) != 2 {
+		t.Fatalf("Expected 1 required and 2 optional items, got %d and %d", len(req), len(opt))
+	}
+
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := estimateItemModel.AcceptAddonsTx(tx, estimate.EstimateID, []int{accepted.LineItemID}); err != nil {
+		t.Fatalf("AcceptAddonsTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if _, err := estimateItemModel.GetByLineItemID(declined.LineItemID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("Expected declined add-on to be removed, got %v", err)
+	}
+
+	got, err := estimateItemModel.GetByLineItemID(accepted.LineItemID)
+	if err != nil {
+		t.Fatalf("GetByLineItemID failed: %v", err)
+	}
+	if got.IsOptional {
+		t.Errorf("Expected accepted add-on to become a regular line item")
+	}
+}
+
+func TestEstimateItemApplyBatch(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+	product := createTestProduct(t, surveyor.ID)
+
+	keep := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	remove := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	for _, item := range []*models.EstimateItem{keep, remove} {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	// A batch that touches a line item that does not exist must not apply anything.
+	_, err := estimateItemModel.ApplyBatch(estimate.EstimateID, []models.ItemOperation{
+		{Kind: models.ItemOperationDelete, Item: models.EstimateItem{LineItemID: remove.LineItemID}},
+		{Kind: models.ItemOperationUpdate, Item: models.EstimateItem{LineItemID: 9999, Quantity: 2}},
+	})
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Fatalf("Expected ErrNoRecord, got %v", err)
+	}
+	if _, err := estimateItemModel.GetByLineItemID(remove.LineItemID); err != nil {
+		t.Fatalf("Expected the failed batch to be rolled back, got %v", err)
+	}
+
+	ids, err := estimateItemModel.ApplyBatch(estimate.EstimateID, []models.ItemOperation{
+		{Kind: models.ItemOperationAdd, Item: models.EstimateItem{ProductID: product.ProductID, Quantity: 4}},
+		{Kind: models.ItemOperationUpdate, Item: models.EstimateItem{LineItemID: keep.LineItemID, Quantity: 7}},
+		{Kind: models.ItemOperationDelete, Item: models.EstimateItem{LineItemID: remove.LineItemID}},
+	})
+	if err != nil {
+		t.Fatalf("ApplyBatch failed: %v", err)
+	}
+	if len(ids) != 3 || ids[0] == 0 {
+		t.Fatalf("Expected an ID per operation, got %v", ids)
+	}
+
+	got, err := estimateItemModel.GetByLineItemID(keep.LineItemID)
+	if err != nil {
+		t.Fatalf("GetByLineItemID failed: %v", err)
+	}
+	if got.Quantity != 7 {
+		t.Errorf("Expected Quantity 7, got %d", got.Quantity)
+	}
+	if _, err := estimateItemModel.GetByLineItemID(remove.LineItemID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("Expected deleted line item to be gone, got %v", err)
+	}
+
+	if err := estimateModel.UpdateStatus(estimate.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+	_, err = estimateItemModel.ApplyBatch(estimate.EstimateID, []models.ItemOperation{
+		{Kind: models.ItemOperationUpdate, Item: models.EstimateItem{LineItemID: keep.LineItemID, Quantity: 1}},
+	})
+	if !errors.Is(err, models.ErrEstimateLocked) {
+		t.Fatalf("Expected ErrEstimateLocked, got %v", err)
+	}
+}
+
+func TestEstimateItemDraftOnly(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+	product := createTestProduct(t, surveyor.ID)
+
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	if err := estimateModel.UpdateStatus(estimate.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	// Line items cannot change once the estimate has left Draft, whatever the caller checked beforehand.
+	added := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	if err := estimateItemModel.Insert(added); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("Expected Insert to return ErrEstimateLocked, got %v", err)
+	}
+	if err := estimateItemModel.Update(models.EstimateItem{LineItemID: item.LineItemID, Quantity: 5}); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("Expected Update to return ErrEstimateLocked, got %v", err)
+	}
+	if err := estimateItemModel.SetOptional(item.LineItemID, true); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("Expected SetOptional to return ErrEstimateLocked, got %v", err)
+	}
+	if err := estimateItemModel.Delete(item.LineItemID); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("Expected Delete to return ErrEstimateLocked, got %v", err)
+	}
+
+	got, err := estimateItemModel.GetByLineItemID(item.LineItemID)
+	if err != nil || got.Quantity != 1 || got.IsOptional {
+		t.Errorf("Expected the line item to be unchanged, got %+v (%v)", got, err)
+	}
+}
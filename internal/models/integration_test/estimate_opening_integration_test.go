@@ -0,0 +1,42 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestEstimateOpeningsInOrder(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+
+	frontDoor := &models.EstimateOpening{EstimateID: estimate.EstimateID, Name: "Front door", Kind: "door", WidthInch: 36, HeightInch: 80}
+	hallway := &models.EstimateOpening{EstimateID: estimate.EstimateID, Name: "Hallway", Kind: "hallway", WidthInch: 36, HeightInch: 96, TurnWidthInch: 32}
+	for _, o := range []*models.EstimateOpening{frontDoor, hallway} {
+		if err := openingModel.Insert(o); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	if frontDoor.Position != 1 || hallway.Position != 2 {
+		t.Errorf("Expected positions 1 and 2, got %d and %d", frontDoor.Position, hallway.Position)
+	}
+
+	openings, err := openingModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(openings) != 2 || openings[0].Name != "Front door" || openings[1].TurnWidthInch != 32 {
+		t.Errorf("Unexpected delivery path: %+v", openings)
+	}
+
+	if err := openingModel.Delete(frontDoor.OpeningID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := openingModel.GetOpeningEstimateID(frontDoor.OpeningID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("Expected ErrNoRecord after delete, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package integration_test
+
+import (
+	"database/sql"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestEstimateOptionGroupsAndSelection(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	estimate := createTestEstimate(t, customer.ID, surveyor.ID)
+	product := createTestProduct(t, surveyor.ID)
+
+	group := &models.OptionGroup{EstimateID: estimate.EstimateID, Name: "Countertops"}
+	if err := optionModel.InsertGroup(group); err != nil {
+		t.Fatalf("InsertGroup failed: %v", err)
+	}
+
+	good := &models.EstimateOption{GroupID: group.GroupID, Name: "Laminate"}
+	best := &models.EstimateOption{GroupID: group.GroupID, Name: "Quartz"}
+	for _, o := range []*models.EstimateOption{good, best} {
+		if err := optionModel.InsertOption(o); err != nil {
+			t.Fatalf("InsertOption failed: %v", err)
+		}
+	}
+	if best.SortOrder <= good.SortOrder {
+		t.Errorf("Expected options to be ordered by insertion, got %d then %d", good.SortOrder, best.SortOrder)
+	}
+
+	base := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	goodItem := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 2,
+		OptionID: sql.NullInt64{Int64: int64(good.OptionID), Valid: true}}
+	bestItem := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 3,
+		OptionID: sql.NullInt64{Int64: int64(best.OptionID), Valid: true}}
+	for _, item := range []*models.EstimateItem{base, goodItem, bestItem} {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert item failed: %v", err)
+		}
+	}
+
+	groups, err := optionModel.GetGroupsByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetGroupsByEstimateID failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups[0].Options) != 2 {
+		t.Fatalf("Expected 1 group with 2 options, got %+v", groups)
+	}
+
+	items, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	baseItems := models.SplitOptionProducts(groups, items)
+	if len(baseItems) != 1 || len(groups[0].Options[0].Products) != 1 || len(groups[0].Options[1].Products) != 1 {
+		t.Fatalf("Expected 1 base item and 1 item per option, got %d base", len(baseItems))
+	}
+
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	err = optionModel.SelectOptionsTx(tx, estimate.EstimateID, 0, map[int]int{group.GroupID + 1: best.OptionID})
+	if err == nil {
+		t.Fatal("Expected an error when selecting an option outside its group")
+	}
+	tx.Rollback()
+
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	var tokenID int
+	err = tx.QueryRow(`INSERT INTO invoice_access_tokens (estimate_id, token_hash, expires_at)
+		VALUES ($1, repeat('a', 64), NOW() + INTERVAL '1 day') RETURNING invoice_token_id`, estimate.EstimateID).Scan(&tokenID)
+	if err != nil {
+		t.Fatalf("insert token failed: %v", err)
+	}
+	if err := optionModel.SelectOptionsTx(tx, estimate.EstimateID, tokenID, map[int]int{group.GroupID: best.OptionID}); err != nil {
+		t.Fatalf("SelectOptionsTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	items, err = estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected base item and chosen option item to remain, got %d items", len(items))
+	}
+	for _, item := range items {
+		if item.EstimateItem.OptionID.Valid {
+			t.Errorf("Expected chosen option items to become regular line items, got option %d", item.EstimateItem.OptionID.Int64)
+		}
+		if item.EstimateItem.LineItemID == goodItem.LineItemID {
+			t.Errorf("Expected the unselected option item to be removed")
+		}
+	}
+
+	groups, err = optionModel.GetGroupsByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetGroupsByEstimateID failed: %v", err)
+	}
+	if !groups[0].IsSelected(best.OptionID) {
+		t.Errorf("Expected option %d to be recorded as selected", best.OptionID)
+	}
+}
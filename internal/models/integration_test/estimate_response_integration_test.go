@@ -0,0 +1,83 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+	"time"
+)
+
+func TestEstimateResponses(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+
+	respond := func(kind models.ResponseKind, reason string) error {
+		raw, err := tokenModel.Insert(estimate.EstimateID, time.Now().Add(72*time.Hour), admin.ID)
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		it, err := tokenModel.GetByRawToken(raw)
+		if err != nil {
+			t.Fatalf("GetByRawToken failed: %v", err)
+		}
+
+		tx, err := testDB.Begin()
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+		defer tx.Rollback()
+
+		if err := tokenModel.MarkUsedTx(tx, it.InvoiceTokenID); err != nil {
+			return err
+		}
+		response := models.EstimateResponse{EstimateID: estimate.EstimateID, InvoiceTokenID: it.InvoiceTokenID, Kind: kind, Reason: reason}
+		if err := responseModel.InsertTx(tx, &response); err != nil {
+			return err
+		}
+		if err := estimateModel.UpdateStatusTx(tx, estimate.EstimateID, kind.Status()); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if err := respond(models.ResponseChangesRequested, "Please swap the sink for a farmhouse sink."); err != nil {
+		t.Fatalf("requesting changes failed: %v", err)
+	}
+	got, err := estimateModel.Get(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != models.StatusDraft {
+		t.Errorf("expected a change request to move the estimate back to Draft, got %s", got.Status)
+	}
+
+	if err := respond(models.ResponseDeclined, "Going with another contractor."); err != nil {
+		t.Fatalf("declining failed: %v", err)
+	}
+	got, err = estimateModel.Get(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != models.StatusDeclined || got.Status.Next() != models.StatusDeclined {
+		t.Errorf("expected the estimate to stay declined, got %s", got.Status)
+	}
+
+	responses, err := responseModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(responses) != 2 || responses[0].Kind != models.ResponseDeclined || responses[1].Kind != models.ResponseChangesRequested {
+		t.Fatalf("expected both responses newest first, got %+v", responses)
+	}
+	if responses[1].Reason != "Please swap the sink for a farmhouse sink." {
+		t.Errorf("expected the customer's comments to be kept, got %q", responses[1].Reason)
+	}
+
+	// Responding uses up the link, so it cannot also be used to sign.
+	if err := tokenModel.MarkUsed(responses[0].InvoiceTokenID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord using a link after responding, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestInventoryReserveAndConsume(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	warehouse := &models.InventoryLocation{Name: "Main Warehouse"}
+	truck := &models.InventoryLocation{Name: "Truck 1"}
+	for _, l := range []*models.InventoryLocation{warehouse, truck} {
+		if err := inventoryModel.InsertLocation(l); err != nil {
+			t.Fatalf("InsertLocation failed: %v", err)
+		}
+	}
+	if err := inventoryModel.InsertLocation(&models.InventoryLocation{Name: "Truck 1"}); !errors.Is(err, models.ErrDuplicateLocation) {
+		t.Errorf("expected ErrDuplicateLocation, got %v", err)
+	}
+
+	tracked := createTestProduct(t, admin.ID)
+	untracked := createTestProduct(t, admin.ID)
+
+	adjustments := []models.InventoryAdjustment{
+		{ProductID: tracked.ProductID, LocationID: warehouse.LocationID, Delta: 3, Reason: "Received", AdjustedBy: admin.ID},
+		{ProductID: tracked.ProductID, LocationID: truck.LocationID, Delta: 1, Reason: "Received", AdjustedBy: admin.ID},
+	}
+	for i := range adjustments {
+		if err := inventoryModel.Adjust(&adjustments[i]); err != nil {
+			t.Fatalf("Adjust failed: %v", err)
+		}
+	}
+	if err := inventoryModel.Adjust(&models.InventoryAdjustment{ProductID: 9999, LocationID: warehouse.LocationID, Delta: 1, Reason: "x"}); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord for an unknown product, got %v", err)
+	}
+	if err := inventoryModel.SetReorderPoint(tracked.ProductID, warehouse.LocationID, 1); err != nil {
+		t.Fatalf("SetReorderPoint failed: %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	items := []*models.EstimateItem{
+		{EstimateID: estimate.EstimateID, ProductID: tracked.ProductID, Quantity: 5},
+		{EstimateID: estimate.EstimateID, ProductID: untracked.ProductID, Quantity: 1},
+		{EstimateID: estimate.EstimateID, ProductID: tracked.ProductID, Quantity: 1, IsOptional: true},
+	}
+	for _, item := range items {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert item failed: %v", err)
+		}
+	}
+
+	stock, err := inventoryModel.GetProductStock([]int{tracked.ProductID, untracked.ProductID})
+	if err != nil {
+		t.Fatalf("GetProductStock failed: %v", err)
+	}
+	if _, ok := stock[untracked.ProductID]; ok {
+		t.Errorf("expected the untracked product to be left out, got %+v", stock)
+	}
+	if got := stock[tracked.ProductID].Warning(5); got != "Only 4 in stock." {
+		t.Errorf("expected a short stock warning, got %q", got)
+	}
+	if got := stock[tracked.ProductID].Warning(3); got != "Low stock: 1 left after this estimate." {
+		t.Errorf("expected a low stock warning, got %q", got)
+	}
+
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inventoryModel.ReserveTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("ReserveTx failed: %v", err)
+	}
+	// Signing twice must not reserve twice.
+	if err := inventoryModel.ReserveTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("second ReserveTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	reservations, err := inventoryModel.GetOpenReservations()
+	if err != nil {
+		t.Fatalf("GetOpenReservations failed: %v", err)
+	}
+	reserved := make(map[string]int)
+	for _, r := range reservations {
+		reserved[r.LocationName] += r.Quantity
+	}
+	// 3 from the warehouse, 1 from the truck, and the shortfall of 1 at the warehouse.
+	if len(reservations) != 2 || reserved["Main Warehouse"] != 4 || reserved["Truck 1"] != 1 {
+		t.Errorf("unexpected reservations: %+v", reservations)
+	}
+
+	stock, err = inventoryModel.GetProductStock([]int{tracked.ProductID})
+	if err != nil {
+		t.Fatalf("GetProductStock failed: %v", err)
+	}
+	if got := stock[tracked.ProductID].Warning(1); got != "Out of stock." {
+		t.Errorf("expected reserved stock to be unavailable, got %q", got)
+	}
+
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inventoryModel.ConsumeTx(tx, estimate.EstimateID, admin.ID); err != nil {
+		t.Fatalf("ConsumeTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	levels, err := inventoryModel.GetStockLevels()
+	if err != nil {
+		t.Fatalf("GetStockLevels failed: %v", err)
+	}
+	onHand := make(map[string]int)
+	for _, l := range levels {
+		if l.Reserved != 0 {
+			t.Errorf("expected no stock to stay reserved, got %+v", l)
+		}
+		onHand[l.LocationName] = l.OnHand
+	}
+	if onHand["Main Warehouse"] != -1 || onHand["Truck 1"] != 0 {
+		t.Errorf("unexpected stock after consuming: %+v", levels)
+	}
+
+	ledger, err := inventoryModel.GetLedger(10)
+	if err != nil {
+		t.Fatalf("GetLedger failed: %v", err)
+	}
+	if len(ledger) != 4 {
+		t.Fatalf("expected 4 ledger entries, got %d", len(ledger))
+	}
+	for _, a := range ledger[:2] {
+		if a.EstimateID != estimate.EstimateID || a.Delta >= 0 || a.AdjustedByName != "Ada Admin" {
+			t.Errorf("unexpected ledger entry for the completed estimate: %+v", a)
+		}
+	}
+}
+
+func TestInventoryDeleteEstimateFreesStock(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	warehouse := &models.InventoryLocation{Name: "Main Warehouse"}
+	if err := inventoryModel.InsertLocation(warehouse); err != nil {
+		t.Fatalf("InsertLocation failed: %v", err)
+	}
+
+	product := createTestProduct(t, admin.ID)
+	adjustment := models.InventoryAdjustment{ProductID: product.ProductID, LocationID: warehouse.LocationID, Delta: 2, Reason: "Received", AdjustedBy: admin.ID}
+	if err := inventoryModel.Adjust(&adjustment); err != nil {
+		t.Fatalf("Adjust failed: %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	if err := estimateItemModel.Insert(&models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 2}); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inventoryModel.ReserveTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("ReserveTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := estimateModel.Delete(estimate.EstimateID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	stock, err := inventoryModel.GetProductStock([]int{product.ProductID})
+	if err != nil {
+		t.Fatalf("GetProductStock failed: %v", err)
+	}
+	if got := stock[product.ProductID]; got.Reserved != 0 || got.Available() != 2 {
+		t.Errorf("expected deleting the estimate to free its stock, got %+v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInvoiceTokenReissueAndRevoke(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+
+	expiresAt := time.Now().Add(72 * time.Hour)
+	first, err := tokenModel.Insert(estimate.EstimateID, expiresAt, admin.ID)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// Issuing a new link revokes the one before it.
+	second, err := tokenModel.Insert(estimate.EstimateID, expiresAt, admin.ID)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	old, err := tokenModel.GetByRawToken(first)
+	if err != nil {
+		t.Fatalf("GetByRawToken failed: %v", err)
+	}
+	if old.Status(time.Now()) != models.TokenRevoked || old.Active(time.Now()) {
+		t.Errorf("expected the first link to be revoked, got %s", old.Status(time.Now()))
+	}
+
+	current, err := tokenModel.GetByRawToken(second)
+	if err != nil {
+		t.Fatalf("GetByRawToken failed: %v", err)
+	}
+	if !current.Active(time.Now()) {
+		t.Errorf("expected the new link to be active, got %s", current.Status(time.Now()))
+	}
+	if current.Status(expiresAt.Add(time.Minute)) != models.TokenExpired {
+		t.Errorf("expected the new link to expire after %s", expiresAt)
+	}
+
+	tokens, err := tokenModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].InvoiceTokenID != current.InvoiceTokenID {
+		t.Fatalf("expected both links newest first, got %+v", tokens)
+	}
+	if tokens[0].CreatedByName != "Ada Admin" || tokens[1].RevokedByName != "Ada Admin" {
+		t.Errorf("expected the admin to have issued and revoked the links, got %+v", tokens)
+	}
+
+	estimateID, err := tokenModel.Revoke(current.InvoiceTokenID, admin.ID)
+	if err != nil || estimateID != estimate.EstimateID {
+		t.Fatalf("expected Revoke to return the estimate, got %d (%v)", estimateID, err)
+	}
+	if _, err := tokenModel.Revoke(current.InvoiceTokenID, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord revoking twice, got %v", err)
+	}
+
+	// Revoked links cannot be used to sign.
+	if err := tokenModel.MarkUsed(current.InvoiceTokenID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord using a revoked link, got %v", err)
+	}
+}
+
+func TestInvoiceTokenReminders(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+
+	// Links issued without a key keep no copy of their token and are never reminded.
+	if _, err := tokenModel.Insert(estimate.EstimateID, time.Now().Add(72*time.Hour), admin.ID); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	keyed := &models.InvoiceTokenModel{DB: testDB, Key: make([]byte, 32)}
+	tokens, _, err := keyed.GetRemindable()
+	if err != nil || len(tokens) != 0 {
+		t.Fatalf("expected no remindable links without a sealed token, got %+v (%v)", tokens, err)
+	}
+
+	// A link sealed under another key is skipped and not tried again, without holding up the others.
+	rotated := &models.InvoiceTokenModel{DB: testDB, Key: []byte(strings.Repeat("k", 32))}
+	if _, err := rotated.Insert(estimate.EstimateID, time.Now().Add(72*time.Hour), admin.ID); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	tokens, unreadable, err := keyed.GetRemindable()
+	if err != nil || len(tokens) != 0 || len(unreadable) != 1 {
+		t.Fatalf("expected one unreadable link, got %+v %v (%v)", tokens, unreadable, err)
+	}
+	_, unreadable, err = keyed.GetRemindable()
+	if err != nil || len(unreadable) != 0 {
+		t.Fatalf("expected the unreadable link to be dropped, got %v (%v)", unreadable, err)
+	}
+
+	raw, err := keyed.Insert(estimate.EstimateID, time.Now().Add(72*time.Hour), admin.ID)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	tokens, _, err = keyed.GetRemindable()
+	if err != nil {
+		t.Fatalf("GetRemindable failed: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].RawToken != raw || tokens[0].RemindedAt.Valid {
+		t.Fatalf("expected the new link with its raw token, got %+v", tokens)
+	}
+
+	if err := keyed.MarkReminded(tokens[0].InvoiceTokenID); err != nil {
+		t.Fatalf("MarkReminded failed: %v", err)
+	}
+	listed, err := keyed.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if !listed[0].RemindedAt.Valid {
+		t.Errorf("expected the link to record the reminder, got %+v", listed[0])
+	}
+
+	// A reminder that could not be sent is taken back.
+	if err := keyed.UnmarkReminded(tokens[0].InvoiceTokenID, tokens[0].RemindedAt); err != nil {
+		t.Fatalf("UnmarkReminded failed: %v", err)
+	}
+	unmarked, err := keyed.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if unmarked[0].RemindedAt.Valid {
+		t.Errorf("expected the reminder to be taken back, got %+v", unmarked[0])
+	}
+
+	// Once the link is used the customer is no longer reminded.
+	if err := keyed.MarkUsed(tokens[0].InvoiceTokenID); err != nil {
+		t.Fatalf("MarkUsed failed: %v", err)
+	}
+	tokens, _, err = keyed.GetRemindable()
+	if err != nil || len(tokens) != 0 {
+		t.Errorf("expected no remindable links after signing, got %+v (%v)", tokens, err)
+	}
+	if err := keyed.MarkReminded(listed[0].InvoiceTokenID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord reminding a used link, got %v", err)
+	}
+}
@@ -19,6 +19,16 @@ var (
 	estimateModel     *models.EstimateModel
 	productModel      *models.ProductModel
 	estimateItemModel *models.EstimateItemModel
+	optionModel       *models.EstimateOptionModel
+	openingModel      *models.EstimateOpeningModel
+	familyModel       *models.ProductFamilyModel
+	vendorModel       *models.VendorModel
+	inventoryModel    *models.InventoryModel
+	poModel           *models.PurchaseOrderModel
+	ruleModel         *models.ProductRuleModel
+	tokenModel        *models.InvoiceTokenModel
+	responseModel     *models.EstimateResponseModel
+	auditModel        *models.SignatureAuditModel
 )
 
 func TestMain(m *testing.M) {
@@ -61,6 +71,16 @@ func TestMain(m *testing.M) {
 	estimateModel = &models.EstimateModel{DB: db}
 	productModel = &models.ProductModel{DB: db}
 	estimateItemModel = &models.EstimateItemModel{DB: db}
+	optionModel = &models.EstimateOptionModel{DB: db}
+	openingModel = &models.EstimateOpeningModel{DB: db}
+	familyModel = &models.ProductFamilyModel{DB: db}
+	vendorModel = &models.VendorModel{DB: db}
+	inventoryModel = &models.InventoryModel{DB: db}
+	poModel = &models.PurchaseOrderModel{DB: db}
+	ruleModel = &models.ProductRuleModel{DB: db}
+	tokenModel = &models.InvoiceTokenModel{DB: db}
+	responseModel = &models.EstimateResponseModel{DB: db}
+	auditModel = &models.SignatureAuditModel{DB: db}
 
 	code := m.Run()
 
@@ -71,6 +91,8 @@ func TestMain(m *testing.M) {
 
 func createSchema(db *sql.DB) error {
 	schema := `
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE IF NOT EXISTS users (
     user_id SERIAL PRIMARY KEY,
     name VARCHAR(100),
@@ -81,6 +103,26 @@ CREATE TABLE IF NOT EXISTS users (
     created_at TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS product_families (
+    family_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    category VARCHAR(50) NOT NULL,
+    subcategory VARCHAR(50) NOT NULL,
+    length REAL NOT NULL DEFAULT 0,
+    width REAL NOT NULL DEFAULT 0,
+    height REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS vendors (
+    vendor_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    contact_name VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
 CREATE TABLE IF NOT EXISTS products (
     product_id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
@@ -92,9 +134,27 @@ CREATE TABLE IF NOT EXISTS products (
     length REAL,
     width REAL,
     height REAL,
-    created_by INT REFERENCES users(user_id)
+    created_by INT REFERENCES users(user_id),
+    family_id INT REFERENCES product_families(family_id) ON DELETE SET NULL,
+    finish VARCHAR(50) NOT NULL DEFAULT '',
+    size VARCHAR(50) NOT NULL DEFAULT '',
+    discontinued_at TIMESTAMP,
+    replaced_by INT REFERENCES products(product_id) ON DELETE SET NULL,
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+    ) STORED,
+    sku VARCHAR(50) UNIQUE,
+    model_number VARCHAR(50) NOT NULL DEFAULT '',
+    vendor_id INT REFERENCES vendors(vendor_id) ON DELETE SET NULL,
+    vendor_cost INT NOT NULL DEFAULT 0 CHECK (vendor_cost >= 0),
+    lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+    image_key VARCHAR(255) NOT NULL DEFAULT ''
 );
 
+CREATE INDEX IF NOT EXISTS idx_products_name_color_trgm ON products
+    USING GIN ((name || ' ' || COALESCE(color, '')) gin_trgm_ops);
+
 CREATE TABLE IF NOT EXISTS estimates (
     estimate_id SERIAL PRIMARY KEY,
     customer_id INT REFERENCES users(user_id),
@@ -110,14 +170,258 @@ CREATE TABLE IF NOT EXISTS estimates (
     street VARCHAR(255),
     city VARCHAR(50),
     state VARCHAR(60),
-    zip VARCHAR(10)
+    zip VARCHAR(10),
+    signature_object_key TEXT,
+    signed_at TIMESTAMPTZ,
+    agreement_object_key TEXT
+);
+
+CREATE TABLE IF NOT EXISTS invoice_access_tokens (
+    invoice_token_id BIGSERIAL PRIMARY KEY,
+    estimate_id INTEGER NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_by INT REFERENCES users(user_id),
+    revoked_at TIMESTAMPTZ,
+    revoked_by INT REFERENCES users(user_id),
+    sealed_token BYTEA,
+    reminded_at TIMESTAMPTZ,
+    opened_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS signature_audits (
+    audit_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL UNIQUE REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id) ON DELETE SET NULL,
+    signer_name TEXT NOT NULL,
+    signer_email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    link_sent_at TIMESTAMPTZ NOT NULL,
+    link_opened_at TIMESTAMPTZ,
+    signed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    consent_version TEXT NOT NULL,
+    consent_text TEXT NOT NULL,
+    agreement_sha256 CHAR(64),
+    certificate_object_key TEXT
+);
+
+CREATE TABLE IF NOT EXISTS estimate_responses (
+    response_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('declined', 'changes_requested')),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS estimate_option_groups (
+    group_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS estimate_options (
+    option_id SERIAL PRIMARY KEY,
+    group_id INT NOT NULL REFERENCES estimate_option_groups(group_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0
 );
 
 CREATE TABLE IF NOT EXISTS estimate_items (
     line_item_id SERIAL PRIMARY KEY,
     estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    product_id INT REFERENCES products(product_id),
+    quantity INT NOT NULL DEFAULT 1,
+    option_id INT REFERENCES estimate_options(option_id) ON DELETE CASCADE,
+    is_optional BOOLEAN NOT NULL DEFAULT FALSE,
+    custom_description VARCHAR(255),
+    custom_unit_price INT,
+    custom_category VARCHAR(50),
+    taxable BOOLEAN NOT NULL DEFAULT TRUE,
+    snapshot_unit_price INT,
+    snapshot_price_tiers JSONB,
+    CHECK (product_id IS NOT NULL OR (custom_description IS NOT NULL AND custom_unit_price IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS estimate_openings (
+    opening_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    width_inch REAL NOT NULL,
+    height_inch REAL NOT NULL,
+    turn_width_inch REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS estimate_shares (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    shared_by INT NOT NULL REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_audit (
+    audit_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    changed_by INT NOT NULL REFERENCES users(user_id),
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    changes JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE TABLE IF NOT EXISTS estimate_option_selections (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    group_id INT NOT NULL REFERENCES estimate_option_groups(group_id) ON DELETE CASCADE,
+    option_id INT NOT NULL REFERENCES estimate_options(option_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id),
+    selected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, group_id)
+);
+
+CREATE TABLE IF NOT EXISTS inventory_locations (
+    location_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS inventory_stock (
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id) ON DELETE CASCADE,
+    on_hand INT NOT NULL DEFAULT 0,
+    reorder_point INT NOT NULL DEFAULT 0 CHECK (reorder_point >= 0),
+    PRIMARY KEY (product_id, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+    reservation_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    line_item_id INT REFERENCES estimate_items(line_item_id) ON DELETE SET NULL,
     product_id INT NOT NULL REFERENCES products(product_id),
-    quantity INT NOT NULL DEFAULT 1
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(10) NOT NULL DEFAULT 'reserved' CHECK (status IN ('reserved', 'consumed')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS inventory_adjustments (
+    adjustment_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id) ON DELETE CASCADE,
+    delta INT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    estimate_id INT REFERENCES estimates(estimate_id) ON DELETE SET NULL,
+    adjusted_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    po_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    vendor_id INT NOT NULL REFERENCES vendors(vendor_id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'sent', 'partially_received', 'received')),
+    expected_delivery DATE,
+    created_by INT NOT NULL REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    po_line_id SERIAL PRIMARY KEY,
+    po_id INT NOT NULL REFERENCES purchase_orders(po_id) ON DELETE CASCADE,
+    line_item_id INT REFERENCES estimate_items(line_item_id) ON DELETE SET NULL,
+    product_id INT REFERENCES products(product_id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    sku VARCHAR(50) NOT NULL DEFAULT '',
+    model_number VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_quantity INT NOT NULL DEFAULT 0 CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    unit_cost INT NOT NULL DEFAULT 0,
+    UNIQUE (line_item_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_prices (
+    price_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    unit_price INT NOT NULL CHECK (unit_price >= 0),
+    effective_from DATE NOT NULL,
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_prices_scheduled ON product_prices(product_id, effective_from)
+    WHERE applied_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS product_rules (
+    rule_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('requires', 'excludes', 'recommends')),
+    target_product_id INT REFERENCES products(product_id) ON DELETE CASCADE,
+    target_subcategory VARCHAR(50),
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((target_product_id IS NULL) <> (target_subcategory IS NULL)),
+    CHECK (target_product_id IS DISTINCT FROM product_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_rules_unique ON product_rules
+    (product_id, kind, COALESCE(target_product_id, 0), LOWER(COALESCE(target_subcategory, '')));
+CREATE INDEX IF NOT EXISTS idx_product_rules_target ON product_rules(target_product_id);
+
+CREATE TABLE IF NOT EXISTS site_requirements (
+    requirement_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_site_requirements_name ON site_requirements(LOWER(name));
+
+CREATE TABLE IF NOT EXISTS product_site_requirements (
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    requirement_id INT NOT NULL REFERENCES site_requirements(requirement_id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, requirement_id)
+);
+
+CREATE TABLE IF NOT EXISTS estimate_site_confirmations (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    requirement_id INT NOT NULL REFERENCES site_requirements(requirement_id) ON DELETE CASCADE,
+    confirmed_by INT REFERENCES users(user_id),
+    confirmed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, requirement_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_bundle_components (
+    bundle_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(product_id) ON DELETE RESTRICT,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id),
+    CHECK (bundle_id <> component_id)
+);
+
+CREATE TABLE IF NOT EXISTS product_price_tiers (
+    tier_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    basis VARCHAR(20) NOT NULL CHECK (basis IN ('quantity', 'order_value')),
+    threshold INT NOT NULL CHECK (threshold > 0),
+    unit_price INT NOT NULL CHECK (unit_price >= 0),
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, basis, threshold)
+);
+
+CREATE TABLE IF NOT EXISTS estimate_item_components (
+    line_item_id INT NOT NULL REFERENCES estimate_items(line_item_id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(product_id) ON DELETE RESTRICT,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (line_item_id, component_id)
 );
 `
 	_, err := db.Exec(schema)
@@ -126,7 +430,7 @@ CREATE TABLE IF NOT EXISTS estimate_items (
 
 func resetDB(t *testing.T) {
 	t.Helper()
-	_, err := testDB.Exec(`TRUNCATE estimate_items, estimates, products, users RESTART IDENTITY CASCADE;`)
+	_, err := testDB.Exec(`TRUNCATE estimate_item_components, product_price_tiers, product_bundle_components, estimate_site_confirmations, product_site_requirements, site_requirements, product_rules, product_prices, purchase_order_lines, purchase_orders, inventory_adjustments, inventory_reservations, inventory_stock, inventory_locations, product_audit, estimate_openings, estimate_shares, estimate_option_selections, estimate_options, estimate_responses, signature_audits, estimate_option_groups, invoice_access_tokens, estimate_items, estimates, products, product_families, vendors, users RESTART IDENTITY CASCADE;`)
 	if err != nil {
 		t.Fatalf("resetDB failed: %v", err)
 	}
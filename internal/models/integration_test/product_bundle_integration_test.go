@@ -0,0 +1,172 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductBundles(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	vendor := &models.Vendor{Name: "Appliance Depot"}
+	if err := vendorModel.Insert(vendor); err != nil {
+		t.Fatalf("Insert vendor failed: %v", err)
+	}
+
+	suite := &models.Product{Name: "Appliance Suite", Category: "Appliances", UnitPrice: 300000, CreatedBy: admin.ID}
+	rangeTop := &models.Product{Name: "Range", Category: "Appliances", UnitPrice: 90000, CreatedBy: admin.ID, VendorID: vendor.VendorID, VendorCost: 60000}
+	knob := &models.Product{Name: "Range Knob", Category: "Misc", UnitPrice: 500, CreatedBy: admin.ID, VendorID: vendor.VendorID, VendorCost: 200}
+	for _, p := range []*models.Product{suite, rangeTop, knob} {
+		if err := productModel.CreateAudited(p, admin.ID); err != nil {
+			t.Fatalf("CreateAudited failed: %v", err)
+		}
+	}
+
+	if err := productModel.SetBundleComponent(suite.ProductID, rangeTop.ProductID, 1, admin.ID); err != nil {
+		t.Fatalf("SetBundleComponent failed: %v", err)
+	}
+	if err := productModel.SetBundleComponent(suite.ProductID, knob.ProductID, 4, admin.ID); err != nil {
+		t.Fatalf("SetBundleComponent failed: %v", err)
+	}
+
+	// Bundles cannot contain themselves or other bundles, and components cannot become bundles.
+	if err := productModel.SetBundleComponent(suite.ProductID, suite.ProductID, 1, admin.ID); !errors.Is(err, models.ErrInvalidBundle) {
+		t.Errorf("expected ErrInvalidBundle for the bundle itself, got %v", err)
+	}
+	if err := productModel.SetBundleComponent(rangeTop.ProductID, knob.ProductID, 1, admin.ID); !errors.Is(err, models.ErrInvalidBundle) {
+		t.Errorf("expected ErrInvalidBundle for a component, got %v", err)
+	}
+	if err := productModel.SetBundleComponent(suite.ProductID, 9999, 1, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	if err := productModel.DeleteAudited(knob.ProductID, admin.ID); !errors.Is(err, models.ErrProductInUse) {
+		t.Errorf("expected ErrProductInUse for a component, got %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: suite.ProductID, Quantity: 2}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	estimateProducts, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(estimateProducts) != 1 || len(estimateProducts[0].Components) != 2 {
+		t.Fatalf("expected one bundle with two components, got %+v", estimateProducts)
+	}
+	components := estimateProducts[0].Components
+	if components[0].Product.Name != "Range" || components[1].Total(item.Quantity) != 8 {
+		t.Errorf("unexpected components %+v", components)
+	}
+
+	if err := estimateModel.UpdateStatus(estimate.EstimateID, models.StatusInProgress); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	// The bundle is ordered as its components.
+	poIDs, skipped, err := poModel.GenerateForEstimate(estimate.EstimateID, admin.ID)
+	if err != nil || len(poIDs) != 1 || skipped != 0 {
+		t.Fatalf("expected 1 purchase order and nothing skipped, got %v and %d (%v)", poIDs, skipped, err)
+	}
+	po, err := poModel.Get(poIDs[0])
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(po.Lines) != 2 || po.Total != 60000*2+200*8 {
+		t.Errorf("unexpected purchase order %+v", po)
+	}
+
+	if err := productModel.RemoveBundleComponent(suite.ProductID, knob.ProductID, admin.ID); err != nil {
+		t.Fatalf("RemoveBundleComponent failed: %v", err)
+	}
+	if err := productModel.RemoveBundleComponent(suite.ProductID, knob.ProductID, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	bundles, err := productModel.GetBundleComponents([]int{suite.ProductID, knob.ProductID})
+	if err != nil || len(bundles) != 1 || len(bundles[suite.ProductID]) != 1 {
+		t.Errorf("expected the suite to have only the range left, got %+v (%v)", bundles, err)
+	}
+}
+
+func TestEstimateBundleSnapshot(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	suite := &models.Product{Name: "Appliance Suite", Category: "Appliances", UnitPrice: 300000, CreatedBy: admin.ID}
+	rangeTop := &models.Product{Name: "Range", Category: "Appliances", UnitPrice: 90000, CreatedBy: admin.ID}
+	fridge := &models.Product{Name: "Fridge", Category: "Appliances", UnitPrice: 120000, CreatedBy: admin.ID}
+	for _, p := range []*models.Product{suite, rangeTop, fridge} {
+		if err := productModel.CreateAudited(p, admin.ID); err != nil {
+			t.Fatalf("CreateAudited failed: %v", err)
+		}
+	}
+	if err := productModel.SetBundleComponent(suite.ProductID, rangeTop.ProductID, 1, admin.ID); err != nil {
+		t.Fatalf("SetBundleComponent failed: %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: suite.ProductID, Quantity: 1}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	// Submitting the estimate freezes what its bundles are made of.
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := estimateModel.UpdateStatusTx(tx, estimate.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatusTx failed: %v", err)
+	}
+	if err := estimateItemModel.SnapshotPricesTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("SnapshotPricesTx failed: %v", err)
+	}
+	if err := estimateItemModel.SnapshotBundlesTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("SnapshotBundlesTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if err := productModel.SetBundleComponent(suite.ProductID, fridge.ProductID, 1, admin.ID); err != nil {
+		t.Fatalf("SetBundleComponent failed: %v", err)
+	}
+
+	products, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("GetByEstimateID failed: %+v (%v)", products, err)
+	}
+	if len(products[0].Components) != 1 || products[0].Components[0].Product.ProductID != rangeTop.ProductID {
+		t.Errorf("expected the submitted estimate to keep the range only, got %+v", products[0].Components)
+	}
+
+	// Going back to Draft follows the catalog again.
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := estimateItemModel.ClearPriceSnapshotTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("ClearPriceSnapshotTx failed: %v", err)
+	}
+	if err := estimateItemModel.ClearBundleSnapshotTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("ClearBundleSnapshotTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	products, err = estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil || len(products) != 1 || len(products[0].Components) != 2 {
+		t.Errorf("expected the draft to include the fridge, got %+v (%v)", products, err)
+	}
+}
@@ -0,0 +1,106 @@
+package integration_test
+
+import (
+	"bytes"
+	"errors"
+	"ezkitchen/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestProductCSVRoundTrip(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	p := createTestProduct(t, admin.ID)
+
+	var buf bytes.Buffer
+	if err := productModel.ExportCSV(&buf); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+
+	// An unedited export imports back without changes.
+	plan, err := productModel.PlanImport(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("PlanImport failed: %v", err)
+	}
+	if len(plan.Rows) != 1 || plan.Rows[0].Action != models.ImportUnchanged {
+		t.Fatalf("expected a single unchanged row, got %+v", plan.Rows)
+	}
+
+	edited := strings.Replace(buf.String(), "250.00", "275.50", 1) +
+		`,Apron Sink,,sinks & faucets,Sink,White,"$1,249.00",33,22,10` + "\n"
+
+	preview, err := productModel.PlanImport(strings.NewReader(edited), nil)
+	if err != nil {
+		t.Fatalf("PlanImport failed: %v", err)
+	}
+
+	// A file whose plan differs from the preview is not applied.
+	_, err = productModel.ApplyImport(strings.NewReader(buf.String()), nil, preview.Digest(), admin.ID)
+	if !errors.Is(err, models.ErrImportChanged) {
+		t.Fatalf("expected ErrImportChanged, got %v", err)
+	}
+
+	plan, err = productModel.ApplyImport(strings.NewReader(edited), nil, preview.Digest(), admin.ID)
+	if err != nil {
+		t.Fatalf("ApplyImport failed: %v", err)
+	}
+	if plan.Inserts() != 1 || plan.Updates() != 1 || plan.PriceChanges() != 1 {
+		t.Errorf("expected 1 insert and 1 price update, got %d inserts, %d updates, %d price changes",
+			plan.Inserts(), plan.Updates(), plan.PriceChanges())
+	}
+
+	got, err := productModel.Get(p.ProductID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.UnitPrice != 27550 {
+		t.Errorf("expected updated price 27550, got %d", got.UnitPrice)
+	}
+
+	inserted, err := productModel.Get(plan.Rows[1].Product.ProductID)
+	if err != nil {
+		t.Fatalf("Get inserted failed: %v", err)
+	}
+	if inserted.Category != "Sinks & Faucets" || inserted.UnitPrice != 124900 {
+		t.Errorf("unexpected inserted product: %+v", inserted)
+	}
+}
+
+func TestProductImportValidation(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+
+	file := "Item,Type,Kind,Cost\n" +
+		"Range Hood,Appliances,Hood,899\n" +
+		"Mystery,Gadgets,Thing,10\n"
+	mapping := map[string]string{"name": "Item", "category": "Type", "subcategory": "Kind", "unit_price": "Cost"}
+
+	_, err := productModel.PlanImport(strings.NewReader(file), nil)
+	if !errors.Is(err, models.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport without a mapping, got %v", err)
+	}
+
+	plan, err := productModel.PlanImport(strings.NewReader(file), mapping)
+	if err != nil {
+		t.Fatalf("PlanImport failed: %v", err)
+	}
+	if plan.Valid() || plan.ErrorRows() != 1 || plan.Rows[1].Line != 3 {
+		t.Fatalf("expected line 3 to fail validation, got %+v", plan.Rows)
+	}
+
+	_, err = productModel.ApplyImport(strings.NewReader(file), mapping, plan.Digest(), admin.ID)
+	if !errors.Is(err, models.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport, got %v", err)
+	}
+
+	products, err := productModel.GetByProductFilter("", "", "")
+	if err != nil {
+		t.Fatalf("GetByProductFilter failed: %v", err)
+	}
+	if len(products) != 0 {
+		t.Errorf("expected nothing to be imported, got %d products", len(products))
+	}
+}
@@ -0,0 +1,85 @@
+package integration_test
+
+import (
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductFamilyVariants(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+
+	family := &models.ProductFamily{
+		Name:        "Island Cabinet",
+		Description: "Kitchen island base cabinet",
+		Category:    "Cabinetry",
+		Subcategory: "Island Cabinet",
+		Length:      48,
+		Width:       36,
+		Height:      34.5,
+	}
+	if err := familyModel.Insert(family); err != nil {
+		t.Fatalf("Insert family failed: %v", err)
+	}
+
+	// Variants only need what sets them apart; the rest comes from the family.
+	for _, v := range []models.Product{
+		{Color: "Walnut", UnitPrice: 135000},
+		{Color: "White", UnitPrice: 120000},
+		{Color: "White", Size: "60 in", UnitPrice: 150000, Length: 60, Width: 36, Height: 34.5},
+	} {
+		v.FamilyID = family.FamilyID
+		v.CreatedBy = admin.ID
+		if err := productModel.CreateAudited(&v, admin.ID); err != nil {
+			t.Fatalf("CreateAudited failed: %v", err)
+		}
+	}
+	standalone := createTestProduct(t, admin.ID)
+
+	families, err := productModel.GetFamiliesByFilter("Cabinetry", "", "")
+	if err != nil {
+		t.Fatalf("GetFamiliesByFilter failed: %v", err)
+	}
+	if len(families) != 1 || len(families[0].Variants) != 3 {
+		t.Fatalf("expected one family with 3 variants, got %+v", families)
+	}
+
+	variants := families[0].Variants
+	if variants[0].Color != "White" || variants[0].Name != "Island Cabinet" || variants[0].Length != 48 {
+		t.Errorf("expected the cheapest variant first with the family's fields, got %+v", variants[0])
+	}
+	if variants[1].Length != 60 {
+		t.Errorf("expected the sized variant to keep its own length, got %v", variants[1].Length)
+	}
+
+	family.Description = "Island base cabinet with soft-close drawers"
+	family.Length = 50
+	if err := familyModel.Update(family, admin.ID); err != nil {
+		t.Fatalf("Update family failed: %v", err)
+	}
+
+	got, err := familyModel.Get(family.FamilyID)
+	if err != nil {
+		t.Fatalf("Get family failed: %v", err)
+	}
+	for _, v := range got.Variants {
+		if v.Description != family.Description {
+			t.Errorf("expected variant #%d to take the new description, got %q", v.ProductID, v.Description)
+		}
+		if v.Size == "" && v.Length != 50 {
+			t.Errorf("expected variant #%d to take the new length, got %v", v.ProductID, v.Length)
+		}
+		if v.Size != "" && v.Length != 60 {
+			t.Errorf("expected sized variant #%d to keep its length, got %v", v.ProductID, v.Length)
+		}
+	}
+
+	solo, err := productModel.GetFamiliesByFilter("Countertops", "", "")
+	if err != nil {
+		t.Fatalf("GetFamiliesByFilter failed: %v", err)
+	}
+	if len(solo) != 1 || solo[0].FamilyID != 0 || solo[0].Variants[0].ProductID != standalone.ProductID {
+		t.Errorf("expected the standalone product as a family of one, got %+v", solo)
+	}
+}
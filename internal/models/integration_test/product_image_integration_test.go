@@ -0,0 +1,70 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductSetImage(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	product := createTestProduct(t, admin.ID)
+
+	old, err := productModel.SetImage(product.ProductID, "products/1/100", admin.ID)
+	if err != nil || old != "" {
+		t.Fatalf("SetImage failed: %q, %v", old, err)
+	}
+
+	old, err = productModel.SetImage(product.ProductID, "products/1/200", admin.ID)
+	if err != nil || old != "products/1/100" {
+		t.Fatalf("expected the replaced key to be returned, got %q (%v)", old, err)
+	}
+
+	got, err := productModel.Get(product.ProductID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.ImageKey != "products/1/200" {
+		t.Errorf("expected the new image key, got %q", got.ImageKey)
+	}
+
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 1}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+	items, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if items[0].Product.ProductID != product.ProductID || items[0].Product.ImageKey != "products/1/200" {
+		t.Errorf("expected line items to carry the product image, got %+v", items[0].Product)
+	}
+
+	if _, err := productModel.SetImage(product.ProductID, "", admin.ID); err != nil {
+		t.Fatalf("SetImage to remove failed: %v", err)
+	}
+
+	audit, err := productModel.GetAudit(product.ProductID)
+	if err != nil {
+		t.Fatalf("GetAudit failed: %v", err)
+	}
+	images := 0
+	for _, entry := range audit {
+		for _, c := range entry.Changes {
+			if c.Field == "Image" {
+				images++
+			}
+		}
+	}
+	if images != 3 {
+		t.Errorf("expected 3 image changes in the audit log, got %d", images)
+	}
+
+	if _, err := productModel.SetImage(9999, "products/9999/1", admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+}
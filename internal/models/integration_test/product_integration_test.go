@@ -164,3 +164,67 @@ func TestProductFilter(t *testing.T) {
 		t.Errorf("Expected 1 Black product, got %+v", results)
 	}
 }
+
+func TestProductAuditedChanges(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+
+	p := &models.Product{
+		Name:        "Farmhouse Sink",
+		Category:    "Sinks & Faucets",
+		Subcategory: "Sink",
+		UnitPrice:   45000,
+		CreatedBy:   admin.ID,
+	}
+	if err := productModel.CreateAudited(p, admin.ID); err != nil {
+		t.Fatalf("CreateAudited failed: %v", err)
+	}
+
+	p.UnitPrice = 47500
+	if err := productModel.UpdateAudited(p, admin.ID); err != nil {
+		t.Fatalf("UpdateAudited failed: %v", err)
+	}
+
+	// Saving without changes must not add an entry.
+	if err := productModel.UpdateAudited(p, admin.ID); err != nil {
+		t.Fatalf("UpdateAudited failed: %v", err)
+	}
+
+	entries, err := productModel.GetAudit(p.ProductID)
+	if err != nil {
+		t.Fatalf("GetAudit failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+
+	update := entries[0]
+	if update.Action != models.AuditUpdate || update.ChangedByName != "Ada Admin" {
+		t.Errorf("unexpected latest entry: %+v", update)
+	}
+	if len(update.Changes) != 1 || update.Changes[0].Field != "Unit Price" {
+		t.Errorf("expected only the unit price to change, got %+v", update.Changes)
+	}
+
+	if err := productModel.DeleteAudited(p.ProductID, admin.ID); err != nil {
+		t.Fatalf("DeleteAudited failed: %v", err)
+	}
+
+	_, err = productModel.Get(p.ProductID)
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord after delete, got %v", err)
+	}
+
+	entries, err = productModel.GetAudit(p.ProductID)
+	if err != nil {
+		t.Fatalf("GetAudit failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Action != models.AuditDelete {
+		t.Errorf("expected the delete to be recorded, got %+v", entries)
+	}
+
+	if err := productModel.DeleteAudited(p.ProductID, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord deleting twice, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductDiscontinue(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	surveyor := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	old := createTestProduct(t, admin.ID)
+	replacement := createTestProduct(t, admin.ID)
+
+	draft := createTestEstimate(t, customer.ID, surveyor.ID)
+	item := &models.EstimateItem{EstimateID: draft.EstimateID, ProductID: old.ProductID, Quantity: 2}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	if err := productModel.Discontinue(old.ProductID, old.ProductID, admin.ID); !errors.Is(err, models.ErrInvalidReplacement) {
+		t.Fatalf("expected ErrInvalidReplacement for a self replacement, got %v", err)
+	}
+	if err := productModel.Discontinue(old.ProductID, replacement.ProductID, admin.ID); err != nil {
+		t.Fatalf("Discontinue failed: %v", err)
+	}
+
+	got, err := productModel.Get(old.ProductID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !got.Discontinued || got.ReplacedBy != replacement.ProductID {
+		t.Errorf("expected a discontinued product replaced by #%d, got %+v", replacement.ProductID, got)
+	}
+
+	offered, err := productModel.GetByProductFilter("Countertops", "", "")
+	if err != nil {
+		t.Fatalf("GetByProductFilter failed: %v", err)
+	}
+	if len(offered) != 1 || offered[0].ProductID != replacement.ProductID {
+		t.Errorf("expected only the replacement to be offered, got %+v", offered)
+	}
+
+	// The estimate keeps rendering the discontinued product.
+	products, err := estimateItemModel.GetByEstimateID(draft.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(products) != 1 || !products[0].Product.Discontinued {
+		t.Fatalf("expected the line item to be flagged as discontinued, got %+v", products)
+	}
+
+	suggestions, err := productModel.SuggestReplacements(got, 5)
+	if err != nil {
+		t.Fatalf("SuggestReplacements failed: %v", err)
+	}
+	if len(suggestions) != 1 || suggestions[0].ProductID != replacement.ProductID {
+		t.Errorf("expected the replacement to be suggested, got %+v", suggestions)
+	}
+
+	items, err := estimateItemModel.GetDiscontinuedDraftItems()
+	if err != nil {
+		t.Fatalf("GetDiscontinuedDraftItems failed: %v", err)
+	}
+	if len(items) != 1 || items[0].LineItemID != item.LineItemID || items[0].CustomerName != "John Smith" {
+		t.Fatalf("expected the draft line item to be listed, got %+v", items)
+	}
+
+	if err := estimateItemModel.ReplaceProduct(item.LineItemID, old.ProductID); !errors.Is(err, models.ErrInvalidReplacement) {
+		t.Errorf("expected ErrInvalidReplacement for a discontinued replacement, got %v", err)
+	}
+	if err := estimateItemModel.ReplaceProduct(item.LineItemID, replacement.ProductID); err != nil {
+		t.Fatalf("ReplaceProduct failed: %v", err)
+	}
+
+	replaced, err := estimateItemModel.GetByLineItemID(item.LineItemID)
+	if err != nil {
+		t.Fatalf("GetByLineItemID failed: %v", err)
+	}
+	if replaced.ProductID != replacement.ProductID || replaced.Quantity != 2 {
+		t.Errorf("expected the replacement with the same quantity, got %+v", replaced)
+	}
+
+	if err := estimateModel.UpdateStatus(draft.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+	if err := estimateItemModel.ReplaceProduct(item.LineItemID, old.ProductID); !errors.Is(err, models.ErrEstimateLocked) {
+		t.Errorf("expected ErrEstimateLocked once the estimate is submitted, got %v", err)
+	}
+
+	if err := productModel.Reactivate(old.ProductID, admin.ID); err != nil {
+		t.Fatalf("Reactivate failed: %v", err)
+	}
+
+	got, err = productModel.Get(old.ProductID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Discontinued || got.ReplacedBy != 0 {
+		t.Errorf("expected an active product without a replacement, got %+v", got)
+	}
+
+	audit, err := productModel.GetAudit(old.ProductID)
+	if err != nil {
+		t.Fatalf("GetAudit failed: %v", err)
+	}
+	if len(audit) < 2 {
+		t.Errorf("expected the discontinue and reactivate to be audited, got %d entries", len(audit))
+	}
+}
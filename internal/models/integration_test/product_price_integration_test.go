@@ -0,0 +1,174 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+	"time"
+)
+
+func TestProductScheduledPrices(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	product := &models.Product{Name: "Range", Category: "Appliances", UnitPrice: 10000, CreatedBy: admin.ID}
+	if err := productModel.CreateAudited(product, admin.ID); err != nil {
+		t.Fatalf("CreateAudited failed: %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 2}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	today := time.Now()
+	if err := productModel.SchedulePrice(product.ProductID, 12000, today, admin.ID); !errors.Is(err, models.ErrInvalidPriceDate) {
+		t.Errorf("expected ErrInvalidPriceDate for today, got %v", err)
+	}
+	if err := productModel.SchedulePrice(9999, 12000, today.AddDate(0, 0, 7), admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	// Scheduling the same day twice replaces the first price.
+	for _, price := range []int{11000, 12000} {
+		if err := productModel.SchedulePrice(product.ProductID, price, today.AddDate(0, 0, 7), admin.ID); err != nil {
+			t.Fatalf("SchedulePrice failed: %v", err)
+		}
+	}
+
+	scheduled, err := productModel.GetScheduledPrices()
+	if err != nil || len(scheduled) != 1 || scheduled[0].UnitPrice != 12000 {
+		t.Fatalf("expected one scheduled price of 12000, got %+v (%v)", scheduled, err)
+	}
+
+	impacts, err := productModel.GetUpcomingPriceImpact()
+	if err != nil {
+		t.Fatalf("GetUpcomingPriceImpact failed: %v", err)
+	}
+	if len(impacts) != 1 || impacts[0].EstimateID != estimate.EstimateID || impacts[0].Difference() != 4000 {
+		t.Errorf("expected the draft to go up by 4000, got %+v", impacts)
+	}
+
+	if n, err := productModel.ApplyScheduledPrices(); err != nil || n != 0 {
+		t.Errorf("expected nothing due yet, got %d (%v)", n, err)
+	}
+
+	// Move the scheduled price to today, as if a week had passed.
+	_, err = testDB.Exec(`UPDATE product_prices SET effective_from = CURRENT_DATE WHERE price_id = $1`, scheduled[0].PriceID)
+	if err != nil {
+		t.Fatalf("moving the scheduled price failed: %v", err)
+	}
+
+	if n, err := productModel.ApplyScheduledPrices(); err != nil || n != 1 {
+		t.Fatalf("expected 1 product repriced, got %d (%v)", n, err)
+	}
+
+	got, err := productModel.Get(product.ProductID)
+	if err != nil || got.UnitPrice != 12000 {
+		t.Errorf("expected the scheduled price to take effect, got %d (%v)", got.UnitPrice, err)
+	}
+
+	history, err := productModel.GetPriceHistory(product.ProductID)
+	if err != nil {
+		t.Fatalf("GetPriceHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].UnitPrice != 10000 || history[1].Scheduled() {
+		t.Errorf("expected the original price followed by the applied one, got %+v", history)
+	}
+
+	// Editing the price records it in the history too.
+	got.UnitPrice = 13000
+	if err := productModel.UpdateAudited(&got, admin.ID); err != nil {
+		t.Fatalf("UpdateAudited failed: %v", err)
+	}
+	history, _ = productModel.GetPriceHistory(product.ProductID)
+	if len(history) != 3 || history[2].UnitPrice != 13000 {
+		t.Errorf("expected the edited price in the history, got %+v", history)
+	}
+
+	if err := productModel.SchedulePrice(product.ProductID, 14000, today.AddDate(0, 1, 0), admin.ID); err != nil {
+		t.Fatalf("SchedulePrice failed: %v", err)
+	}
+	scheduled, _ = productModel.GetScheduledPrices()
+	productID, err := productModel.CancelScheduledPrice(scheduled[0].PriceID)
+	if err != nil || productID != product.ProductID {
+		t.Errorf("CancelScheduledPrice failed: %d (%v)", productID, err)
+	}
+	if _, err := productModel.CancelScheduledPrice(history[0].PriceID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected applied prices to be kept, got %v", err)
+	}
+}
+
+func TestEstimatePriceSnapshot(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	product := &models.Product{Name: "Range", Category: "Appliances", UnitPrice: 10000, CreatedBy: admin.ID}
+	if err := productModel.CreateAudited(product, admin.ID); err != nil {
+		t.Fatalf("CreateAudited failed: %v", err)
+	}
+	tier := &models.PriceTier{ProductID: product.ProductID, Basis: models.TierQuantity, Threshold: 2, UnitPrice: 9000}
+	if err := productModel.InsertPriceTier(tier, admin.ID); err != nil {
+		t.Fatalf("InsertPriceTier failed: %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: product.ProductID, Quantity: 2}
+	if err := estimateItemModel.Insert(item); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+
+	// Submitting the estimate freezes its prices.
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := estimateModel.UpdateStatusTx(tx, estimate.EstimateID, models.StatusAwaitingAgreement); err != nil {
+		t.Fatalf("UpdateStatusTx failed: %v", err)
+	}
+	if err := estimateItemModel.SnapshotPricesTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("SnapshotPricesTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	_, err = testDB.Exec(`UPDATE products SET unit_price = 15000 WHERE product_id = $1`, product.ProductID)
+	if err != nil {
+		t.Fatalf("repricing failed: %v", err)
+	}
+	if _, err := productModel.DeletePriceTier(tier.TierID, admin.ID); err != nil {
+		t.Fatalf("DeletePriceTier failed: %v", err)
+	}
+
+	products, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil || len(products) != 1 {
+		t.Fatalf("GetByEstimateID failed: %+v (%v)", products, err)
+	}
+	if products[0].Product.UnitPrice != 10000 || products[0].UnitPrice() != 9000 {
+		t.Errorf("expected the submitted estimate to keep its price and tier, got %d and %d",
+			products[0].Product.UnitPrice, products[0].UnitPrice())
+	}
+
+	// Going back to Draft prices it from the catalog again.
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := estimateItemModel.ClearPriceSnapshotTx(tx, estimate.EstimateID); err != nil {
+		t.Fatalf("ClearPriceSnapshotTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	products, err = estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil || len(products) != 1 || products[0].UnitPrice() != 15000 {
+		t.Errorf("expected the draft to be priced from the catalog, got %+v (%v)", products, err)
+	}
+}
@@ -0,0 +1,109 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductPriceTiers(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	cabinet := &models.Product{Name: "Base Cabinet", Category: "Cabinetry", UnitPrice: 20000, CreatedBy: admin.ID}
+	sink := &models.Product{Name: "Sink", Category: "Sinks & Faucets", UnitPrice: 30000, CreatedBy: admin.ID}
+	for _, p := range []*models.Product{cabinet, sink} {
+		if err := productModel.CreateAudited(p, admin.ID); err != nil {
+			t.Fatalf("CreateAudited failed: %v", err)
+		}
+	}
+
+	tiers := []*models.PriceTier{
+		{ProductID: cabinet.ProductID, Basis: models.TierQuantity, Threshold: 10, UnitPrice: 19000},
+		{ProductID: cabinet.ProductID, Basis: models.TierQuantity, Threshold: 20, UnitPrice: 18000},
+		{ProductID: sink.ProductID, Basis: models.TierOrderValue, Threshold: 400000, UnitPrice: 25000},
+	}
+	for _, tier := range tiers {
+		if err := productModel.InsertPriceTier(tier, admin.ID); err != nil {
+			t.Fatalf("InsertPriceTier failed: %v", err)
+		}
+	}
+
+	duplicate := &models.PriceTier{ProductID: cabinet.ProductID, Basis: models.TierQuantity, Threshold: 10, UnitPrice: 1}
+	if err := productModel.InsertPriceTier(duplicate, admin.ID); !errors.Is(err, models.ErrDuplicatePriceTier) {
+		t.Errorf("expected ErrDuplicatePriceTier, got %v", err)
+	}
+	missing := &models.PriceTier{ProductID: 9999, Basis: models.TierQuantity, Threshold: 5, UnitPrice: 1}
+	if err := productModel.InsertPriceTier(missing, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	cabinets := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: cabinet.ProductID, Quantity: 12}
+	sinks := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: sink.ProductID, Quantity: 1}
+	for _, item := range []*models.EstimateItem{cabinets, sinks} {
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert item failed: %v", err)
+		}
+	}
+
+	// 12 cabinets get the 10+ break. The estimate is worth $2,700 at list prices, short of the sink's $4,000 break.
+	estimateProducts, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if estimateProducts[0].AppliedTier == nil || estimateProducts[0].UnitPrice() != 19000 {
+		t.Errorf("expected the cabinets at $190, got %+v", estimateProducts[0])
+	}
+	if estimateProducts[1].AppliedTier != nil || estimateProducts[1].UnitPrice() != 30000 {
+		t.Errorf("expected the sink at list price, got %+v", estimateProducts[1])
+	}
+
+	totals := estimateModel.CalculateEstimateTotals(estimateProducts)
+	if totals.Subtotal != 19000*12+30000 {
+		t.Errorf("expected a subtotal of %d, got %d", 19000*12+30000, totals.Subtotal)
+	}
+
+	// An optional add-on that is accepted pushes the estimate past the sink's order value break.
+	addon := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: cabinet.ProductID, Quantity: 8, IsOptional: true}
+	if err := estimateItemModel.Insert(addon); err != nil {
+		t.Fatalf("Insert addon failed: %v", err)
+	}
+	estimateProducts, err = estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if estimateProducts[1].AppliedTier != nil {
+		t.Errorf("expected add-ons to be left out of the order value, got %+v", estimateProducts[1].AppliedTier)
+	}
+	totals = estimateModel.CalculateEstimateTotals(estimateProducts)
+	if totals.Subtotal != 19000*12+25000+20000*8 {
+		t.Errorf("expected a subtotal of %d with the add-on, got %d", 19000*12+25000+20000*8, totals.Subtotal)
+	}
+
+	productID, err := productModel.DeletePriceTier(tiers[0].TierID, admin.ID)
+	if err != nil || productID != cabinet.ProductID {
+		t.Errorf("expected DeletePriceTier to return the cabinet, got %d (%v)", productID, err)
+	}
+	if _, err := productModel.DeletePriceTier(tiers[0].TierID, admin.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	remaining, err := productModel.GetPriceTiers([]int{cabinet.ProductID, sink.ProductID})
+	if err != nil || len(remaining[cabinet.ProductID]) != 1 || len(remaining[sink.ProductID]) != 1 {
+		t.Errorf("expected one break left on each product, got %+v (%v)", remaining, err)
+	}
+
+	// Tiers only apply when they lower the price.
+	options := []models.EstimateProduct{{
+		Product:      models.Product{UnitPrice: 100},
+		EstimateItem: models.EstimateItem{Quantity: 5},
+		PriceTiers:   []models.PriceTier{{Basis: models.TierQuantity, Threshold: 2, UnitPrice: 150}},
+	}}
+	models.ApplyPriceTiers(options, 0)
+	if options[0].AppliedTier != nil {
+		t.Errorf("expected a dearer tier to be ignored, got %+v", options[0].AppliedTier)
+	}
+}
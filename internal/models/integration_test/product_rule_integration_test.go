@@ -0,0 +1,118 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestProductRules(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	sink := createTestProduct(t, admin.ID)
+	base := createTestProduct(t, admin.ID)
+
+	rule := &models.ProductRule{ProductID: sink.ProductID, Kind: "requires", TargetSubcategory: "Sink Base", CreatedBy: admin.ID}
+	if err := ruleModel.Insert(rule); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	duplicate := &models.ProductRule{ProductID: sink.ProductID, Kind: "requires", TargetSubcategory: "sink base"}
+	if err := ruleModel.Insert(duplicate); !errors.Is(err, models.ErrDuplicateRule) {
+		t.Errorf("expected ErrDuplicateRule, got %v", err)
+	}
+
+	self := &models.ProductRule{ProductID: sink.ProductID, Kind: "excludes", TargetProductID: sink.ProductID}
+	if err := ruleModel.Insert(self); !errors.Is(err, models.ErrInvalidRule) {
+		t.Errorf("expected ErrInvalidRule, got %v", err)
+	}
+
+	missing := &models.ProductRule{ProductID: sink.ProductID, Kind: "excludes", TargetProductID: 9999}
+	if err := ruleModel.Insert(missing); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	excludes := &models.ProductRule{ProductID: base.ProductID, Kind: "excludes", TargetProductID: sink.ProductID, Note: "Too shallow."}
+	if err := ruleModel.Insert(excludes); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// The sink's page shows its own rule first, then the rule that targets it.
+	involving, err := ruleModel.GetInvolving(sink.ProductID)
+	if err != nil {
+		t.Fatalf("GetInvolving failed: %v", err)
+	}
+	if len(involving) != 2 || involving[0].RuleID != rule.RuleID || involving[1].RuleID != excludes.RuleID {
+		t.Fatalf("expected the sink rule then the base rule, got %+v", involving)
+	}
+	if involving[0].TargetLabel() != "any Sink Base" || involving[1].TargetLabel() != sink.Name {
+		t.Errorf("unexpected target labels %q and %q", involving[0].TargetLabel(), involving[1].TargetLabel())
+	}
+
+	rules, err := ruleModel.GetForProducts([]int{base.ProductID})
+	if err != nil || len(rules) != 1 || rules[0].RuleID != excludes.RuleID {
+		t.Errorf("expected only the base rule, got %+v (%v)", rules, err)
+	}
+
+	productID, err := ruleModel.Delete(rule.RuleID)
+	if err != nil || productID != sink.ProductID {
+		t.Errorf("expected Delete to return the sink, got %d (%v)", productID, err)
+	}
+	if _, err := ruleModel.Delete(rule.RuleID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+}
+
+func TestSiteRequirements(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	product := createTestProduct(t, admin.ID)
+
+	// Names are shared between products regardless of case.
+	for _, name := range []string{"240V circuit", " 240v Circuit ", "Gas line"} {
+		if err := ruleModel.AddProductRequirement(product.ProductID, name); err != nil {
+			t.Fatalf("AddProductRequirement(%q) failed: %v", name, err)
+		}
+	}
+	if err := ruleModel.AddProductRequirement(9999, "Gas line"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	all, err := ruleModel.GetRequirements()
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected two site requirements, got %+v (%v)", all, err)
+	}
+
+	requirements, err := ruleModel.GetProductRequirements([]int{product.ProductID})
+	if err != nil || len(requirements) != 2 || requirements[0].Name != "240V circuit" {
+		t.Fatalf("expected the circuit and the gas line, got %+v (%v)", requirements, err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	ids := []int{requirements[0].RequirementID, requirements[1].RequirementID}
+	if err := ruleModel.SetConfirmedRequirements(estimate.EstimateID, ids, admin.ID); err != nil {
+		t.Fatalf("SetConfirmedRequirements failed: %v", err)
+	}
+	if err := ruleModel.SetConfirmedRequirements(estimate.EstimateID, ids[1:], admin.ID); err != nil {
+		t.Fatalf("SetConfirmedRequirements failed: %v", err)
+	}
+
+	confirmed, err := ruleModel.GetConfirmedRequirements(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetConfirmedRequirements failed: %v", err)
+	}
+	if len(confirmed) != 1 || !confirmed[ids[1]] {
+		t.Errorf("expected only the gas line to stay confirmed, got %v", confirmed)
+	}
+
+	if err := ruleModel.RemoveProductRequirement(product.ProductID, ids[0]); err != nil {
+		t.Fatalf("RemoveProductRequirement failed: %v", err)
+	}
+	requirements, err = ruleModel.GetProductRequirements([]int{product.ProductID})
+	if err != nil || len(requirements) != 1 {
+		t.Errorf("expected one requirement left, got %+v (%v)", requirements, err)
+	}
+}
@@ -0,0 +1,122 @@
+package integration_test
+
+import (
+	"ezkitchen/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestProductSearch(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	user := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	for _, p := range []models.Product{
+		{Name: "Bosch 800 Series Dishwasher", Description: "Whisper quiet 42 dBA dishwasher", Color: "Stainless", Length: 24, Width: 24, Height: 34},
+		{Name: "GE Compact Dishwasher", Description: "Portable dishwasher for small kitchens", Color: "White", Length: 22, Width: 18, Height: 34},
+		{Name: "LG French Door Refrigerator", Description: "Counter depth refrigerator", Color: "Stainless", Length: 30, Width: 36, Height: 70},
+	} {
+		p.Category = "Appliances"
+		p.Subcategory = "Dishwasher"
+		p.UnitPrice = 90000
+		p.CreatedBy = user.ID
+		if err := productModel.Insert(&p); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	results, err := productModel.Search(models.ParseProductSearch("quiet dishwasher stainless"))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 3 || results[0].Name != "Bosch 800 Series Dishwasher" {
+		t.Errorf("expected the quiet stainless dishwasher first, got %+v", results)
+	}
+
+	// A typo still matches through trigram similarity.
+	results, err = productModel.Search(models.ParseProductSearch("dishwaser"))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected both dishwashers for a misspelled search, got %+v", results)
+	}
+
+	results, err = productModel.Search(models.ParseProductSearch("dishwasher max width 20in"))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "GE Compact Dishwasher" {
+		t.Errorf("expected only the compact dishwasher under 20in wide, got %+v", results)
+	}
+
+	results, err = productModel.Search(models.ProductSearch{Category: "Appliances", Color: "White"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected filters alone to narrow the results, got %+v", results)
+	}
+
+	results, err = productModel.Search(models.ParseProductSearch("sofa"))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no results for an unrelated search, got %+v", results)
+	}
+}
+
+func TestProductSearchPage(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	user := createTestUser(t, "Daniel Surveyor", "boss@example.com", "surveyor")
+
+	for _, p := range []models.Product{
+		{Name: "Bosch Dishwasher", Category: "Appliances", Subcategory: "Dishwasher", Color: "Stainless"},
+		{Name: "GE Dishwasher", Category: "Appliances", Subcategory: "Dishwasher", Color: "White"},
+		{Name: "LG Refrigerator", Category: "Appliances", Subcategory: "Refrigerator", Color: "Stainless"},
+		{Name: "Farmhouse Sink", Category: "Sinks & Faucets", Subcategory: "Sink", Color: "White"},
+	} {
+		p.UnitPrice = 50000
+		p.CreatedBy = user.ID
+		if err := productModel.Insert(&p); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	page, err := productModel.SearchPage(models.ProductSearch{Category: "Appliances"}, 2, 0)
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if page.Total != 3 || len(page.Products) != 2 {
+		t.Fatalf("expected 2 of 3 appliances, got %d of %d", len(page.Products), page.Total)
+	}
+
+	// Facets cover every match, not only the page.
+	want := []models.Facet{{Value: "Dishwasher", Count: 2}, {Value: "Refrigerator", Count: 1}}
+	if !reflect.DeepEqual(page.Facets.Subcategories, want) {
+		t.Errorf("expected subcategory facets %+v, got %+v", want, page.Facets.Subcategories)
+	}
+	want = []models.Facet{{Value: "Stainless", Count: 2}, {Value: "White", Count: 1}}
+	if !reflect.DeepEqual(page.Facets.Colors, want) {
+		t.Errorf("expected color facets %+v, got %+v", want, page.Facets.Colors)
+	}
+
+	page, err = productModel.SearchPage(models.ProductSearch{Category: "Appliances"}, 2, 2)
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	if page.Total != 3 || len(page.Products) != 1 || page.Products[0].Name != "LG Refrigerator" {
+		t.Errorf("expected the refrigerator on the second page, got %+v", page.Products)
+	}
+
+	page, err = productModel.SearchPage(models.ParseProductSearch("white"), 10, 0)
+	if err != nil {
+		t.Fatalf("SearchPage failed: %v", err)
+	}
+	wantCategories := []models.Facet{{Value: "Appliances", Count: 1}, {Value: "Sinks & Faucets", Count: 1}}
+	if page.Total != 2 || !reflect.DeepEqual(page.Facets.Categories, wantCategories) {
+		t.Errorf("expected one white appliance and one white sink, got %d %+v", page.Total, page.Facets.Categories)
+	}
+}
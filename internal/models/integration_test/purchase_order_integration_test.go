@@ -0,0 +1,140 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestPurchaseOrderGenerateAndReceive(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	stone := &models.Vendor{Name: "Stone Supply Co"}
+	appliances := &models.Vendor{Name: "Appliance Depot"}
+	for _, v := range []*models.Vendor{stone, appliances} {
+		if err := vendorModel.Insert(v); err != nil {
+			t.Fatalf("Insert vendor failed: %v", err)
+		}
+	}
+
+	products := []*models.Product{
+		{Name: "Granite Slab", Category: "Countertops", UnitPrice: 20000, CreatedBy: admin.ID, SKU: "GR-1", VendorID: stone.VendorID, VendorCost: 12000, LeadTimeDays: 14},
+		{Name: "Quartz Slab", Category: "Countertops", UnitPrice: 25000, CreatedBy: admin.ID, SKU: "QZ-1", VendorID: stone.VendorID, VendorCost: 15000, LeadTimeDays: 21},
+		{Name: "Range", Category: "Appliances", UnitPrice: 90000, CreatedBy: admin.ID, SKU: "RG-1", VendorID: appliances.VendorID, VendorCost: 60000},
+		{Name: "Cabinet Pulls", Category: "Misc", UnitPrice: 500, CreatedBy: admin.ID},
+	}
+	for _, p := range products {
+		if err := productModel.CreateAudited(p, admin.ID); err != nil {
+			t.Fatalf("CreateAudited failed: %v", err)
+		}
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	for i, p := range products {
+		item := &models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: p.ProductID, Quantity: i + 1}
+		if err := estimateItemModel.Insert(item); err != nil {
+			t.Fatalf("Insert item failed: %v", err)
+		}
+	}
+
+	if _, _, err := poModel.GenerateForEstimate(estimate.EstimateID, admin.ID); !errors.Is(err, models.ErrEstimateNotSigned) {
+		t.Fatalf("expected ErrEstimateNotSigned for a draft, got %v", err)
+	}
+
+	if err := estimateModel.UpdateStatus(estimate.EstimateID, models.StatusInProgress); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	poIDs, skipped, err := poModel.GenerateForEstimate(estimate.EstimateID, admin.ID)
+	if err != nil {
+		t.Fatalf("GenerateForEstimate failed: %v", err)
+	}
+	if len(poIDs) != 2 || skipped != 1 {
+		t.Fatalf("expected 2 purchase orders and 1 skipped item, got %v and %d", poIDs, skipped)
+	}
+
+	// Generating again finds nothing new to order.
+	again, _, err := poModel.GenerateForEstimate(estimate.EstimateID, admin.ID)
+	if err != nil || len(again) != 0 {
+		t.Fatalf("expected no new purchase orders, got %v (%v)", again, err)
+	}
+
+	orders, err := poModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("expected 2 purchase orders for the estimate, got %d", len(orders))
+	}
+
+	var stonePO models.PurchaseOrder
+	for _, po := range orders {
+		if po.VendorID == stone.VendorID {
+			stonePO, err = poModel.Get(po.POID)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+		}
+	}
+	if len(stonePO.Lines) != 2 || stonePO.Total != 12000*1+15000*2 || stonePO.Status != models.POStatusDraft {
+		t.Fatalf("unexpected stone purchase order: %+v", stonePO)
+	}
+	if !stonePO.ExpectedDelivery.Valid {
+		t.Errorf("expected a delivery date from the vendor lead time")
+	}
+	if stonePO.CustomerName != "John Smith" {
+		t.Errorf("expected the customer of the estimate, got %q", stonePO.CustomerName)
+	}
+
+	if err := poModel.Receive(stonePO.POID, map[int]int{stonePO.Lines[0].POLineID: 1}); !errors.Is(err, models.ErrPurchaseOrderStatus) {
+		t.Errorf("expected ErrPurchaseOrderStatus when receiving a draft, got %v", err)
+	}
+
+	if err := poModel.MarkSent(stonePO.POID); err != nil {
+		t.Fatalf("MarkSent failed: %v", err)
+	}
+	if err := poModel.MarkSent(stonePO.POID); !errors.Is(err, models.ErrPurchaseOrderStatus) {
+		t.Errorf("expected ErrPurchaseOrderStatus when sending twice, got %v", err)
+	}
+	if err := poModel.MarkSent(9999); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord, got %v", err)
+	}
+
+	if err := poModel.Receive(stonePO.POID, map[int]int{stonePO.Lines[0].POLineID: 1, stonePO.Lines[1].POLineID: 1}); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	got, _ := poModel.Get(stonePO.POID)
+	if got.Status != models.POStatusPartiallyReceived {
+		t.Errorf("expected partially received, got %s", got.Status)
+	}
+
+	if err := poModel.Receive(stonePO.POID, map[int]int{stonePO.Lines[1].POLineID: 5}); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	got, _ = poModel.Get(stonePO.POID)
+	if got.Status != models.POStatusReceived || got.Lines[1].ReceivedQuantity != 2 {
+		t.Errorf("expected a fully received order capped at the quantity ordered, got %+v", got)
+	}
+
+	received, err := poModel.List(models.POStatusReceived)
+	if err != nil || len(received) != 1 {
+		t.Errorf("expected 1 received purchase order, got %d (%v)", len(received), err)
+	}
+
+	var buf bytes.Buffer
+	if err := got.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV failed: %v", err)
+	}
+	if len(records) != 3 || records[1][3] != "GR-1" || records[2][8] != "150.00" {
+		t.Errorf("unexpected CSV: %v", records)
+	}
+}
@@ -0,0 +1,122 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureAudit(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+
+	if _, err := auditModel.GetByEstimateID(estimate.EstimateID); !errors.Is(err, models.ErrNoRecord) {
+		t.Fatalf("expected ErrNoRecord before signing, got %v", err)
+	}
+
+	raw, err := tokenModel.Insert(estimate.EstimateID, time.Now().Add(72*time.Hour), admin.ID)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	it, err := tokenModel.GetByRawToken(raw)
+	if err != nil {
+		t.Fatalf("GetByRawToken failed: %v", err)
+	}
+
+	// Only the first open is recorded.
+	if err := tokenModel.MarkOpened(it.InvoiceTokenID); err != nil {
+		t.Fatalf("MarkOpened failed: %v", err)
+	}
+	opened, err := tokenModel.GetByRawToken(raw)
+	if err != nil || !opened.OpenedAt.Valid {
+		t.Fatalf("expected the link to record when it was opened, got %+v (%v)", opened, err)
+	}
+	if err := tokenModel.MarkOpened(it.InvoiceTokenID); err != nil {
+		t.Fatalf("MarkOpened failed: %v", err)
+	}
+	reopened, err := tokenModel.GetByRawToken(raw)
+	if err != nil || !reopened.OpenedAt.Time.Equal(opened.OpenedAt.Time) {
+		t.Errorf("expected a second open to keep the first time, got %v and %v", opened.OpenedAt.Time, reopened.OpenedAt.Time)
+	}
+
+	tx, err := testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := estimateModel.SetSignatureKeyTx(tx, estimate.EstimateID, "signatures/1.png"); err != nil {
+		t.Fatalf("SetSignatureKeyTx failed: %v", err)
+	}
+	audit := models.SignatureAudit{
+		EstimateID:     estimate.EstimateID,
+		InvoiceTokenID: it.InvoiceTokenID,
+		SignerName:     "John Smith",
+		SignerEmail:    "john@example.com",
+		IPAddress:      "203.0.113.7",
+		UserAgent:      "Mozilla/5.0 (test)",
+		LinkSentAt:     opened.CreatedAt,
+		LinkOpenedAt:   opened.OpenedAt,
+		ConsentVersion: "2026-10-19",
+		ConsentText:    "I agree to sign electronically.",
+	}
+	if err := auditModel.InsertTx(tx, &audit); err != nil {
+		t.Fatalf("InsertTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	signed, err := estimateModel.Get(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !signed.SignedAt.Valid || !signed.SignedAt.Time.Equal(audit.SignedAt) {
+		t.Errorf("expected the audit to share the estimate's signing time, got %v and %v", signed.SignedAt.Time, audit.SignedAt)
+	}
+
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := auditModel.SetAgreementHashTx(tx, estimate.EstimateID, hash); err != nil {
+		t.Fatalf("SetAgreementHashTx failed: %v", err)
+	}
+	if err := auditModel.SetCertificateKeyTx(tx, estimate.EstimateID, "certificates/1.pdf"); err != nil {
+		t.Fatalf("SetCertificateKeyTx failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// The hash of what was signed is never replaced.
+	tx, err = testDB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	err = auditModel.SetAgreementHashTx(tx, estimate.EstimateID, strings.Repeat("0", 64))
+	if !errors.Is(err, models.ErrAgreementRecorded) {
+		t.Errorf("expected ErrAgreementRecorded, got %v", err)
+	}
+	if err := auditModel.SetAgreementHashTx(tx, 9999, hash); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord for an unsigned estimate, got %v", err)
+	}
+	tx.Rollback()
+
+	got, err := auditModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+	if got.IPAddress != "203.0.113.7" || got.UserAgent != "Mozilla/5.0 (test)" || got.ConsentVersion != "2026-10-19" {
+		t.Errorf("expected the signing details to be kept, got %+v", got)
+	}
+	if !got.LinkOpenedAt.Valid || got.AgreementSHA256.String != hash || got.CertificateObjectKey.String != "certificates/1.pdf" {
+		t.Errorf("expected the open time, hash and certificate to be recorded, got %+v", got)
+	}
+}
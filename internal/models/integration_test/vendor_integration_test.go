@@ -0,0 +1,81 @@
+package integration_test
+
+import (
+	"errors"
+	"ezkitchen/internal/models"
+	"testing"
+)
+
+func TestVendorSKUAndMargins(t *testing.T) {
+	t.Cleanup(func() { resetDB(t) })
+
+	admin := createTestUser(t, "Ada Admin", "ada@example.com", "admin")
+	customer := createTestUser(t, "John Smith", "john@example.com", "customer")
+
+	vendor := &models.Vendor{Name: "Stone Supply Co", ContactName: "Sam", Email: "sam@stone.example"}
+	if err := vendorModel.Insert(vendor); err != nil {
+		t.Fatalf("Insert vendor failed: %v", err)
+	}
+	if err := vendorModel.Insert(&models.Vendor{Name: "Stone Supply Co"}); !errors.Is(err, models.ErrDuplicateVendor) {
+		t.Errorf("expected ErrDuplicateVendor, got %v", err)
+	}
+
+	p := models.Product{
+		Name:         "Granite Slab",
+		Category:     "Countertops",
+		Subcategory:  "Granite",
+		Color:        "Black",
+		UnitPrice:    20000,
+		CreatedBy:    admin.ID,
+		SKU:          "GR-BLK-01",
+		ModelNumber:  "SS-4410",
+		VendorID:     vendor.VendorID,
+		VendorCost:   12000,
+		LeadTimeDays: 14,
+	}
+	if err := productModel.CreateAudited(&p, admin.ID); err != nil {
+		t.Fatalf("CreateAudited failed: %v", err)
+	}
+
+	dup := p
+	dup.ProductID = 0
+	if err := productModel.CreateAudited(&dup, admin.ID); !errors.Is(err, models.ErrDuplicateSKU) {
+		t.Errorf("expected ErrDuplicateSKU, got %v", err)
+	}
+
+	got, err := productModel.GetBySKU("GR-BLK-01")
+	if err != nil {
+		t.Fatalf("GetBySKU failed: %v", err)
+	}
+	if got.ProductID != p.ProductID || got.VendorID != vendor.VendorID || got.VendorCost != 12000 || got.LeadTimeDays != 14 {
+		t.Errorf("unexpected product from SKU lookup: %+v", got)
+	}
+	if _, err := productModel.GetBySKU("NOPE"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("expected ErrNoRecord for an unknown SKU, got %v", err)
+	}
+
+	estimate := createTestEstimate(t, customer.ID, admin.ID)
+	if err := estimateItemModel.Insert(&models.EstimateItem{EstimateID: estimate.EstimateID, ProductID: p.ProductID, Quantity: 2}); err != nil {
+		t.Fatalf("Insert item failed: %v", err)
+	}
+	custom := &models.EstimateItem{EstimateID: estimate.EstimateID, Quantity: 1, CustomDescription: "Drywall repair", CustomUnitPrice: 5000, CustomCategory: "Misc", Taxable: true}
+	if err := estimateItemModel.Insert(custom); err != nil {
+		t.Fatalf("Insert custom item failed: %v", err)
+	}
+
+	products, err := estimateItemModel.GetByEstimateID(estimate.EstimateID)
+	if err != nil {
+		t.Fatalf("GetByEstimateID failed: %v", err)
+	}
+
+	margins := models.CalculateMargins(products)
+	if margins.Revenue != 40000 || margins.Cost != 24000 || margins.Margin() != 16000 {
+		t.Errorf("unexpected estimate margin: revenue %d, cost %d", margins.Revenue, margins.Cost)
+	}
+	if margins.Percent() != 40 {
+		t.Errorf("expected a 40%% margin, got %v", margins.Percent())
+	}
+	if margins.UnknownCost != 1 || margins.Lines[1].HasCost {
+		t.Errorf("expected the custom line item to be left out, got %+v", margins)
+	}
+}
@@ -0,0 +1,516 @@
+// models/inventory.go tracks stock per product and location. Signing an estimate reserves stock for its line items
+// and completing it consumes the reserved stock. Line items cannot change once an estimate is signed, and deleting
+// an estimate deletes its reservations, so reserved stock never needs releasing by hand. Every change to the
+// quantity on hand is written to a ledger of adjustments. Products without a stock row at any location are not
+// tracked.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reservation statuses.
+const (
+	ReservationReserved = "reserved"
+	ReservationConsumed = "consumed"
+)
+
+// InventoryLocation is a place stock is kept, such as a warehouse or a truck.
+type InventoryLocation struct {
+	LocationID int
+	Name       string
+}
+
+// StockLevel is the stock of a product at one location. Reserved is held for signed estimates.
+type StockLevel struct {
+	ProductID    int
+	ProductName  string
+	SKU          string
+	LocationID   int
+	LocationName string
+	OnHand       int
+	Reserved     int
+	ReorderPoint int
+}
+
+// Available returns the stock that is not reserved.
+func (s StockLevel) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+// Low reports whether the available stock is at or below the reorder point.
+func (s StockLevel) Low() bool {
+	return s.Available() <= s.ReorderPoint
+}
+
+// ProductStock is the stock of a product across every location.
+type ProductStock struct {
+	ProductID    int
+	OnHand       int
+	Reserved     int
+	ReorderPoint int
+}
+
+// Available returns the stock that is not reserved.
+func (s ProductStock) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+// Warning describes a stock problem with selling quantity units, or returns "" when there is none.
+func (s ProductStock) Warning(quantity int) string {
+	available := s.Available()
+
+	switch {
+	case available <= 0:
+		return "Out of stock."
+	case available < quantity:
+		return fmt.Sprintf("Only %d in stock.", available)
+	case available-quantity <= s.ReorderPoint:
+		return fmt.Sprintf("Low stock: %d left after this estimate.", available-quantity)
+	}
+	return ""
+}
+
+// InventoryAdjustment is an entry in the ledger of stock changes. EstimateID is set when the change came from
+// completing an estimate.
+type InventoryAdjustment struct {
+	AdjustmentID   int
+	ProductID      int
+	ProductName    string
+	LocationID     int
+	LocationName   string
+	Delta          int
+	Reason         string
+	EstimateID     int
+	AdjustedBy     int
+	AdjustedByName string
+	CreatedAt      time.Time
+}
+
+// InventoryReservation is stock held at a location for a line item of a signed estimate.
+type InventoryReservation struct {
+	ReservationID int
+	EstimateID    int
+	CustomerName  string
+	ProductID     int
+	ProductName   string
+	LocationName  string
+	Quantity      int
+	CreatedAt     time.Time
+}
+
+// InventoryModel wraps a sql.DB connection and provides methods for stock, reservations and adjustments.
+type InventoryModel struct {
+	DB *sql.DB
+}
+
+// GetLocations returns every location ordered by name.
+func (m *InventoryModel) GetLocations() ([]InventoryLocation, error) {
+	rows, err := m.DB.Query(`SELECT location_id, name FROM inventory_locations ORDER BY name, location_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []InventoryLocation
+	for rows.Next() {
+		var l InventoryLocation
+		if err := rows.Scan(&l.LocationID, &l.Name); err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
+
+// InsertLocation adds a location and assigns the generated LocationID to the struct.
+// Returns ErrDuplicateLocation if a location with the same name exists.
+func (m *InventoryModel) InsertLocation(l *InventoryLocation) error {
+	err := m.DB.QueryRow(`INSERT INTO inventory_locations (name) VALUES ($1) RETURNING location_id`, l.Name).
+		Scan(&l.LocationID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateLocation
+	}
+	return err
+}
+
+// GetStockLevels returns the stock of every tracked product at every location it is kept.
+func (m *InventoryModel) GetStockLevels() ([]StockLevel, error) {
+	stmt := `SELECT s.product_id, p.name, COALESCE(p.sku, ''), s.location_id, l.name, s.on_hand,
+	COALESCE(r.reserved, 0), s.reorder_point
+	FROM inventory_stock s
+	JOIN products p ON p.product_id = s.product_id
+	JOIN inventory_locations l ON l.location_id = s.location_id
+	LEFT JOIN (
+		SELECT product_id, location_id, SUM(quantity) AS reserved
+		FROM inventory_reservations WHERE status = 'reserved'
+		GROUP BY product_id, location_id
+	) r ON r.product_id = s.product_id AND r.location_id = s.location_id
+	ORDER BY p.name, s.product_id, l.name`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []StockLevel
+	for rows.Next() {
+		var s StockLevel
+		err := rows.Scan(&s.ProductID, &s.ProductName, &s.SKU, &s.LocationID, &s.LocationName, &s.OnHand,
+			&s.Reserved, &s.ReorderPoint)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return levels, nil
+}
+
+// GetProductStock returns the stock of the given products across every location. Products that are not tracked
+// are left out of the map.
+func (m *InventoryModel) GetProductStock(productIDs []int) (map[int]ProductStock, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	stmt := `SELECT s.product_id, SUM(s.on_hand), SUM(s.reorder_point),
+	COALESCE((SELECT SUM(r.quantity) FROM inventory_reservations r
+	          WHERE r.product_id = s.product_id AND r.status = 'reserved'), 0)
+	FROM inventory_stock s
+	WHERE s.product_id = ANY($1)
+	GROUP BY s.product_id`
+
+	rows, err := m.DB.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stock := make(map[int]ProductStock)
+	for rows.Next() {
+		var s ProductStock
+		if err := rows.Scan(&s.ProductID, &s.OnHand, &s.ReorderPoint, &s.Reserved); err != nil {
+			return nil, err
+		}
+		stock[s.ProductID] = s
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return stock, nil
+}
+
+// Adjust changes the stock of a product at a location by a.Delta and records the change in the ledger. The product
+// becomes tracked at the location if it was not already. Returns ErrNoRecord if the product or location does not
+// exist.
+func (m *InventoryModel) Adjust(a *InventoryAdjustment) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = adjustStock(tx, a)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetReorderPoint sets the stock level at which a product is low at a location. The product becomes tracked at the
+// location if it was not already. Returns ErrNoRecord if the product or location does not exist.
+func (m *InventoryModel) SetReorderPoint(productID, locationID, reorderPoint int) error {
+	stmt := `INSERT INTO inventory_stock (product_id, location_id, reorder_point) VALUES ($1, $2, $3)
+	ON CONFLICT (product_id, location_id) DO UPDATE SET reorder_point = EXCLUDED.reorder_point`
+
+	_, err := m.DB.Exec(stmt, productID, locationID, reorderPoint)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrNoRecord
+	}
+	return err
+}
+
+// GetLedger returns the most recent adjustments, newest first.
+func (m *InventoryModel) GetLedger(limit int) ([]InventoryAdjustment, error) {
+	stmt := `SELECT a.adjustment_id, a.product_id, p.name, a.location_id, l.name, a.delta, a.reason,
+	COALESCE(a.estimate_id, 0), COALESCE(a.adjusted_by, 0), COALESCE(u.name, ''), a.created_at
+	FROM inventory_adjustments a
+	JOIN products p ON p.product_id = a.product_id
+	JOIN inventory_locations l ON l.location_id = a.location_id
+	LEFT JOIN users u ON u.user_id = a.adjusted_by
+	ORDER BY a.created_at DESC, a.adjustment_id DESC
+	LIMIT $1`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ledger []InventoryAdjustment
+	for rows.Next() {
+		var a InventoryAdjustment
+		err := rows.Scan(&a.AdjustmentID, &a.ProductID, &a.ProductName, &a.LocationID, &a.LocationName, &a.Delta,
+			&a.Reason, &a.EstimateID, &a.AdjustedBy, &a.AdjustedByName, &a.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		ledger = append(ledger, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+// GetOpenReservations returns the stock held for signed estimates that are not completed yet, oldest first.
+func (m *InventoryModel) GetOpenReservations() ([]InventoryReservation, error) {
+	stmt := `SELECT r.reservation_id, r.estimate_id, COALESCE(u.name, ''), r.product_id, p.name, l.name,
+	r.quantity, r.created_at
+	FROM inventory_reservations r
+	JOIN estimates e ON e.estimate_id = r.estimate_id
+	JOIN products p ON p.product_id = r.product_id
+	JOIN inventory_locations l ON l.location_id = r.location_id
+	LEFT JOIN users u ON u.user_id = e.customer_id
+	WHERE r.status = 'reserved'
+	ORDER BY r.created_at, r.reservation_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []InventoryReservation
+	for rows.Next() {
+		var r InventoryReservation
+		err := rows.Scan(&r.ReservationID, &r.EstimateID, &r.CustomerName, &r.ProductID, &r.ProductName,
+			&r.LocationName, &r.Quantity, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// ReserveTx holds stock for every catalog line item of a signed estimate, or for each component of a bundle. Stock
+// is taken from the locations with the most available first. When there is not enough, the shortfall is reserved
+// at the location with the most available so the warehouse sees it as negative availability. Untracked products
+// are skipped.
+// Nothing is done if the estimate already has reservations.
+func (m *InventoryModel) ReserveTx(tx *sql.Tx, estimateID int) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM inventory_reservations WHERE estimate_id=$1)`, estimateID).
+		Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	type lineItem struct{ lineItemID, productID, quantity int }
+
+	// Bundles are reserved as their components.
+	rows, err := tx.Query(`SELECT ei.line_item_id, COALESCE(bc.component_id, ei.product_id),
+	ei.quantity * COALESCE(bc.quantity, 1)
+	FROM estimate_items ei
+	`+lineItemComponents+`
+	WHERE ei.estimate_id=$1 AND ei.product_id IS NOT NULL AND NOT ei.is_optional
+	ORDER BY ei.line_item_id, bc.component_id`, estimateID)
+	if err != nil {
+		return err
+	}
+
+	var items []lineItem
+	for rows.Next() {
+		var i lineItem
+		if err := rows.Scan(&i.lineItemID, &i.productID, &i.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, i)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		allocations, err := allocateStock(tx, item.productID, item.quantity)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range allocations {
+			_, err := tx.Exec(`INSERT INTO inventory_reservations (estimate_id, line_item_id, product_id, location_id, quantity)
+			VALUES ($1, $2, $3, $4, $5)`, estimateID, item.lineItemID, item.productID, a.locationID, a.quantity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConsumeTx takes the stock reserved for a completed estimate off hand and records each change in the ledger.
+func (m *InventoryModel) ConsumeTx(tx *sql.Tx, estimateID, userID int) error {
+	rows, err := tx.Query(`SELECT reservation_id, product_id, location_id, quantity FROM inventory_reservations
+	WHERE estimate_id=$1 AND status='reserved'
+	ORDER BY reservation_id
+	FOR UPDATE`, estimateID)
+	if err != nil {
+		return err
+	}
+
+	var reservations []struct{ id, productID, locationID, quantity int }
+	for rows.Next() {
+		var r struct{ id, productID, locationID, quantity int }
+		if err := rows.Scan(&r.id, &r.productID, &r.locationID, &r.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		reservations = append(reservations, r)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range reservations {
+		err := adjustStock(tx, &InventoryAdjustment{
+			ProductID:  r.productID,
+			LocationID: r.locationID,
+			Delta:      -r.quantity,
+			Reason:     fmt.Sprintf("Installed on estimate #%d", estimateID),
+			EstimateID: estimateID,
+			AdjustedBy: userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE inventory_reservations SET status='consumed', updated_at=NOW()
+	WHERE estimate_id=$1 AND status='reserved'`, estimateID)
+	return err
+}
+
+// stockAllocation is part of a line item's quantity taken from one location.
+type stockAllocation struct {
+	locationID int
+	quantity   int
+}
+
+// allocateStock decides which locations a quantity of a product is reserved from. The product's stock rows are
+// locked until the transaction ends. It returns nothing for untracked products.
+func allocateStock(tx *sql.Tx, productID, quantity int) ([]stockAllocation, error) {
+	rows, err := tx.Query(`SELECT s.location_id, s.on_hand - COALESCE((
+		SELECT SUM(r.quantity) FROM inventory_reservations r
+		WHERE r.product_id = s.product_id AND r.location_id = s.location_id AND r.status = 'reserved'), 0) AS available
+	FROM inventory_stock s
+	WHERE s.product_id=$1
+	ORDER BY available DESC, s.location_id
+	FOR UPDATE OF s`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []stockAllocation
+	remaining := quantity
+	first := true
+	for rows.Next() {
+		var locationID, available int
+		if err := rows.Scan(&locationID, &available); err != nil {
+			return nil, err
+		}
+
+		// The shortfall goes to the location with the most available, which is the first one.
+		if first {
+			allocations = append(allocations, stockAllocation{locationID: locationID})
+			first = false
+		}
+		if remaining == 0 || available <= 0 {
+			continue
+		}
+
+		take := min(available, remaining)
+		remaining -= take
+		if allocations[0].locationID == locationID {
+			allocations[0].quantity += take
+		} else {
+			allocations = append(allocations, stockAllocation{locationID: locationID, quantity: take})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(allocations) == 0 {
+		return nil, nil
+	}
+	allocations[0].quantity += remaining
+
+	if allocations[0].quantity == 0 {
+		allocations = allocations[1:]
+	}
+
+	return allocations, nil
+}
+
+// adjustStock changes the quantity on hand and writes the ledger entry inside a transaction.
+func adjustStock(tx *sql.Tx, a *InventoryAdjustment) error {
+	stmt := `INSERT INTO inventory_stock (product_id, location_id, on_hand) VALUES ($1, $2, $3)
+	ON CONFLICT (product_id, location_id) DO UPDATE SET on_hand = inventory_stock.on_hand + EXCLUDED.on_hand`
+
+	_, err := tx.Exec(stmt, a.ProductID, a.LocationID, a.Delta)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO inventory_adjustments (product_id, location_id, delta, reason, estimate_id, adjusted_by)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0))
+	RETURNING adjustment_id, created_at`
+
+	return tx.QueryRow(stmt, a.ProductID, a.LocationID, a.Delta, a.Reason, a.EstimateID, a.AdjustedBy).
+		Scan(&a.AdjustmentID, &a.CreatedAt)
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -8,6 +10,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type InvoiceToken struct {
@@ -17,29 +21,254 @@ type InvoiceToken struct {
 	ExpiresAt      time.Time
 	UsedAt         sql.NullTime
 	CreatedAt      time.Time
+	CreatedByName  string
+	RevokedAt      sql.NullTime
+	RevokedByName  string
+	RemindedAt     sql.NullTime
+	// OpenedAt is when the customer first opened the link.
+	OpenedAt sql.NullTime
+}
+
+// TokenStatus is where a signing link stands, as shown to admins.
+type TokenStatus string
+
+const (
+	TokenActive  TokenStatus = "Active"
+	TokenUsed    TokenStatus = "Used"
+	TokenExpired TokenStatus = "Expired"
+	TokenRevoked TokenStatus = "Revoked"
+)
+
+// Status returns the status of the link at the given time. A link that was used stays used even after it expires.
+func (it InvoiceToken) Status(now time.Time) TokenStatus {
+	switch {
+	case it.UsedAt.Valid:
+		return TokenUsed
+	case it.RevokedAt.Valid:
+		return TokenRevoked
+	case now.After(it.ExpiresAt):
+		return TokenExpired
+	default:
+		return TokenActive
+	}
+}
+
+// Active reports whether the link can still be used to view and sign the invoice.
+func (it InvoiceToken) Active(now time.Time) bool {
+	return it.Status(now) == TokenActive
 }
 
 type InvoiceTokenModel struct {
 	DB *sql.DB
+	// Key is the AES-256 key sealing the copy of each raw token kept so reminders can resend the link. Without a
+	// key no copy is kept and the links are never reminded.
+	Key []byte
+}
+
+// ReminderToken is a signing link that is still active along with the raw token to resend to the customer.
+type ReminderToken struct {
+	InvoiceToken
+	RawToken string
 }
 
-func (m *InvoiceTokenModel) Insert(estimateID int, expiresAt time.Time) (string, error) {
+// Insert issues a new signing link for an estimate and returns the raw token to send to the customer. Links
+// still active for the estimate are revoked, so only the newest link can be used. userID is whoever issued it.
+func (m *InvoiceTokenModel) Insert(estimateID int, expiresAt time.Time, userID int) (string, error) {
 	rawToken, tokenHash, err := generateInvoiceToken()
 	if err != nil {
 		return "", err
 	}
 
+	var sealed []byte
+	if m.Key != nil {
+		sealed, err = sealToken(m.Key, rawToken)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
 	stmt := `
-		INSERT INTO invoice_access_tokens (estimate_id, token_hash, expires_at)
-		VALUES ($1, $2, $3)
+		UPDATE invoice_access_tokens
+		SET revoked_at = NOW(), revoked_by = NULLIF($2, 0)
+		WHERE estimate_id = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`
+
+	_, err = tx.Exec(stmt, estimateID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	stmt = `
+		INSERT INTO invoice_access_tokens (estimate_id, token_hash, expires_at, created_by, sealed_token)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5)
 	`
 
-	_, err = m.DB.Exec(stmt, estimateID, tokenHash, expiresAt)
+	_, err = tx.Exec(stmt, estimateID, tokenHash, expiresAt, userID, sealed)
 	if err != nil {
 		return "", err
 	}
 
-	return rawToken, nil
+	return rawToken, tx.Commit()
+}
+
+// GetByEstimateID returns every signing link issued for an estimate, newest first.
+func (m *InvoiceTokenModel) GetByEstimateID(estimateID int) ([]InvoiceToken, error) {
+	stmt := `
+		SELECT t.invoice_token_id, t.estimate_id, t.token_hash, t.expires_at, t.used_at, t.created_at,
+			COALESCE(c.name, ''), t.revoked_at, COALESCE(r.name, ''), t.reminded_at, t.opened_at
+		FROM invoice_access_tokens t
+		LEFT JOIN users c ON c.user_id = t.created_by
+		LEFT JOIN users r ON r.user_id = t.revoked_by
+		WHERE t.estimate_id = $1
+		ORDER BY t.created_at DESC, t.invoice_token_id DESC
+	`
+
+	rows, err := m.DB.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []InvoiceToken
+	for rows.Next() {
+		var it InvoiceToken
+		err := rows.Scan(&it.InvoiceTokenID, &it.EstimateID, &it.TokenHash, &it.ExpiresAt, &it.UsedAt, &it.CreatedAt,
+			&it.CreatedByName, &it.RevokedAt, &it.RevokedByName, &it.RemindedAt,
+			&it.OpenedAt)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, it)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke stops a signing link from being used and returns the estimate it was for. Returns ErrNoRecord if there is
+// no such link or it was already used or revoked.
+func (m *InvoiceTokenModel) Revoke(id, userID int) (int, error) {
+	stmt := `
+		UPDATE invoice_access_tokens
+		SET revoked_at = NOW(), revoked_by = NULLIF($2, 0)
+		WHERE invoice_token_id = $1 AND used_at IS NULL AND revoked_at IS NULL
+		RETURNING estimate_id
+	`
+
+	var estimateID int
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&estimateID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return estimateID, nil
+}
+
+// GetRemindable returns every signing link that can still be used and has a sealed copy of its token, with the
+// raw token unsealed, oldest first. Links whose copy cannot be unsealed, ex. because it was sealed under an older
+// key, have the copy dropped so they are not reminded again, and their IDs are returned as unreadable. The links
+// themselves keep working.
+func (m *InvoiceTokenModel) GetRemindable() ([]ReminderToken, []int, error) {
+	if m.Key == nil {
+		return nil, nil, nil
+	}
+
+	stmt := `
+		SELECT invoice_token_id, estimate_id, expires_at, created_at, reminded_at, sealed_token
+		FROM invoice_access_tokens
+		WHERE used_at IS NULL AND revoked_at IS NULL AND expires_at > NOW() AND sealed_token IS NOT NULL
+		ORDER BY created_at, invoice_token_id
+	`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var tokens []ReminderToken
+	var unreadable []int
+	for rows.Next() {
+		var rt ReminderToken
+		var sealed []byte
+		err := rows.Scan(&rt.InvoiceTokenID, &rt.EstimateID, &rt.ExpiresAt, &rt.CreatedAt, &rt.RemindedAt, &sealed)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rt.RawToken, err = openToken(m.Key, sealed)
+		if err != nil {
+			unreadable = append(unreadable, rt.InvoiceTokenID)
+			continue
+		}
+		tokens = append(tokens, rt)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(unreadable) > 0 {
+		ids := make([]int64, len(unreadable))
+		for i, id := range unreadable {
+			ids[i] = int64(id)
+		}
+
+		_, err = m.DB.Exec(`UPDATE invoice_access_tokens SET sealed_token = NULL WHERE invoice_token_id = ANY($1)`,
+			pq.Array(ids))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return tokens, unreadable, nil
+}
+
+// MarkReminded records that the customer was reminded of a signing link. Returns ErrNoRecord if the link was used,
+// revoked or expired in the meantime, in which case no reminder should be sent.
+func (m *InvoiceTokenModel) MarkReminded(id int) error {
+	stmt := `
+		UPDATE invoice_access_tokens
+		SET reminded_at = NOW()
+		WHERE invoice_token_id = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+	`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// UnmarkReminded puts back when the customer was last reminded of a signing link, after a reminder recorded by
+// MarkReminded could not be sent.
+func (m *InvoiceTokenModel) UnmarkReminded(id int, remindedAt sql.NullTime) error {
+	stmt := `UPDATE invoice_access_tokens SET reminded_at = $2 WHERE invoice_token_id = $1`
+
+	_, err := m.DB.Exec(stmt, id, remindedAt)
+	return err
 }
 
 func (m *InvoiceTokenModel) GetByRawToken(rawToken string) (*InvoiceToken, error) {
@@ -47,7 +276,7 @@ func (m *InvoiceTokenModel) GetByRawToken(rawToken string) (*InvoiceToken, error
 	tokenHash := hex.EncodeToString(sum[:])
 
 	stmt := `
-		SELECT invoice_token_id, estimate_id, token_hash, expires_at, used_at, created_at
+		SELECT invoice_token_id, estimate_id, token_hash, expires_at, used_at, created_at, revoked_at, opened_at
 		FROM invoice_access_tokens
 		WHERE token_hash = $1
 	`
@@ -61,6 +290,8 @@ func (m *InvoiceTokenModel) GetByRawToken(rawToken string) (*InvoiceToken, error
 		&it.ExpiresAt,
 		&it.UsedAt,
 		&it.CreatedAt,
+		&it.RevokedAt,
+		&it.OpenedAt,
 	)
 
 	if err != nil {
@@ -73,6 +304,14 @@ func (m *InvoiceTokenModel) GetByRawToken(rawToken string) (*InvoiceToken, error
 	return &it, nil
 }
 
+// MarkOpened records when the customer first opened a signing link. Later opens leave it unchanged.
+func (m *InvoiceTokenModel) MarkOpened(id int) error {
+	stmt := `UPDATE invoice_access_tokens SET opened_at = NOW() WHERE invoice_token_id = $1 AND opened_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
 func (m *InvoiceTokenModel) MarkUsed(id int) error {
 	return m.markUsed(m.DB, id)
 }
@@ -85,7 +324,7 @@ func (m *InvoiceTokenModel) markUsed(exec executor, id int) error {
 	stmt := `
 		UPDATE invoice_access_tokens
 		SET used_at = $2
-		WHERE invoice_token_id = $1
+		WHERE invoice_token_id = $1 AND used_at IS NULL AND revoked_at IS NULL
 	`
 
 	result, err := exec.Exec(stmt, id, time.Now())
@@ -120,3 +359,45 @@ func generateInvoiceToken() (raw string, hash string, err error) {
 	return raw, hash, nil
 
 }
+
+// sealToken encrypts a raw token with AES-GCM, the nonce first.
+func sealToken(key []byte, rawToken string) ([]byte, error) {
+	gcm, err := newTokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, []byte(rawToken), nil), nil
+}
+
+// openToken decrypts a token sealed by sealToken.
+func openToken(key, sealed []byte) (string, error) {
+	gcm, err := newTokenCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("models: sealed invoice token is too short")
+	}
+
+	raw, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+func newTokenCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
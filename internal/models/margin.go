@@ -0,0 +1,72 @@
+// models/margin.go works out how much we make on an estimate: the sell price of its products less what the
+// vendors charge for them. Labor and sales tax are left out. Margins are only shown to admins.
+
+package models
+
+// LineMargin is the margin on a single line item. Lines without a known vendor cost, such as custom line items,
+// have HasCost set to false and are left out of the estimate's margin.
+type LineMargin struct {
+	Item    EstimateProduct
+	Revenue int
+	Cost    int
+	HasCost bool
+}
+
+// Margin returns the revenue less the cost of the line, in cents.
+func (l LineMargin) Margin() int {
+	return l.Revenue - l.Cost
+}
+
+// Percent returns the margin as a percentage of the revenue.
+func (l LineMargin) Percent() float64 {
+	return marginPercent(l.Revenue, l.Cost)
+}
+
+// EstimateMargin totals the margins of the line items whose cost is known.
+type EstimateMargin struct {
+	Lines   []LineMargin
+	Revenue int
+	Cost    int
+	// UnknownCost is the number of line items left out because their vendor cost is unknown.
+	UnknownCost int
+}
+
+// Margin returns the revenue less the cost of the costed line items, in cents.
+func (m EstimateMargin) Margin() int {
+	return m.Revenue - m.Cost
+}
+
+// Percent returns the margin as a percentage of the revenue of the costed line items.
+func (m EstimateMargin) Percent() float64 {
+	return marginPercent(m.Revenue, m.Cost)
+}
+
+// CalculateMargins works out the margin of every line item and of the estimate as a whole.
+func CalculateMargins(estimateProducts []EstimateProduct) EstimateMargin {
+	var margin EstimateMargin
+	for _, ep := range estimateProducts {
+		line := LineMargin{
+			Item:    ep,
+			Revenue: ep.LineTotal(),
+			Cost:    ep.Product.VendorCost * ep.EstimateItem.Quantity,
+			HasCost: !ep.EstimateItem.IsCustom() && ep.Product.VendorCost > 0,
+		}
+		margin.Lines = append(margin.Lines, line)
+
+		if !line.HasCost {
+			margin.UnknownCost++
+			continue
+		}
+		margin.Revenue += line.Revenue
+		margin.Cost += line.Cost
+	}
+
+	return margin
+}
+
+func marginPercent(revenue, cost int) float64 {
+	if revenue == 0 {
+		return 0
+	}
+	return float64(revenue-cost) / float64(revenue) * 100
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"strings"
 )
 
 // Product represents a product record in the database.
@@ -19,24 +20,97 @@ type Product struct {
 	Width       float32
 	Height      float32
 	CreatedBy   int
+	// FamilyID is the family the product is a variant of, or 0 for a standalone product.
+	FamilyID int
+	Finish   string
+	Size     string
+	// Discontinued products can no longer be added to estimates but still show on the estimates that have them.
+	Discontinued bool
+	// ReplacedBy is the product suggested in place of a discontinued one, or 0.
+	ReplacedBy int
+	// SKU is our own stock keeping unit, unique across the catalog when set.
+	SKU         string
+	ModelNumber string
+	// VendorID is the supplier the product is bought from, or 0 when unknown.
+	VendorID int
+	// VendorCost is what the vendor charges us, in cents. 0 means the cost is unknown.
+	VendorCost   int
+	LeadTimeDays int
+	// ImageKey is the storage prefix of the product photo's resized variants, or "" when it has no photo.
+	ImageKey string
 }
 
+// Margin returns the difference between the sell price and the vendor cost of one unit, in cents.
+func (p Product) Margin() int {
+	return p.UnitPrice - p.VendorCost
+}
+
+// VariantLabel describes what sets a variant apart from the rest of its family, ex. "White / Matte / 36 in".
+func (p Product) VariantLabel() string {
+	var parts []string
+	for _, v := range []string{p.Color, p.Finish, p.Size} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, " / ")
+}
+
+// ProductCategories lists every product category. Categories drive labor rules and how line items are grouped.
+var ProductCategories = []string{"Appliances", "Cabinetry", "Countertops", "Sinks & Faucets", "Flooring", "Backsplash", "Misc"}
+
 // ProductModel wraps a sql.DB connection and provides methods for CRUD operations on products.
 type ProductModel struct {
 	DB *sql.DB
 }
 
-// Insert adds a new Product to the database and assigns the generated ProductID to the struct.
-// Returns an error if the insert or Scan operation fails.
-func (m *ProductModel) Insert(p *Product) error {
-	stmt := `
+const insertProductStmt = `
 		INSERT INTO products
-			(name, description, category, subcategory, color, unit_price, length, width, height, created_by)
+			(name, description, category, subcategory, color, unit_price, length, width, height, created_by,
+			 family_id, finish, size, sku, model_number, vendor_id, vendor_cost, lead_time_days)
 		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, 0), $12, $13, NULLIF($14, ''), $15, NULLIF($16, 0), $17, $18)
 		RETURNING product_id
 	`
-	return m.DB.QueryRow(stmt,
+
+// productColumns is the column list scanned by scanProduct.
+const productColumns = `product_id, name, description, category, subcategory, color,
+		       unit_price, length, width, height, created_by, COALESCE(family_id, 0), finish, size,
+		       discontinued_at IS NOT NULL, COALESCE(replaced_by, 0),
+		       COALESCE(sku, ''), model_number, COALESCE(vendor_id, 0), vendor_cost, lead_time_days, image_key`
+
+// scanProduct scans a row selected with productColumns.
+func scanProduct(row interface{ Scan(...any) error }, p *Product) error {
+	return row.Scan(
+		&p.ProductID,
+		&p.Name,
+		&p.Description,
+		&p.Category,
+		&p.Subcategory,
+		&p.Color,
+		&p.UnitPrice,
+		&p.Length,
+		&p.Width,
+		&p.Height,
+		&p.CreatedBy,
+		&p.FamilyID,
+		&p.Finish,
+		&p.Size,
+		&p.Discontinued,
+		&p.ReplacedBy,
+		&p.SKU,
+		&p.ModelNumber,
+		&p.VendorID,
+		&p.VendorCost,
+		&p.LeadTimeDays,
+		&p.ImageKey,
+	)
+}
+
+// Insert adds a new Product to the database and assigns the generated ProductID to the struct.
+// Returns an error if the insert or Scan operation fails.
+func (m *ProductModel) Insert(p *Product) error {
+	return m.DB.QueryRow(insertProductStmt,
 		p.Name,
 		p.Description,
 		p.Category,
@@ -47,6 +121,14 @@ func (m *ProductModel) Insert(p *Product) error {
 		p.Width,
 		p.Height,
 		p.CreatedBy,
+		p.FamilyID,
+		p.Finish,
+		p.Size,
+		p.SKU,
+		p.ModelNumber,
+		p.VendorID,
+		p.VendorCost,
+		p.LeadTimeDays,
 	).Scan(&p.ProductID)
 }
 
@@ -54,25 +136,27 @@ func (m *ProductModel) Insert(p *Product) error {
 // Returns ErrNoRecord if the product does not exist.
 func (m *ProductModel) Get(id int) (Product, error) {
 	stmt := `
-		SELECT product_id, name, description, category, subcategory, color,
-		       unit_price, length, width, height, created_by
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE product_id=$1
 	`
 	var p Product
-	err := m.DB.QueryRow(stmt, id).Scan(
-		&p.ProductID,
-		&p.Name,
-		&p.Description,
-		&p.Category,
-		&p.Subcategory,
-		&p.Color,
-		&p.UnitPrice,
-		&p.Length,
-		&p.Width,
-		&p.Height,
-		&p.CreatedBy,
-	)
+	err := scanProduct(m.DB.QueryRow(stmt, id), &p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Product{}, ErrNoRecord
+		}
+		return Product{}, err
+	}
+	return p, nil
+}
+
+// GetBySKU retrieves a Product by its SKU. Returns ErrNoRecord if no product has the SKU.
+func (m *ProductModel) GetBySKU(sku string) (Product, error) {
+	stmt := `SELECT ` + productColumns + ` FROM products WHERE sku=$1`
+
+	var p Product
+	err := scanProduct(m.DB.QueryRow(stmt, strings.TrimSpace(sku)), &p)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return Product{}, ErrNoRecord
@@ -83,16 +167,18 @@ func (m *ProductModel) Get(id int) (Product, error) {
 }
 
 // GetByProductFilter retrieves a list of Products filtered by category, subcategory, or color.
-// Empty string values are treated as wildcards (i.e., no filter applied).
+// Empty string values are treated as wildcards (i.e., no filter applied). Discontinued products are left out
+// since the filter is used to pick products for new line items.
 // Returns a slice of Product or an error.
 func (m *ProductModel) GetByProductFilter(category, subcategory, color string) ([]Product, error) {
 	stmt := `
-		SELECT product_id, name, description, category, subcategory, color,
-		       unit_price, length, width, height, created_by
+		SELECT ` + productColumns + `
 		FROM products
 		WHERE ($1='' OR category=$1)
 		AND ($2='' OR subcategory=$2)
 		AND ($3='' OR color=$3)
+		AND discontinued_at IS NULL
+		ORDER BY category, subcategory, name, product_id
 	`
 
 	rows, err := m.DB.Query(stmt, category, subcategory, color)
@@ -104,19 +190,7 @@ func (m *ProductModel) GetByProductFilter(category, subcategory, color string) (
 	var products []Product
 	for rows.Next() {
 		var p Product
-		err := rows.Scan(
-			&p.ProductID,
-			&p.Name,
-			&p.Description,
-			&p.Category,
-			&p.Subcategory,
-			&p.Color,
-			&p.UnitPrice,
-			&p.Length,
-			&p.Width,
-			&p.Height,
-			&p.CreatedBy,
-		)
+		err := scanProduct(rows, &p)
 		if err != nil {
 			return nil, err
 		}
@@ -133,7 +207,9 @@ func (m *ProductModel) Update(p *Product) error {
 	stmt := `
 		UPDATE products
 		SET name=$2, description=$3, category=$4, subcategory=$5, color=$6,
-		    unit_price=$7, length=$8, width=$9, height=$10, created_by=$11
+		    unit_price=$7, length=$8, width=$9, height=$10, created_by=$11,
+		    family_id=NULLIF($12, 0), finish=$13, size=$14,
+		    sku=NULLIF($15, ''), model_number=$16, vendor_id=NULLIF($17, 0), vendor_cost=$18, lead_time_days=$19
 		WHERE product_id=$1
 	`
 	result, err := m.DB.Exec(stmt,
@@ -148,6 +224,14 @@ func (m *ProductModel) Update(p *Product) error {
 		p.Width,
 		p.Height,
 		p.CreatedBy,
+		p.FamilyID,
+		p.Finish,
+		p.Size,
+		p.SKU,
+		p.ModelNumber,
+		p.VendorID,
+		p.VendorCost,
+		p.LeadTimeDays,
 	)
 	if err != nil {
 		return err
@@ -0,0 +1,317 @@
+// models/product_audit.go records who created, changed or removed catalog products and what they changed.
+// The audited methods write the product and its audit entry in one transaction.
+
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Audit actions recorded for products.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// FieldChange is a single field that changed in an audited update.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ProductAuditEntry is one recorded change to a product. ProductName is kept so entries remain readable
+// after the product is deleted.
+type ProductAuditEntry struct {
+	AuditID       int
+	ProductID     int
+	ProductName   string
+	Action        string
+	ChangedBy     int
+	ChangedByName string
+	ChangedAt     time.Time
+	Changes       []FieldChange
+}
+
+// CreateAudited inserts a new product and records who created it. Returns ErrDuplicateSKU if another product
+// already has its SKU.
+func (m *ProductModel) CreateAudited(p *Product, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = createAudited(tx, p, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateAudited saves changes to a product and records which fields changed and who changed them.
+// CreatedBy is never changed. Returns ErrNoRecord if the product does not exist and ErrDuplicateSKU if another
+// product already has its SKU.
+func (m *ProductModel) UpdateAudited(p *Product, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = updateAudited(tx, p, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteAudited removes a product and records who removed it. Returns ErrNoRecord if the product does not exist
+// and ErrProductInUse if it is still a line item on an estimate or part of a bundle.
+func (m *ProductModel) DeleteAudited(id int, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := getProductForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM products WHERE product_id=$1`, id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrProductInUse
+		}
+		return err
+	}
+
+	err = insertProductAudit(tx, id, before.Name, AuditDelete, userID, productChanges(before, Product{}))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetAudit returns the audit history of a product, newest first.
+func (m *ProductModel) GetAudit(productID int) ([]ProductAuditEntry, error) {
+	stmt := `SELECT a.audit_id, a.product_id, a.product_name, a.action, a.changed_by, u.name, a.changed_at, a.changes
+	FROM product_audit a
+	JOIN users u ON u.user_id = a.changed_by
+	WHERE a.product_id=$1
+	ORDER BY a.changed_at DESC, a.audit_id DESC`
+
+	rows, err := m.DB.Query(stmt, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []ProductAuditEntry
+	for rows.Next() {
+		var (
+			e       ProductAuditEntry
+			changes []byte
+		)
+		err := rows.Scan(&e.AuditID, &e.ProductID, &e.ProductName, &e.Action, &e.ChangedBy, &e.ChangedByName, &e.ChangedAt, &changes)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(changes, &e.Changes)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// createAudited inserts a product and its audit entry inside a transaction. Variants take the shared fields of
+// their family.
+func createAudited(tx *sql.Tx, p *Product, userID int) error {
+	err := applyFamily(tx, p)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(insertProductStmt,
+		p.Name, p.Description, p.Category, p.Subcategory, p.Color,
+		p.UnitPrice, p.Length, p.Width, p.Height, p.CreatedBy,
+		p.FamilyID, p.Finish, p.Size, p.SKU, p.ModelNumber, p.VendorID, p.VendorCost, p.LeadTimeDays,
+	).Scan(&p.ProductID)
+	if err != nil {
+		return skuError(err)
+	}
+
+	err = recordPrice(tx, p.ProductID, p.UnitPrice, userID)
+	if err != nil {
+		return err
+	}
+
+	return insertProductAudit(tx, p.ProductID, p.Name, AuditCreate, userID, productChanges(Product{}, *p))
+}
+
+// updateAudited saves a product and its audit entry inside a transaction. Variants take the shared fields of
+// their family. Whether the product is discontinued is left as it is. Nothing is written when no field changed.
+// It returns the fields that changed.
+func updateAudited(tx *sql.Tx, p *Product, userID int) ([]FieldChange, error) {
+	before, err := getProductForUpdate(tx, p.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	p.CreatedBy = before.CreatedBy
+	p.Discontinued = before.Discontinued
+	p.ReplacedBy = before.ReplacedBy
+
+	err = applyFamily(tx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := productChanges(before, *p)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	stmt := `UPDATE products
+	SET name=$2, description=$3, category=$4, subcategory=$5, color=$6, unit_price=$7, length=$8, width=$9, height=$10,
+	family_id=NULLIF($11, 0), finish=$12, size=$13,
+	sku=NULLIF($14, ''), model_number=$15, vendor_id=NULLIF($16, 0), vendor_cost=$17, lead_time_days=$18
+	WHERE product_id=$1`
+
+	_, err = tx.Exec(stmt, p.ProductID, p.Name, p.Description, p.Category, p.Subcategory, p.Color,
+		p.UnitPrice, p.Length, p.Width, p.Height, p.FamilyID, p.Finish, p.Size,
+		p.SKU, p.ModelNumber, p.VendorID, p.VendorCost, p.LeadTimeDays)
+	if err != nil {
+		return nil, skuError(err)
+	}
+
+	if p.UnitPrice != before.UnitPrice {
+		err = recordPrice(tx, p.ProductID, p.UnitPrice, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = insertProductAudit(tx, p.ProductID, p.Name, AuditUpdate, userID, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// skuError turns a unique violation on the SKU column into ErrDuplicateSKU.
+func skuError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "products_sku_key" {
+		return ErrDuplicateSKU
+	}
+	return err
+}
+
+// getProductForUpdate reads a product inside a transaction and locks its row until the transaction ends.
+func getProductForUpdate(tx *sql.Tx, id int) (Product, error) {
+	stmt := `SELECT ` + productColumns + ` FROM products WHERE product_id=$1 FOR UPDATE`
+
+	var p Product
+	err := scanProduct(tx.QueryRow(stmt, id), &p)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Product{}, ErrNoRecord
+		}
+		return Product{}, err
+	}
+
+	return p, nil
+}
+
+// insertProductAudit writes a single audit entry.
+func insertProductAudit(exec executor, productID int, productName, action string, userID int, changes []FieldChange) error {
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO product_audit (product_id, product_name, action, changed_by, changes) VALUES ($1, $2, $3, $4, $5)`
+	_, err = exec.Exec(stmt, productID, productName, action, userID, encoded)
+	return err
+}
+
+// productChanges lists the editable fields that differ between two versions of a product.
+// Prices are shown in dollars.
+func productChanges(before, after Product) []FieldChange {
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"Name", before.Name, after.Name},
+		{"Description", before.Description, after.Description},
+		{"Category", before.Category, after.Category},
+		{"Subcategory", before.Subcategory, after.Subcategory},
+		{"Color", before.Color, after.Color},
+		{"Unit Price", fmt.Sprintf("$%.2f", float64(before.UnitPrice)/100), fmt.Sprintf("$%.2f", float64(after.UnitPrice)/100)},
+		{"Length", fmt.Sprintf("%g in", before.Length), fmt.Sprintf("%g in", after.Length)},
+		{"Width", fmt.Sprintf("%g in", before.Width), fmt.Sprintf("%g in", after.Width)},
+		{"Height", fmt.Sprintf("%g in", before.Height), fmt.Sprintf("%g in", after.Height)},
+		{"Family", refLabel(before.FamilyID), refLabel(after.FamilyID)},
+		{"Finish", before.Finish, after.Finish},
+		{"Size", before.Size, after.Size},
+		{"Status", statusLabel(before.Discontinued), statusLabel(after.Discontinued)},
+		{"Replacement", refLabel(before.ReplacedBy), refLabel(after.ReplacedBy)},
+		{"SKU", before.SKU, after.SKU},
+		{"Model Number", before.ModelNumber, after.ModelNumber},
+		{"Vendor", refLabel(before.VendorID), refLabel(after.VendorID)},
+		{"Vendor Cost", fmt.Sprintf("$%.2f", float64(before.VendorCost)/100), fmt.Sprintf("$%.2f", float64(after.VendorCost)/100)},
+		{"Lead Time", fmt.Sprintf("%d days", before.LeadTimeDays), fmt.Sprintf("%d days", after.LeadTimeDays)},
+	}
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if f.before != f.after {
+			changes = append(changes, FieldChange{Field: f.name, From: f.before, To: f.after})
+		}
+	}
+
+	return changes
+}
+
+// refLabel names a referenced family, product or vendor in the audit log.
+func refLabel(id int) string {
+	if id == 0 {
+		return "None"
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// statusLabel names the lifecycle status of a product in the audit log.
+func statusLabel(discontinued bool) string {
+	if discontinued {
+		return "Discontinued"
+	}
+	return "Active"
+}
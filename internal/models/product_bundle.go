@@ -0,0 +1,225 @@
+// models/product_bundle.go stores the components of bundle products, ex. a builder-grade appliance suite made of
+// a range, a fridge, a dishwasher and a microwave. A bundle is sold at its own unit price; its components are what
+// is delivered, checked, reserved and ordered.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// BundleComponent is a product included in a bundle. Quantity is how many come with one bundle.
+type BundleComponent struct {
+	BundleID int
+	Quantity int
+	Product  Product
+}
+
+// Total returns how many of the component come with the given number of bundles.
+func (c BundleComponent) Total(bundles int) int {
+	return c.Quantity * bundles
+}
+
+// GetBundleComponents returns the components of the given products keyed by bundle, in name order. Products that
+// are not bundles are left out.
+func (m *ProductModel) GetBundleComponents(productIDs []int) (map[int][]BundleComponent, error) {
+	return getBundleComponents(m.DB, productIDs)
+}
+
+func getBundleComponents(db *sql.DB, productIDs []int) (map[int][]BundleComponent, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	stmt := `SELECT bc.bundle_id, bc.quantity, ` + productColumns + `
+	FROM product_bundle_components bc
+	JOIN products ON products.product_id = bc.component_id
+	WHERE bc.bundle_id = ANY($1)
+	ORDER BY bc.bundle_id, name, product_id`
+
+	rows, err := db.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	components := make(map[int][]BundleComponent)
+	for rows.Next() {
+		var c BundleComponent
+		err := scanProduct(prefixedRow{row: rows, prefix: []any{&c.BundleID, &c.Quantity}}, &c.Product)
+		if err != nil {
+			return nil, err
+		}
+		components[c.BundleID] = append(components[c.BundleID], c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return components, nil
+}
+
+// lineItemComponents joins a line item ei to the components it is delivered, reserved and ordered as: the
+// components frozen by SnapshotBundlesTx once its estimate has left Draft, or its bundle's current components.
+const lineItemComponents = `LEFT JOIN LATERAL (
+		SELECT component_id, quantity FROM estimate_item_components WHERE line_item_id = ei.line_item_id
+		UNION ALL
+		SELECT component_id, quantity FROM product_bundle_components
+		WHERE bundle_id = ei.product_id AND ei.snapshot_unit_price IS NULL
+	) bc ON true`
+
+// getFrozenComponents returns the components frozen for the line items of an estimate, keyed by line item.
+func getFrozenComponents(db *sql.DB, estimateID int) (map[int][]BundleComponent, error) {
+	stmt := `SELECT ei.line_item_id, ei.product_id, ec.quantity, ` + productColumns + `
+	FROM estimate_item_components ec
+	JOIN estimate_items ei ON ei.line_item_id = ec.line_item_id
+	JOIN products ON products.product_id = ec.component_id
+	WHERE ei.estimate_id = $1
+	ORDER BY ei.line_item_id, name, products.product_id`
+
+	rows, err := db.Query(stmt, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	components := make(map[int][]BundleComponent)
+	for rows.Next() {
+		var (
+			lineItemID int
+			c          BundleComponent
+		)
+		err := scanProduct(prefixedRow{row: rows, prefix: []any{&lineItemID, &c.BundleID, &c.Quantity}}, &c.Product)
+		if err != nil {
+			return nil, err
+		}
+		components[lineItemID] = append(components[lineItemID], c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return components, nil
+}
+
+// prefixedRow scans extra columns selected before productColumns.
+type prefixedRow struct {
+	row    interface{ Scan(...any) error }
+	prefix []any
+}
+
+func (r prefixedRow) Scan(dest ...any) error {
+	return r.row.Scan(append(r.prefix, dest...)...)
+}
+
+// SetBundleComponent puts quantity of a component in a bundle, replacing the quantity it had, and records the
+// change on the bundle's history. Returns ErrNoRecord if either product does not exist and ErrInvalidBundle if
+// the component is the bundle itself or either product would end up both a bundle and a component.
+func (m *ProductModel) SetBundleComponent(bundleID, componentID, quantity, userID int) error {
+	if bundleID == componentID {
+		return ErrInvalidBundle
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bundle, err := getProductForUpdate(tx, bundleID)
+	if err != nil {
+		return err
+	}
+	component, err := getProductForUpdate(tx, componentID)
+	if err != nil {
+		return err
+	}
+
+	var nested bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM product_bundle_components WHERE component_id = $1)
+		OR EXISTS (SELECT 1 FROM product_bundle_components WHERE bundle_id = $2)`, bundleID, componentID).Scan(&nested)
+	if err != nil {
+		return err
+	}
+	if nested {
+		return ErrInvalidBundle
+	}
+
+	var before int
+	err = tx.QueryRow(`SELECT quantity FROM product_bundle_components WHERE bundle_id = $1 AND component_id = $2`,
+		bundleID, componentID).Scan(&before)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if before == quantity {
+		return nil
+	}
+
+	_, err = tx.Exec(`INSERT INTO product_bundle_components (bundle_id, component_id, quantity) VALUES ($1, $2, $3)
+	ON CONFLICT (bundle_id, component_id) DO UPDATE SET quantity = EXCLUDED.quantity`, bundleID, componentID, quantity)
+	if err != nil {
+		return err
+	}
+
+	changes := []FieldChange{{
+		Field: "Bundle: " + component.Name,
+		From:  componentLabel(before),
+		To:    componentLabel(quantity),
+	}}
+	err = insertProductAudit(tx, bundleID, bundle.Name, AuditUpdate, userID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveBundleComponent takes a component out of a bundle and records the change on the bundle's history.
+// Returns ErrNoRecord if the bundle does not contain the component.
+func (m *ProductModel) RemoveBundleComponent(bundleID, componentID, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bundle, err := getProductForUpdate(tx, bundleID)
+	if err != nil {
+		return err
+	}
+
+	var before int
+	var name string
+	err = tx.QueryRow(`DELETE FROM product_bundle_components bc USING products p
+	WHERE bc.bundle_id = $1 AND bc.component_id = $2 AND p.product_id = bc.component_id
+	RETURNING bc.quantity, p.name`, bundleID, componentID).Scan(&before, &name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	changes := []FieldChange{{Field: "Bundle: " + name, From: componentLabel(before), To: componentLabel(0)}}
+	err = insertProductAudit(tx, bundleID, bundle.Name, AuditUpdate, userID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// componentLabel describes how many of a component a bundle has for the audit log.
+func componentLabel(quantity int) string {
+	if quantity == 0 {
+		return "None"
+	}
+	return strconv.Itoa(quantity)
+}
@@ -0,0 +1,740 @@
+// models/product_csv.go imports and exports the product catalog as CSV so supplier price lists can be loaded
+// and the catalog can be edited in a spreadsheet. An exported file can be imported back unchanged.
+
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// ProductCSVFields lists the catalog fields in the order they are exported. Each field is also the default
+// column header an import looks for.
+var ProductCSVFields = []string{
+	"product_id", "name", "description", "category", "subcategory", "color", "unit_price", "length", "width", "height",
+	"family_id", "finish", "size", "sku", "model_number", "vendor_id", "vendor_cost", "lead_time_days",
+}
+
+// requiredImportFields must be mapped to a column for an import to be read at all.
+var requiredImportFields = []string{"name", "category", "subcategory", "unit_price"}
+
+// Import row actions.
+const (
+	ImportInsert    = "insert"
+	ImportUpdate    = "update"
+	ImportUnchanged = "unchanged"
+)
+
+// ProductImportRow is a single data row of an import file. Line is the line number in the file, counting the
+// header as line 1.
+type ProductImportRow struct {
+	Line         int
+	Action       string
+	Product      Product
+	OldUnitPrice int
+	Changes      []FieldChange
+	Errors       []string
+
+	// unmapped lists the fields the file has no column for. Updates keep the product's current value for them.
+	unmapped []string
+}
+
+// PriceChanged reports whether an update changes the product's price.
+func (r ProductImportRow) PriceChanged() bool {
+	return r.Action == ImportUpdate && r.OldUnitPrice != r.Product.UnitPrice
+}
+
+// ProductImportPlan is what an import will do, row by row. It is shown to the user as a preview before the import
+// is committed.
+type ProductImportPlan struct {
+	Rows []ProductImportRow
+}
+
+// Inserts returns the number of products the import adds.
+func (p ProductImportPlan) Inserts() int {
+	return p.count(func(r ProductImportRow) bool { return r.Action == ImportInsert })
+}
+
+// Updates returns the number of existing products the import changes.
+func (p ProductImportPlan) Updates() int {
+	return p.count(func(r ProductImportRow) bool { return r.Action == ImportUpdate })
+}
+
+// PriceChanges returns the number of existing products whose price the import changes.
+func (p ProductImportPlan) PriceChanges() int {
+	return p.count(ProductImportRow.PriceChanged)
+}
+
+// ErrorRows returns the number of rows that failed validation.
+func (p ProductImportPlan) ErrorRows() int {
+	return p.count(func(r ProductImportRow) bool { return len(r.Errors) > 0 })
+}
+
+// Valid reports whether every row passed validation.
+func (p ProductImportPlan) Valid() bool {
+	return p.ErrorRows() == 0
+}
+
+// Digest identifies what the plan does. Two plans of the same file have the same digest only when they make
+// exactly the same changes.
+func (p ProductImportPlan) Digest() string {
+	h := sha256.New()
+	for _, r := range p.Rows {
+		fmt.Fprintf(h, "%d %s %v %v %v\n", r.Line, r.Action, r.Product, r.Changes, r.Errors)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p ProductImportPlan) count(match func(ProductImportRow) bool) int {
+	n := 0
+	for _, r := range p.Rows {
+		if match(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// ExportCSV writes every product to w, one row per product, with a header row of ProductCSVFields.
+// Prices are written in dollars.
+func (m *ProductModel) ExportCSV(w io.Writer) error {
+	stmt := `SELECT ` + productColumns + `
+	FROM products
+	ORDER BY product_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	err = cw.Write(ProductCSVFields)
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var p Product
+		err := scanProduct(rows, &p)
+		if err != nil {
+			return err
+		}
+
+		familyID, vendorID := "", ""
+		if p.FamilyID != 0 {
+			familyID = strconv.Itoa(p.FamilyID)
+		}
+		if p.VendorID != 0 {
+			vendorID = strconv.Itoa(p.VendorID)
+		}
+
+		err = cw.Write([]string{
+			strconv.Itoa(p.ProductID),
+			p.Name,
+			p.Description,
+			p.Category,
+			p.Subcategory,
+			p.Color,
+			fmt.Sprintf("%.2f", float64(p.UnitPrice)/100),
+			strconv.FormatFloat(float64(p.Length), 'f', -1, 32),
+			strconv.FormatFloat(float64(p.Width), 'f', -1, 32),
+			strconv.FormatFloat(float64(p.Height), 'f', -1, 32),
+			familyID,
+			p.Finish,
+			p.Size,
+			p.SKU,
+			p.ModelNumber,
+			vendorID,
+			fmt.Sprintf("%.2f", float64(p.VendorCost)/100),
+			strconv.Itoa(p.LeadTimeDays),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// PlanImport reads an import file and works out what importing it would do without changing anything.
+// mapping maps catalog fields to the column headers of the file; unmapped fields use their own name as the header.
+// It returns ErrInvalidImport when the file cannot be read at all. Row-level problems are reported on the rows.
+func (m *ProductModel) PlanImport(r io.Reader, mapping map[string]string) (ProductImportPlan, error) {
+	rows, err := parseProductImport(r, mapping)
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+
+	err = planProductImport(m.DB, rows, "")
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+
+	return ProductImportPlan{Rows: rows}, nil
+}
+
+// ApplyImport imports a file in a single transaction, recording every insert and update in the product audit.
+// The file is planned again inside the transaction and must match the Digest of the previewed plan; otherwise
+// nothing is written and ErrImportChanged is returned. Nothing is written and ErrInvalidImport is returned when
+// any row fails validation.
+func (m *ProductModel) ApplyImport(r io.Reader, mapping map[string]string, digest string, userID int) (ProductImportPlan, error) {
+	rows, err := parseProductImport(r, mapping)
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+	defer tx.Rollback()
+
+	// Lock the products being updated so the plan cannot go stale before it is applied.
+	err = planProductImport(tx, rows, "FOR UPDATE")
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+
+	plan := ProductImportPlan{Rows: rows}
+	if plan.Digest() != digest {
+		return plan, ErrImportChanged
+	}
+	if !plan.Valid() {
+		return plan, ErrInvalidImport
+	}
+
+	for i := range plan.Rows {
+		row := &plan.Rows[i]
+
+		switch row.Action {
+		case ImportInsert:
+			row.Product.CreatedBy = userID
+			err = createAudited(tx, &row.Product, userID)
+		case ImportUpdate:
+			_, err = updateAudited(tx, &row.Product, userID)
+		}
+		if err != nil {
+			return ProductImportPlan{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ProductImportPlan{}, err
+	}
+
+	return plan, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// planProductImport decides the action of every valid row by comparing it with the product it names.
+// Rows without a product ID are inserts. lock is appended to the SELECT of existing products.
+func planProductImport(q queryer, rows []ProductImportRow, lock string) error {
+	var ids []int64
+	for _, r := range rows {
+		if r.Product.ProductID > 0 {
+			ids = append(ids, int64(r.Product.ProductID))
+		}
+	}
+
+	existing := make(map[int]Product)
+	if len(ids) > 0 {
+		stmt := `SELECT ` + productColumns + `
+		FROM products WHERE product_id = ANY($1) ORDER BY product_id ` + lock
+
+		result, err := q.Query(stmt, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		defer result.Close()
+
+		for result.Next() {
+			var p Product
+			err := scanProduct(result, &p)
+			if err != nil {
+				return err
+			}
+			existing[p.ProductID] = p
+		}
+
+		if err = result.Err(); err != nil {
+			return err
+		}
+	}
+
+	var familyIDs []int64
+	for _, r := range rows {
+		if r.Product.FamilyID > 0 {
+			familyIDs = append(familyIDs, int64(r.Product.FamilyID))
+		} else if before, ok := existing[r.Product.ProductID]; ok && before.FamilyID > 0 {
+			familyIDs = append(familyIDs, int64(before.FamilyID))
+		}
+	}
+
+	families, err := getFamilies(q, familyIDs, "")
+	if err != nil {
+		return err
+	}
+
+	vendors, err := existingVendors(q, rows)
+	if err != nil {
+		return err
+	}
+
+	skus, err := existingSKUs(q, rows)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int]int)
+	seenSKU := make(map[string]int)
+	for i := range rows {
+		checkImportVendor(&rows[i], vendors)
+		checkImportSKU(&rows[i], skus, seenSKU)
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		id := row.Product.ProductID
+
+		if id == 0 {
+			if applyImportFamily(row, families) {
+				row.Action = ImportInsert
+			}
+			continue
+		}
+
+		if line, ok := seen[id]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("Product #%d is already on line %d.", id, line))
+			continue
+		}
+		seen[id] = row.Line
+
+		before, ok := existing[id]
+		if !ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("Product #%d does not exist.", id))
+			continue
+		}
+		keepUnmapped(&row.Product, before, row.unmapped)
+		row.Product.Discontinued = before.Discontinued
+		row.Product.ReplacedBy = before.ReplacedBy
+		if !applyImportFamily(row, families) {
+			continue
+		}
+
+		row.OldUnitPrice = before.UnitPrice
+		row.Changes = productChanges(before, row.Product)
+		if len(row.Changes) == 0 {
+			row.Action = ImportUnchanged
+		} else {
+			row.Action = ImportUpdate
+		}
+	}
+
+	return nil
+}
+
+// existingVendors returns the IDs of the vendors the rows name that exist.
+func existingVendors(q queryer, rows []ProductImportRow) (map[int]bool, error) {
+	var ids []int64
+	for _, r := range rows {
+		if r.Product.VendorID > 0 {
+			ids = append(ids, int64(r.Product.VendorID))
+		}
+	}
+
+	found := make(map[int]bool, len(ids))
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	result, err := q.Query(`SELECT vendor_id FROM vendors WHERE vendor_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var id int
+		if err := result.Scan(&id); err != nil {
+			return nil, err
+		}
+		found[id] = true
+	}
+
+	return found, result.Err()
+}
+
+// existingSKUs maps the SKUs the rows use to the products that already have them.
+func existingSKUs(q queryer, rows []ProductImportRow) (map[string]int, error) {
+	var skus []string
+	for _, r := range rows {
+		if r.Product.SKU != "" {
+			skus = append(skus, r.Product.SKU)
+		}
+	}
+
+	found := make(map[string]int, len(skus))
+	if len(skus) == 0 {
+		return found, nil
+	}
+
+	result, err := q.Query(`SELECT sku, product_id FROM products WHERE sku = ANY($1)`, pq.Array(skus))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var (
+			sku string
+			id  int
+		)
+		if err := result.Scan(&sku, &id); err != nil {
+			return nil, err
+		}
+		found[sku] = id
+	}
+
+	return found, result.Err()
+}
+
+// checkImportVendor reports a row that names a vendor that does not exist.
+func checkImportVendor(row *ProductImportRow, vendors map[int]bool) {
+	id := row.Product.VendorID
+	if id == 0 || vendors[id] {
+		return
+	}
+	row.Errors = append(row.Errors, fmt.Sprintf("Vendor #%d does not exist.", id))
+}
+
+// checkImportSKU reports a row whose SKU belongs to another product or is repeated in the file.
+func checkImportSKU(row *ProductImportRow, skus map[string]int, seen map[string]int) {
+	sku := row.Product.SKU
+	if sku == "" {
+		return
+	}
+
+	if line, ok := seen[sku]; ok {
+		row.Errors = append(row.Errors, fmt.Sprintf("SKU %q is already used on line %d.", sku, line))
+		return
+	}
+	seen[sku] = row.Line
+
+	if id, ok := skus[sku]; ok && id != row.Product.ProductID {
+		row.Errors = append(row.Errors, fmt.Sprintf("SKU %q already belongs to product #%d.", sku, id))
+	}
+}
+
+// applyImportFamily copies the shared fields of a row's family onto it. It reports whether the row is free of
+// errors afterwards.
+func applyImportFamily(row *ProductImportRow, families map[int]ProductFamily) bool {
+	if row.Product.FamilyID != 0 {
+		f, ok := families[row.Product.FamilyID]
+		if !ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("Family #%d does not exist.", row.Product.FamilyID))
+		} else {
+			f.applyTo(&row.Product)
+		}
+	}
+
+	return len(row.Errors) == 0
+}
+
+// keepUnmapped copies the fields an import file has no column for from the product being updated.
+func keepUnmapped(p *Product, before Product, unmapped []string) {
+	for _, field := range unmapped {
+		switch field {
+		case "description":
+			p.Description = before.Description
+		case "color":
+			p.Color = before.Color
+		case "length":
+			p.Length = before.Length
+		case "width":
+			p.Width = before.Width
+		case "height":
+			p.Height = before.Height
+		case "family_id":
+			p.FamilyID = before.FamilyID
+		case "finish":
+			p.Finish = before.Finish
+		case "size":
+			p.Size = before.Size
+		case "sku":
+			p.SKU = before.SKU
+		case "model_number":
+			p.ModelNumber = before.ModelNumber
+		case "vendor_id":
+			p.VendorID = before.VendorID
+		case "vendor_cost":
+			p.VendorCost = before.VendorCost
+		case "lead_time_days":
+			p.LeadTimeDays = before.LeadTimeDays
+		}
+	}
+}
+
+// parseProductImport reads and validates every data row of an import file.
+func parseProductImport(r io.Reader, mapping map[string]string) ([]ProductImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: the file is empty", ErrInvalidImport)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	columns, err := importColumns(header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []ProductImportRow
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+
+		if isBlankRecord(record) {
+			continue
+		}
+
+		rows = append(rows, parseImportRecord(line, record, columns))
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file has no product rows", ErrInvalidImport)
+	}
+
+	return rows, nil
+}
+
+// importColumns finds the column index of every catalog field in the header row. Headers are matched without
+// regard to case or surrounding spaces. Fields whose column is missing get an index of -1.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+
+	columns := make(map[string]int, len(ProductCSVFields))
+	for _, field := range ProductCSVFields {
+		name := field
+		if mapped := strings.TrimSpace(mapping[field]); mapped != "" {
+			name = mapped
+		}
+
+		i, ok := index[strings.ToLower(name)]
+		if !ok {
+			if slices.Contains(requiredImportFields, field) {
+				return nil, fmt.Errorf("%w: no %q column was found for %s", ErrInvalidImport, name, field)
+			}
+			i = -1
+		}
+		columns[field] = i
+	}
+
+	return columns, nil
+}
+
+// parseImportRecord converts a single CSV record into a product, collecting every problem with it.
+func parseImportRecord(line int, record []string, columns map[string]int) ProductImportRow {
+	row := ProductImportRow{Line: line}
+	for field, i := range columns {
+		if i < 0 {
+			row.unmapped = append(row.unmapped, field)
+		}
+	}
+
+	value := func(field string) string {
+		i := columns[field]
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	fail := func(format string, args ...any) {
+		row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+	}
+
+	p := &row.Product
+	p.Name = value("name")
+	p.Description = value("description")
+	p.Category = value("category")
+	p.Subcategory = value("subcategory")
+	p.Color = value("color")
+
+	if v := value("product_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			fail("Product ID %q is not a valid ID.", v)
+		} else {
+			p.ProductID = id
+		}
+	}
+
+	p.Finish = value("finish")
+	p.Size = value("size")
+
+	if v := value("family_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			fail("Family ID %q is not a valid ID.", v)
+		} else {
+			p.FamilyID = id
+		}
+	}
+
+	// Variants take their name, description and category from their family.
+	if p.FamilyID == 0 {
+		if p.Name == "" {
+			fail("Name cannot be blank.")
+		} else if utf8.RuneCountInString(p.Name) > 100 {
+			fail("Name cannot be more than 100 characters long.")
+		}
+		if utf8.RuneCountInString(p.Description) > 255 {
+			fail("Description cannot be more than 255 characters long.")
+		}
+
+		// Categories are matched without regard to case and stored the way the catalog spells them.
+		i := slices.IndexFunc(ProductCategories, func(c string) bool { return strings.EqualFold(c, p.Category) })
+		if i < 0 {
+			fail("%q is not a product category.", p.Category)
+		} else {
+			p.Category = ProductCategories[i]
+		}
+
+		if p.Subcategory == "" {
+			fail("Subcategory cannot be blank.")
+		} else if utf8.RuneCountInString(p.Subcategory) > 50 {
+			fail("Subcategory cannot be more than 50 characters long.")
+		}
+	}
+
+	if utf8.RuneCountInString(p.Color) > 20 {
+		fail("Color cannot be more than 20 characters long.")
+	}
+	if utf8.RuneCountInString(p.Finish) > 50 {
+		fail("Finish cannot be more than 50 characters long.")
+	}
+	if utf8.RuneCountInString(p.Size) > 50 {
+		fail("Size cannot be more than 50 characters long.")
+	}
+	price, err := parseDollars(value("unit_price"))
+	if err != nil || price <= 0 {
+		fail("Unit price %q must be a dollar amount greater than zero.", value("unit_price"))
+	} else {
+		p.UnitPrice = price
+	}
+
+	p.SKU = value("sku")
+	p.ModelNumber = value("model_number")
+	if utf8.RuneCountInString(p.SKU) > 50 {
+		fail("SKU cannot be more than 50 characters long.")
+	}
+	if utf8.RuneCountInString(p.ModelNumber) > 50 {
+		fail("Model number cannot be more than 50 characters long.")
+	}
+
+	if v := value("vendor_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			fail("Vendor ID %q is not a valid ID.", v)
+		} else {
+			p.VendorID = id
+		}
+	}
+
+	if v := value("vendor_cost"); v != "" {
+		cost, err := parseDollars(v)
+		if err != nil || cost < 0 {
+			fail("Vendor cost %q must be a dollar amount that is not negative.", v)
+		} else {
+			p.VendorCost = cost
+		}
+	}
+
+	if v := value("lead_time_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			fail("Lead time %q must be a whole number of days that is not negative.", v)
+		} else {
+			p.LeadTimeDays = days
+		}
+	}
+
+	for _, dim := range []struct {
+		field string
+		dst   *float32
+	}{{"length", &p.Length}, {"width", &p.Width}, {"height", &p.Height}} {
+		v := value(dim.field)
+		if v == "" {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil || f < 0 {
+			fail("%s %q must be a number of inches that is not negative.", strings.ToUpper(dim.field[:1])+dim.field[1:], v)
+			continue
+		}
+		*dim.dst = float32(f)
+	}
+
+	return row
+}
+
+// parseDollars converts a dollar amount such as "$1,249.50" into cents.
+func parseDollars(s string) (int, error) {
+	s = strings.ReplaceAll(strings.TrimPrefix(s, "$"), ",", "")
+
+	dollars, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(math.Round(dollars * 100)), nil
+}
+
+// isBlankRecord reports whether every field of a record is empty, as spreadsheets often leave trailing rows.
+func isBlankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
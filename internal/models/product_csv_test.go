@@ -0,0 +1,39 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProductImportLengths(t *testing.T) {
+	file := "name,category,subcategory,color,unit_price\n" +
+		strings.Repeat("n", 101) + ",Appliances,Hood,White,899\n" +
+		"Range Hood,Appliances,Hood," + strings.Repeat("c", 21) + ",899\n" +
+		strings.Repeat("n", 100) + ",Appliances,Hood," + strings.Repeat("c", 20) + ",899\n"
+
+	rows, err := parseProductImport(strings.NewReader(file), nil)
+	if err != nil {
+		t.Fatalf("parseProductImport failed: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+
+	tests := []struct {
+		line int
+		want string
+	}{
+		{2, "Name cannot be more than 100 characters long."},
+		{3, "Color cannot be more than 20 characters long."},
+	}
+	for i, tt := range tests {
+		row := rows[i]
+		if row.Line != tt.line || !strings.Contains(strings.Join(row.Errors, " "), tt.want) {
+			t.Errorf("line %d: expected %q, got %v", tt.line, tt.want, row.Errors)
+		}
+	}
+
+	if len(rows[2].Errors) != 0 {
+		t.Errorf("line 4: expected values at the column sizes to pass, got %v", rows[2].Errors)
+	}
+}
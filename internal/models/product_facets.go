@@ -0,0 +1,86 @@
+// models/product_facets.go pages through the results of a catalog search and counts the categories,
+// subcategories and colors they cover, so the filters offered to surveyors come from the catalog itself.
+
+package models
+
+// Facet is a value of a product field and how many products in a result set have it.
+type Facet struct {
+	Value string
+	Count int
+}
+
+// ProductFacets counts the distinct categories, subcategories and colors of a result set, most common first.
+type ProductFacets struct {
+	Categories    []Facet
+	Subcategories []Facet
+	Colors        []Facet
+}
+
+// ProductPage is one page of the results of a search. Total and Facets cover every result, not only the page.
+type ProductPage struct {
+	Products []Product
+	Total    int
+	Facets   ProductFacets
+}
+
+// SearchPage returns up to limit products matching a search, skipping the first offset, along with the facets of
+// every match. Unlike Search, text searches are not capped since the results are paged.
+func (m *ProductModel) SearchPage(s ProductSearch, limit, offset int) (ProductPage, error) {
+	terms := s.terms()
+	args := s.searchArgs(terms)
+
+	var page ProductPage
+
+	stmt := `SELECT ` + productColumns + ` FROM (` + searchMatchesStmt + `) matches
+	` + searchOrder + `
+	LIMIT $13 OFFSET $14`
+
+	products, err := m.queryProducts(stmt, append(args, limit, offset)...)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	page.Products = products
+
+	stmt = `WITH matches AS (` + searchMatchesStmt + `)
+	SELECT 'category', category, COUNT(*) FROM matches GROUP BY category
+	UNION ALL
+	SELECT 'subcategory', COALESCE(subcategory, ''), COUNT(*) FROM matches GROUP BY COALESCE(subcategory, '')
+	UNION ALL
+	SELECT 'color', COALESCE(color, ''), COUNT(*) FROM matches GROUP BY COALESCE(color, '')
+	ORDER BY 1, 3 DESC, 2`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var field string
+		var f Facet
+		if err := rows.Scan(&field, &f.Value, &f.Count); err != nil {
+			return ProductPage{}, err
+		}
+
+		switch field {
+		case "category":
+			// Every match has exactly one category, so the category counts add up to the total.
+			page.Total += f.Count
+			page.Facets.Categories = append(page.Facets.Categories, f)
+		case "subcategory":
+			if f.Value != "" {
+				page.Facets.Subcategories = append(page.Facets.Subcategories, f)
+			}
+		case "color":
+			if f.Value != "" {
+				page.Facets.Colors = append(page.Facets.Colors, f)
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return ProductPage{}, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,301 @@
+// models/product_family.go groups near-identical products into families. A family holds the name, description,
+// category and dimensions its variants share; variants differ by color, finish, size and price.
+// Shared fields are copied onto every variant so estimates, clearance checks and exports keep reading products.
+
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// ProductFamily is a group of products that are variants of one another.
+// A family with a FamilyID of 0 wraps a single standalone product.
+type ProductFamily struct {
+	FamilyID    int
+	Name        string
+	Description string
+	Category    string
+	Subcategory string
+	Length      float32
+	Width       float32
+	Height      float32
+	Variants    []Product
+}
+
+// HasVariants reports whether there is more than one product to choose from.
+func (f ProductFamily) HasVariants() bool {
+	return len(f.Variants) > 1
+}
+
+// applyTo copies the shared fields of a family onto one of its variants. Variants with a size have their own
+// dimensions and keep them.
+func (f ProductFamily) applyTo(p *Product) {
+	p.FamilyID = f.FamilyID
+	p.Name = f.Name
+	p.Description = f.Description
+	p.Category = f.Category
+	p.Subcategory = f.Subcategory
+
+	if p.Size == "" {
+		p.Length = f.Length
+		p.Width = f.Width
+		p.Height = f.Height
+	}
+}
+
+// ProductFamilyModel wraps a sql.DB connection and provides methods for product families.
+type ProductFamilyModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new family and assigns the generated FamilyID to the struct.
+func (m *ProductFamilyModel) Insert(f *ProductFamily) error {
+	stmt := `INSERT INTO product_families (name, description, category, subcategory, length, width, height)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING family_id`
+
+	return m.DB.QueryRow(stmt, f.Name, f.Description, f.Category, f.Subcategory, f.Length, f.Width, f.Height).
+		Scan(&f.FamilyID)
+}
+
+// Get retrieves a family along with its variants, cheapest first. Returns ErrNoRecord if the family does not exist.
+func (m *ProductFamilyModel) Get(id int) (ProductFamily, error) {
+	families, err := getFamilies(m.DB, []int64{int64(id)}, "")
+	if err != nil {
+		return ProductFamily{}, err
+	}
+
+	f, ok := families[id]
+	if !ok {
+		return ProductFamily{}, ErrNoRecord
+	}
+
+	stmt := `SELECT ` + productColumns + ` FROM products WHERE family_id=$1 ORDER BY unit_price, product_id`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return ProductFamily{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Product
+		err := scanProduct(rows, &p)
+		if err != nil {
+			return ProductFamily{}, err
+		}
+		f.Variants = append(f.Variants, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ProductFamily{}, err
+	}
+
+	return f, nil
+}
+
+// GetAll returns every family ordered by category and name. Variants are not loaded.
+func (m *ProductFamilyModel) GetAll() ([]ProductFamily, error) {
+	stmt := `SELECT family_id, name, description, category, subcategory, length, width, height
+	FROM product_families
+	ORDER BY category, subcategory, name, family_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var families []ProductFamily
+	for rows.Next() {
+		var f ProductFamily
+		err := rows.Scan(&f.FamilyID, &f.Name, &f.Description, &f.Category, &f.Subcategory, &f.Length, &f.Width, &f.Height)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return families, nil
+}
+
+// Update saves a family and copies its shared fields onto every variant in one transaction. Each variant that
+// changes is recorded in the product audit. Returns ErrNoRecord if the family does not exist.
+func (m *ProductFamilyModel) Update(f *ProductFamily, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE product_families
+	SET name=$2, description=$3, category=$4, subcategory=$5, length=$6, width=$7, height=$8
+	WHERE family_id=$1`
+
+	result, err := tx.Exec(stmt, f.FamilyID, f.Name, f.Description, f.Category, f.Subcategory, f.Length, f.Width, f.Height)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	rows, err := tx.Query(`SELECT product_id FROM products WHERE family_id=$1 ORDER BY product_id FOR UPDATE`, f.FamilyID)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		p, err := getProductForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = updateAudited(tx, &p, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFamiliesByFilter returns the products matching a filter grouped into families, the way they are offered
+// when adding a line item. Empty filter values are treated as wildcards.
+func (m *ProductModel) GetFamiliesByFilter(category, subcategory, color string) ([]ProductFamily, error) {
+	products, err := m.GetByProductFilter(category, subcategory, color)
+	if err != nil {
+		return nil, err
+	}
+
+	return groupFamilies(m.DB, products)
+}
+
+// groupFamilies groups products into families in the order they first appear. Products outside a family become
+// a family of one with a FamilyID of 0. Within a family the cheapest variant comes first.
+func groupFamilies(q queryer, products []Product) ([]ProductFamily, error) {
+	var ids []int64
+	for _, p := range products {
+		if p.FamilyID != 0 {
+			ids = append(ids, int64(p.FamilyID))
+		}
+	}
+
+	shared, err := getFamilies(q, ids, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var families []ProductFamily
+	index := make(map[int]int)
+	for _, p := range products {
+		if i, ok := index[p.FamilyID]; ok && p.FamilyID != 0 {
+			families[i].Variants = append(families[i].Variants, p)
+			continue
+		}
+
+		f, ok := shared[p.FamilyID]
+		if !ok {
+			f = ProductFamily{
+				Name:        p.Name,
+				Description: p.Description,
+				Category:    p.Category,
+				Subcategory: p.Subcategory,
+				Length:      p.Length,
+				Width:       p.Width,
+				Height:      p.Height,
+			}
+		}
+		f.Variants = []Product{p}
+
+		index[f.FamilyID] = len(families)
+		families = append(families, f)
+	}
+
+	// Offer the cheapest variant first.
+	for _, f := range families {
+		slices.SortStableFunc(f.Variants, func(a, b Product) int { return a.UnitPrice - b.UnitPrice })
+	}
+
+	return families, nil
+}
+
+// getFamilies loads the families with the given IDs, without variants. lock is appended to the SELECT.
+func getFamilies(q queryer, ids []int64, lock string) (map[int]ProductFamily, error) {
+	families := make(map[int]ProductFamily, len(ids))
+	if len(ids) == 0 {
+		return families, nil
+	}
+
+	stmt := `SELECT family_id, name, description, category, subcategory, length, width, height
+	FROM product_families WHERE family_id = ANY($1) ORDER BY family_id ` + lock
+
+	rows, err := q.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f ProductFamily
+		err := rows.Scan(&f.FamilyID, &f.Name, &f.Description, &f.Category, &f.Subcategory, &f.Length, &f.Width, &f.Height)
+		if err != nil {
+			return nil, err
+		}
+		families[f.FamilyID] = f
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return families, nil
+}
+
+// applyFamily copies the shared fields of the product's family onto it. Standalone products are left as they are.
+func applyFamily(q queryer, p *Product) error {
+	if p.FamilyID == 0 {
+		return nil
+	}
+
+	families, err := getFamilies(q, []int64{int64(p.FamilyID)}, "")
+	if err != nil {
+		return err
+	}
+
+	f, ok := families[p.FamilyID]
+	if !ok {
+		return fmt.Errorf("%w: product family #%d", ErrNoRecord, p.FamilyID)
+	}
+
+	f.applyTo(p)
+	return nil
+}
@@ -0,0 +1,46 @@
+// models/product_image.go records which photo a product shows. The image files themselves live in object storage
+// under the product's ImageKey.
+
+package models
+
+import "path"
+
+// SetImage points a product at a newly uploaded photo, or removes its photo when key is "", and records who did
+// it. It returns the key of the photo it replaced so the caller can delete those files.
+// Returns ErrNoRecord if the product does not exist.
+func (m *ProductModel) SetImage(productID int, key string, userID int) (string, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := getProductForUpdate(tx, productID)
+	if err != nil {
+		return "", err
+	}
+	if before.ImageKey == key {
+		return "", tx.Commit()
+	}
+
+	_, err = tx.Exec(`UPDATE products SET image_key=$2 WHERE product_id=$1`, productID, key)
+	if err != nil {
+		return "", err
+	}
+
+	changes := []FieldChange{{Field: "Image", From: imageLabel(before.ImageKey), To: imageLabel(key)}}
+	err = insertProductAudit(tx, productID, before.Name, AuditUpdate, userID, changes)
+	if err != nil {
+		return "", err
+	}
+
+	return before.ImageKey, tx.Commit()
+}
+
+// imageLabel names a product photo in the audit log by the upload it came from.
+func imageLabel(key string) string {
+	if key == "" {
+		return "None"
+	}
+	return path.Base(key)
+}
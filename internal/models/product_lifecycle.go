@@ -0,0 +1,235 @@
+// models/product_lifecycle.go discontinues products. Products that are on estimates cannot be deleted, so they are
+// discontinued instead: they stop being offered for new line items but keep rendering on existing estimates.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// DiscontinuedLineItem is a line item on a Draft estimate whose product has been discontinued.
+type DiscontinuedLineItem struct {
+	LineItemID   int
+	EstimateID   int
+	CustomerName string
+	Quantity     int
+	Product      Product
+}
+
+// List returns every product in a category, or every product when category is empty, including discontinued ones.
+func (m *ProductModel) List(category string) ([]Product, error) {
+	stmt := `SELECT ` + productColumns + `
+	FROM products
+	WHERE ($1='' OR category=$1)
+	ORDER BY category, subcategory, name, product_id`
+
+	rows, err := m.DB.Query(stmt, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		err := scanProduct(rows, &p)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// Discontinue stops a product from being offered for new line items and records who did it. replacementID is the
+// product suggested in its place, or 0 for none. Returns ErrNoRecord if the product does not exist and
+// ErrInvalidReplacement if the replacement is the product itself, does not exist or is discontinued.
+func (m *ProductModel) Discontinue(id, replacementID, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replacementID != 0 {
+		var active bool
+		err := tx.QueryRow(`SELECT discontinued_at IS NULL FROM products WHERE product_id=$1 FOR SHARE`, replacementID).
+			Scan(&active)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if !active || replacementID == id {
+			return ErrInvalidReplacement
+		}
+	}
+
+	err = setLifecycle(tx, id, true, replacementID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reactivate offers a discontinued product for new line items again and clears its replacement.
+// Returns ErrNoRecord if the product does not exist.
+func (m *ProductModel) Reactivate(id, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = setLifecycle(tx, id, false, 0, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SuggestReplacements returns up to limit active products that could stand in for a discontinued one. The chosen
+// replacement comes first, then other variants of the same family, then the products of the same subcategory
+// closest in price.
+func (m *ProductModel) SuggestReplacements(p Product, limit int) ([]Product, error) {
+	stmt := `SELECT ` + productColumns + `
+	FROM products
+	WHERE discontinued_at IS NULL
+	AND product_id <> $1
+	AND (product_id = $2 OR (category = $3 AND subcategory = $4))
+	ORDER BY
+		CASE WHEN product_id = $2 THEN 0 WHEN $5 <> 0 AND family_id = $5 THEN 1 ELSE 2 END,
+		ABS(unit_price - $6),
+		product_id
+	LIMIT $7`
+
+	rows, err := m.DB.Query(stmt, p.ProductID, p.ReplacedBy, p.Category, p.Subcategory, p.FamilyID, p.UnitPrice, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var s Product
+		err := scanProduct(rows, &s)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// GetDiscontinuedDraftItems returns every line item on a Draft estimate whose product has been discontinued,
+// oldest estimate first.
+func (m *EstimateItemModel) GetDiscontinuedDraftItems() ([]DiscontinuedLineItem, error) {
+	stmt := `SELECT ei.line_item_id, e.estimate_id, COALESCE(u.name, ''), ei.quantity,
+	p.product_id, p.name, p.description, p.category, p.subcategory, p.color,
+	p.unit_price, p.length, p.width, p.height, p.created_by, COALESCE(p.family_id, 0), p.finish, p.size,
+	TRUE, COALESCE(p.replaced_by, 0)
+	FROM estimate_items ei
+	JOIN estimates e ON e.estimate_id = ei.estimate_id
+	JOIN products p ON p.product_id = ei.product_id
+	LEFT JOIN users u ON u.user_id = e.customer_id
+	WHERE e.status = $1 AND p.discontinued_at IS NOT NULL
+	ORDER BY e.estimate_id, ei.line_item_id`
+
+	rows, err := m.DB.Query(stmt, StatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []DiscontinuedLineItem
+	for rows.Next() {
+		var (
+			item DiscontinuedLineItem
+			p    = &item.Product
+		)
+		err := rows.Scan(&item.LineItemID, &item.EstimateID, &item.CustomerName, &item.Quantity,
+			&p.ProductID, &p.Name, &p.Description, &p.Category, &p.Subcategory, &p.Color,
+			&p.UnitPrice, &p.Length, &p.Width, &p.Height, &p.CreatedBy, &p.FamilyID, &p.Finish, &p.Size,
+			&p.Discontinued, &p.ReplacedBy)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ReplaceProduct swaps the catalog product of a line item on a Draft estimate, keeping its quantity and option.
+// Returns ErrNoRecord if there is no such catalog line item, ErrInvalidReplacement if the replacement does not
+// exist or is discontinued and ErrEstimateLocked if the estimate is not a Draft.
+func (m *EstimateItemModel) ReplaceProduct(lineItemID, productID int) error {
+	stmt := `UPDATE estimate_items ei SET product_id=$2
+	FROM estimates e, products p
+	WHERE e.estimate_id = ei.estimate_id AND ei.line_item_id=$1 AND ei.product_id IS NOT NULL AND e.status=$3
+	AND p.product_id = $2 AND p.discontinued_at IS NULL
+	RETURNING ei.line_item_id`
+
+	var id int
+	err := m.DB.QueryRow(stmt, lineItemID, productID, StatusDraft).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		var status EstimateStatus
+		err := m.DB.QueryRow(`SELECT e.status FROM estimate_items ei JOIN estimates e USING (estimate_id)
+		WHERE ei.line_item_id=$1 AND ei.product_id IS NOT NULL`, lineItemID).Scan(&status)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNoRecord
+			}
+			return err
+		}
+		if status != StatusDraft {
+			return ErrEstimateLocked
+		}
+		return ErrInvalidReplacement
+	}
+
+	return err
+}
+
+// setLifecycle discontinues or reactivates a product inside a transaction and records the change.
+func setLifecycle(tx *sql.Tx, id int, discontinued bool, replacementID, userID int) error {
+	before, err := getProductForUpdate(tx, id)
+	if err != nil {
+		return err
+	}
+
+	after := before
+	after.Discontinued = discontinued
+	after.ReplacedBy = replacementID
+
+	changes := productChanges(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	stmt := `UPDATE products
+	SET discontinued_at = CASE WHEN $2 THEN COALESCE(discontinued_at, NOW()) ELSE NULL END, replaced_by = NULLIF($3, 0)
+	WHERE product_id=$1`
+
+	_, err = tx.Exec(stmt, id, discontinued, replacementID)
+	if err != nil {
+		return err
+	}
+
+	return insertProductAudit(tx, id, before.Name, AuditUpdate, userID, changes)
+}
@@ -0,0 +1,278 @@
+// models/product_price.go keeps the price history of each product and the prices scheduled to replace the current
+// one. products.unit_price stays the price in effect; ApplyScheduledPrices moves scheduled prices into it once
+// their day comes.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ProductPrice is a price a product had, has, or is scheduled to have from EffectiveFrom. AppliedAt is unset while
+// the price is still scheduled.
+type ProductPrice struct {
+	PriceID       int
+	ProductID     int
+	ProductName   string
+	UnitPrice     int
+	EffectiveFrom time.Time
+	CreatedBy     int
+	CreatedByName string
+	CreatedAt     time.Time
+	AppliedAt     sql.NullTime
+}
+
+// Scheduled reports whether the price has yet to take effect.
+func (p ProductPrice) Scheduled() bool {
+	return !p.AppliedAt.Valid
+}
+
+// PriceChangeImpact is a product on an open Draft estimate whose price is scheduled to change.
+type PriceChangeImpact struct {
+	EstimateID    int
+	CustomerName  string
+	ProductID     int
+	ProductName   string
+	Quantity      int
+	CurrentPrice  int
+	NewPrice      int
+	EffectiveFrom time.Time
+}
+
+// Difference is how much the estimate changes by, in cents, when the new price takes effect.
+func (i PriceChangeImpact) Difference() int {
+	return (i.NewPrice - i.CurrentPrice) * i.Quantity
+}
+
+const productPriceColumns = `pp.price_id, pp.product_id, p.name, pp.unit_price, pp.effective_from,
+	COALESCE(pp.created_by, 0), COALESCE(u.name, ''), pp.created_at, pp.applied_at`
+
+const productPriceJoins = `FROM product_prices pp
+	JOIN products p ON p.product_id = pp.product_id
+	LEFT JOIN users u ON u.user_id = pp.created_by`
+
+func scanProductPrices(rows *sql.Rows) ([]ProductPrice, error) {
+	defer rows.Close()
+
+	var prices []ProductPrice
+	for rows.Next() {
+		var pp ProductPrice
+		err := rows.Scan(&pp.PriceID, &pp.ProductID, &pp.ProductName, &pp.UnitPrice, &pp.EffectiveFrom,
+			&pp.CreatedBy, &pp.CreatedByName, &pp.CreatedAt, &pp.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, pp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// GetPriceHistory returns every price of a product, scheduled ones included, oldest first.
+func (m *ProductModel) GetPriceHistory(productID int) ([]ProductPrice, error) {
+	stmt := `SELECT ` + productPriceColumns + ` ` + productPriceJoins + `
+	WHERE pp.product_id = $1
+	ORDER BY pp.effective_from, pp.applied_at NULLS LAST, pp.price_id`
+
+	rows, err := m.DB.Query(stmt, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanProductPrices(rows)
+}
+
+// GetScheduledPrices returns every price that has yet to take effect, soonest first.
+func (m *ProductModel) GetScheduledPrices() ([]ProductPrice, error) {
+	stmt := `SELECT ` + productPriceColumns + ` ` + productPriceJoins + `
+	WHERE pp.applied_at IS NULL
+	ORDER BY pp.effective_from, p.name, pp.price_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanProductPrices(rows)
+}
+
+// SchedulePrice sets the price a product will have from effectiveFrom. A price already scheduled for that day is
+// replaced. Returns ErrNoRecord if the product does not exist and ErrInvalidPriceDate unless effectiveFrom is
+// after today.
+func (m *ProductModel) SchedulePrice(productID, unitPrice int, effectiveFrom time.Time, userID int) error {
+	day := effectiveFrom.Format("2006-01-02")
+
+	var future bool
+	err := m.DB.QueryRow(`SELECT $1::date > CURRENT_DATE`, day).Scan(&future)
+	if err != nil {
+		return err
+	}
+	if !future {
+		return ErrInvalidPriceDate
+	}
+
+	stmt := `INSERT INTO product_prices (product_id, unit_price, effective_from, created_by)
+	SELECT product_id, $2, $3, $4 FROM products WHERE product_id = $1
+	ON CONFLICT (product_id, effective_from) WHERE applied_at IS NULL
+	DO UPDATE SET unit_price = EXCLUDED.unit_price, created_by = EXCLUDED.created_by, created_at = NOW()`
+
+	result, err := m.DB.Exec(stmt, productID, unitPrice, day, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// CancelScheduledPrice removes a price that has not taken effect yet and returns the product it was for.
+// Returns ErrNoRecord if there is no such scheduled price.
+func (m *ProductModel) CancelScheduledPrice(priceID int) (int, error) {
+	var productID int
+	err := m.DB.QueryRow(`DELETE FROM product_prices WHERE price_id = $1 AND applied_at IS NULL RETURNING product_id`,
+		priceID).Scan(&productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return productID, nil
+}
+
+// ApplyScheduledPrices puts every scheduled price whose day has come into effect and returns how many products
+// were repriced. Only Draft estimates change with it; the others keep the prices snapshot when they left Draft. When several prices for a product are due, the latest one wins. Each change is audited as made by
+// whoever scheduled it.
+func (m *ProductModel) ApplyScheduledPrices() (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE product_prices pp SET applied_at = NOW()
+	WHERE pp.applied_at IS NULL AND pp.effective_from <= CURRENT_DATE
+	RETURNING pp.product_id, pp.unit_price, pp.effective_from, COALESCE(pp.created_by, 0)`
+
+	rows, err := tx.Query(stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	type due struct {
+		price         int
+		effectiveFrom time.Time
+		userID        int
+	}
+	latest := make(map[int]due)
+	for rows.Next() {
+		var productID int
+		var d due
+		err := rows.Scan(&productID, &d.price, &d.effectiveFrom, &d.userID)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if current, ok := latest[productID]; !ok || d.effectiveFrom.After(current.effectiveFrom) {
+			latest[productID] = d
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	repriced := 0
+	for productID, d := range latest {
+		before, err := getProductForUpdate(tx, productID)
+		if err != nil {
+			return 0, err
+		}
+		if before.UnitPrice == d.price {
+			continue
+		}
+
+		_, err = tx.Exec(`UPDATE products SET unit_price = $2 WHERE product_id = $1`, productID, d.price)
+		if err != nil {
+			return 0, err
+		}
+
+		if d.userID != 0 {
+			changes := []FieldChange{{Field: "Unit Price", From: dollarLabel(before.UnitPrice), To: dollarLabel(d.price)}}
+			err = insertProductAudit(tx, productID, before.Name, AuditUpdate, d.userID, changes)
+			if err != nil {
+				return 0, err
+			}
+		}
+		repriced++
+	}
+
+	return repriced, tx.Commit()
+}
+
+// GetUpcomingPriceImpact lists the products on open Draft estimates that have a scheduled price change, by the
+// date the change takes effect. Drafts are priced from the catalog, so their totals move when the price does; every
+// other estimate keeps the prices it had when it left Draft (see SnapshotPricesTx).
+func (m *ProductModel) GetUpcomingPriceImpact() ([]PriceChangeImpact, error) {
+	stmt := `SELECT e.estimate_id, COALESCE(u.name, ''), p.product_id, p.name, SUM(ei.quantity)::int,
+	p.unit_price, pp.unit_price, pp.effective_from
+	FROM product_prices pp
+	JOIN products p ON p.product_id = pp.product_id
+	JOIN estimate_items ei ON ei.product_id = pp.product_id
+	JOIN estimates e ON e.estimate_id = ei.estimate_id
+	LEFT JOIN users u ON u.user_id = e.customer_id
+	WHERE pp.applied_at IS NULL AND e.status = $1
+	GROUP BY e.estimate_id, u.name, p.product_id, p.name, p.unit_price, pp.price_id, pp.unit_price, pp.effective_from
+	ORDER BY pp.effective_from, e.estimate_id, p.name`
+
+	rows, err := m.DB.Query(stmt, StatusDraft)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var impacts []PriceChangeImpact
+	for rows.Next() {
+		var i PriceChangeImpact
+		err := rows.Scan(&i.EstimateID, &i.CustomerName, &i.ProductID, &i.ProductName, &i.Quantity,
+			&i.CurrentPrice, &i.NewPrice, &i.EffectiveFrom)
+		if err != nil {
+			return nil, err
+		}
+		impacts = append(impacts, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return impacts, nil
+}
+
+// recordPrice adds the price a product has from today to its history.
+func recordPrice(exec executor, productID, unitPrice, userID int) error {
+	stmt := `INSERT INTO product_prices (product_id, unit_price, effective_from, created_by, applied_at)
+	VALUES ($1, $2, CURRENT_DATE, NULLIF($3, 0), NOW())`
+	_, err := exec.Exec(stmt, productID, unitPrice, userID)
+	return err
+}
+
+// dollarLabel formats a price in cents for the audit log.
+func dollarLabel(cents int) string {
+	return fmt.Sprintf("$%.2f", float64(cents)/100)
+}
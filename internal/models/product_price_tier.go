@@ -0,0 +1,205 @@
+// models/product_price_tier.go stores price breaks, ex. cabinets at $180 instead of $200 each when twenty or more
+// are ordered. A tier replaces the unit price of a line item once the line reaches a quantity or the estimate reaches
+// an order value; CalculateEstimateTotals applies them.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// TierBasis is what a price tier's threshold is measured against.
+type TierBasis string
+
+const (
+	// TierQuantity tiers apply once a line item's quantity reaches the threshold.
+	TierQuantity TierBasis = "quantity"
+	// TierOrderValue tiers apply once the estimate's value at list prices reaches the threshold, in cents.
+	TierOrderValue TierBasis = "order_value"
+)
+
+// TierBases lists every TierBasis in the order they are offered.
+var TierBases = []TierBasis{TierQuantity, TierOrderValue}
+
+// PriceTier is a unit price a product sells at once its threshold is reached.
+type PriceTier struct {
+	TierID    int
+	ProductID int
+	Basis     TierBasis
+	Threshold int
+	UnitPrice int
+}
+
+// IsQuantity reports whether the tier is a quantity break rather than an order value break.
+func (t PriceTier) IsQuantity() bool {
+	return t.Basis == TierQuantity
+}
+
+// Label describes when the tier applies, for the audit log.
+func (t PriceTier) Label() string {
+	if t.IsQuantity() {
+		return strconv.Itoa(t.Threshold) + "+ units"
+	}
+	return "orders of " + dollarLabel(t.Threshold) + "+"
+}
+
+// qualifies reports whether a line item of quantity on an estimate worth orderValue at list prices gets the tier.
+func (t PriceTier) qualifies(quantity, orderValue int) bool {
+	if t.IsQuantity() {
+		return quantity >= t.Threshold
+	}
+	return orderValue >= t.Threshold
+}
+
+// UnitPrice returns the price each unit of the line item sells at: the applied tier's price if it has one and the
+// product's unit price otherwise.
+func (ep EstimateProduct) UnitPrice() int {
+	if ep.AppliedTier != nil {
+		return ep.AppliedTier.UnitPrice
+	}
+	return ep.Product.UnitPrice
+}
+
+// LineTotal returns the price of the whole line item in cents.
+func (ep EstimateProduct) LineTotal() int {
+	return ep.UnitPrice() * ep.EstimateItem.Quantity
+}
+
+// ListValue returns what the line items are worth at their products' unit prices, before any tier applies.
+func ListValue(estimateProducts []EstimateProduct) int {
+	var value int
+	for _, ep := range estimateProducts {
+		value += ep.Product.UnitPrice * ep.EstimateItem.Quantity
+	}
+	return value
+}
+
+// ApplyPriceTiers sets the AppliedTier of each line item to the cheapest of its PriceTiers it qualifies for, given
+// an estimate worth orderValue at list prices. Tiers are only applied when they lower the price.
+func ApplyPriceTiers(estimateProducts []EstimateProduct, orderValue int) {
+	for i := range estimateProducts {
+		ep := &estimateProducts[i]
+		ep.AppliedTier = nil
+		for j, t := range ep.PriceTiers {
+			if !t.qualifies(ep.EstimateItem.Quantity, orderValue) || t.UnitPrice >= ep.UnitPrice() {
+				continue
+			}
+			ep.AppliedTier = &ep.PriceTiers[j]
+		}
+	}
+}
+
+// GetPriceTiers returns the price tiers of the given products keyed by product, quantity breaks first and then in
+// threshold order.
+func (m *ProductModel) GetPriceTiers(productIDs []int) (map[int][]PriceTier, error) {
+	return getPriceTiers(m.DB, productIDs)
+}
+
+func getPriceTiers(db *sql.DB, productIDs []int) (map[int][]PriceTier, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	stmt := `SELECT tier_id, product_id, basis, threshold, unit_price FROM product_price_tiers
+	WHERE product_id = ANY($1)
+	ORDER BY product_id, basis, threshold`
+
+	rows, err := db.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := make(map[int][]PriceTier)
+	for rows.Next() {
+		var t PriceTier
+		err := rows.Scan(&t.TierID, &t.ProductID, &t.Basis, &t.Threshold, &t.UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		tiers[t.ProductID] = append(tiers[t.ProductID], t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tiers, nil
+}
+
+// InsertPriceTier adds a price tier to a product and records it on the product's history. Returns ErrNoRecord if
+// the product does not exist and ErrDuplicatePriceTier if it already has a tier at the same threshold.
+func (m *ProductModel) InsertPriceTier(t *PriceTier, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	product, err := getProductForUpdate(tx, t.ProductID)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO product_price_tiers (product_id, basis, threshold, unit_price, created_by)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0)) RETURNING tier_id`
+
+	err = tx.QueryRow(stmt, t.ProductID, t.Basis, t.Threshold, t.UnitPrice, userID).Scan(&t.TierID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicatePriceTier
+		}
+		return err
+	}
+
+	changes := []FieldChange{{Field: tierField(*t), From: "None", To: dollarLabel(t.UnitPrice)}}
+	err = insertProductAudit(tx, product.ProductID, product.Name, AuditUpdate, userID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeletePriceTier removes a price tier, records it on the product's history and returns the product it belonged to.
+// Returns ErrNoRecord if there is no such tier.
+func (m *ProductModel) DeletePriceTier(tierID, userID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var t PriceTier
+	var name string
+	err = tx.QueryRow(`DELETE FROM product_price_tiers pt USING products p
+	WHERE pt.tier_id = $1 AND p.product_id = pt.product_id
+	RETURNING pt.product_id, pt.basis, pt.threshold, pt.unit_price, p.name`, tierID).
+		Scan(&t.ProductID, &t.Basis, &t.Threshold, &t.UnitPrice, &name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	changes := []FieldChange{{Field: tierField(t), From: dollarLabel(t.UnitPrice), To: "None"}}
+	err = insertProductAudit(tx, t.ProductID, name, AuditUpdate, userID, changes)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.ProductID, tx.Commit()
+}
+
+// tierField names a price tier in the audit log.
+func tierField(t PriceTier) string {
+	return fmt.Sprintf("Price for %s", t.Label())
+}
@@ -0,0 +1,307 @@
+// models/product_rule.go stores the installation rules between catalog products and what products need at the
+// site, along with the site requirements surveyors have confirmed on each estimate. The rules are evaluated by
+// the compat package.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ProductRule links a product to a target product or to any product of TargetSubcategory. Kind is "requires",
+// "excludes" or "recommends".
+type ProductRule struct {
+	RuleID            int
+	ProductID         int
+	ProductName       string
+	Kind              string
+	TargetProductID   int
+	TargetProductName string
+	TargetSubcategory string
+	Note              string
+	CreatedBy         int
+	CreatedAt         time.Time
+}
+
+// TargetLabel describes the target of a rule, ex. "36 in Sink Base" or "any Sink Base".
+func (r ProductRule) TargetLabel() string {
+	if r.TargetProductID != 0 {
+		return r.TargetProductName
+	}
+	return "any " + r.TargetSubcategory
+}
+
+// SiteRequirement is something a product can need at the site, ex. a 240V circuit.
+type SiteRequirement struct {
+	RequirementID int
+	Name          string
+}
+
+// ProductRequirement is a site requirement of one product.
+type ProductRequirement struct {
+	ProductID     int
+	RequirementID int
+	Name          string
+}
+
+// ProductRuleModel wraps a sql.DB connection and provides methods for product rules and site requirements.
+type ProductRuleModel struct {
+	DB *sql.DB
+}
+
+const productRuleSelect = `SELECT r.rule_id, r.product_id, p.name, r.kind, COALESCE(r.target_product_id, 0),
+	COALESCE(t.name, ''), COALESCE(r.target_subcategory, ''), r.note, COALESCE(r.created_by, 0), r.created_at
+	FROM product_rules r
+	JOIN products p ON p.product_id = r.product_id
+	LEFT JOIN products t ON t.product_id = r.target_product_id`
+
+func scanProductRules(rows *sql.Rows) ([]ProductRule, error) {
+	defer rows.Close()
+
+	var rules []ProductRule
+	for rows.Next() {
+		var r ProductRule
+		err := rows.Scan(&r.RuleID, &r.ProductID, &r.ProductName, &r.Kind, &r.TargetProductID,
+			&r.TargetProductName, &r.TargetSubcategory, &r.Note, &r.CreatedBy, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// GetForProducts returns the rules of the given products.
+func (m *ProductRuleModel) GetForProducts(productIDs []int) ([]ProductRule, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := m.DB.Query(productRuleSelect+` WHERE r.product_id = ANY($1) ORDER BY r.product_id, r.kind, r.rule_id`,
+		pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return scanProductRules(rows)
+}
+
+// GetInvolving returns the rules of a product and the rules that target it, its own rules first.
+func (m *ProductRuleModel) GetInvolving(productID int) ([]ProductRule, error) {
+	rows, err := m.DB.Query(productRuleSelect+`
+	WHERE r.product_id = $1 OR r.target_product_id = $1
+	ORDER BY r.product_id <> $1, r.kind, r.rule_id`, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanProductRules(rows)
+}
+
+// Insert adds a rule and assigns the generated RuleID. Returns ErrNoRecord if either product does not exist,
+// ErrInvalidRule if the rule targets its own product and ErrDuplicateRule if the product already has the rule.
+func (m *ProductRuleModel) Insert(r *ProductRule) error {
+	if r.TargetProductID == r.ProductID {
+		return ErrInvalidRule
+	}
+
+	stmt := `INSERT INTO product_rules (product_id, kind, target_product_id, target_subcategory, note, created_by)
+	VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, ''), $5, NULLIF($6, 0))
+	RETURNING rule_id, created_at`
+
+	err := m.DB.QueryRow(stmt, r.ProductID, r.Kind, r.TargetProductID, strings.TrimSpace(r.TargetSubcategory),
+		strings.TrimSpace(r.Note), r.CreatedBy).Scan(&r.RuleID, &r.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23503":
+				return ErrNoRecord
+			case "23505":
+				return ErrDuplicateRule
+			case "23514":
+				return ErrInvalidRule
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes a rule and returns the product it belonged to. Returns ErrNoRecord if the rule does not exist.
+func (m *ProductRuleModel) Delete(ruleID int) (int, error) {
+	var productID int
+	err := m.DB.QueryRow(`DELETE FROM product_rules WHERE rule_id = $1 RETURNING product_id`, ruleID).Scan(&productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+		return 0, err
+	}
+
+	return productID, nil
+}
+
+// GetRequirements returns every site requirement ordered by name.
+func (m *ProductRuleModel) GetRequirements() ([]SiteRequirement, error) {
+	rows, err := m.DB.Query(`SELECT requirement_id, name FROM site_requirements ORDER BY LOWER(name)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requirements []SiteRequirement
+	for rows.Next() {
+		var s SiteRequirement
+		if err := rows.Scan(&s.RequirementID, &s.Name); err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requirements, nil
+}
+
+// GetProductRequirements returns the site requirements of the given products.
+func (m *ProductRuleModel) GetProductRequirements(productIDs []int) ([]ProductRequirement, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	stmt := `SELECT psr.product_id, s.requirement_id, s.name
+	FROM product_site_requirements psr
+	JOIN site_requirements s ON s.requirement_id = psr.requirement_id
+	WHERE psr.product_id = ANY($1)
+	ORDER BY psr.product_id, LOWER(s.name)`
+
+	rows, err := m.DB.Query(stmt, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requirements []ProductRequirement
+	for rows.Next() {
+		var r ProductRequirement
+		if err := rows.Scan(&r.ProductID, &r.RequirementID, &r.Name); err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return requirements, nil
+}
+
+// AddProductRequirement records that a product needs something at the site. A site requirement with a new name
+// is created; names are matched ignoring case. Returns ErrNoRecord if the product does not exist.
+func (m *ProductRuleModel) AddProductRequirement(productID int, name string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var requirementID int
+	stmt := `INSERT INTO site_requirements (name) VALUES ($1)
+	ON CONFLICT ((LOWER(name))) DO UPDATE SET name = site_requirements.name
+	RETURNING requirement_id`
+	err = tx.QueryRow(stmt, strings.TrimSpace(name)).Scan(&requirementID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO product_site_requirements (product_id, requirement_id) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`, productID, requirementID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveProductRequirement removes a site requirement from a product.
+func (m *ProductRuleModel) RemoveProductRequirement(productID, requirementID int) error {
+	_, err := m.DB.Exec(`DELETE FROM product_site_requirements WHERE product_id = $1 AND requirement_id = $2`,
+		productID, requirementID)
+	return err
+}
+
+// GetConfirmedRequirements returns the site requirements confirmed for an estimate, keyed by RequirementID.
+func (m *ProductRuleModel) GetConfirmedRequirements(estimateID int) (map[int]bool, error) {
+	rows, err := m.DB.Query(`SELECT requirement_id FROM estimate_site_confirmations WHERE estimate_id = $1`, estimateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	confirmed := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		confirmed[id] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return confirmed, nil
+}
+
+// SetConfirmedRequirements replaces the site requirements confirmed for an estimate. Confirmations that are kept
+// keep who made them and when.
+func (m *ProductRuleModel) SetConfirmedRequirements(estimateID int, requirementIDs []int, userID int) error {
+	ids := make([]int64, len(requirementIDs))
+	for i, id := range requirementIDs {
+		ids[i] = int64(id)
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM estimate_site_confirmations WHERE estimate_id = $1 AND NOT requirement_id = ANY($2)`,
+		estimateID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO estimate_site_confirmations (estimate_id, requirement_id, confirmed_by)
+	SELECT $1, requirement_id, NULLIF($3, 0) FROM site_requirements WHERE requirement_id = ANY($2)
+	ON CONFLICT DO NOTHING`
+	_, err = tx.Exec(stmt, estimateID, pq.Array(ids), userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,200 @@
+// models/product_search.go searches the catalog by free text. Words are matched against the name and description
+// with Postgres full-text search, and against the name and color with trigram similarity so typos still match.
+// Dimension limits such as "max width 30in" are read out of the text and applied as filters.
+
+package models
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// searchLimit caps the results of a text search. Searches without text return every product that matches the filters.
+const searchLimit = 50
+
+// fuzzyThreshold is the lowest trigram word similarity that counts as a match for a search term. It is pg_trgm's
+// default word_similarity_threshold, which decides what the %> operator matches.
+const fuzzyThreshold = 0.6
+
+// ProductSearch describes a catalog search. Empty strings and zero dimensions are treated as wildcards.
+// Dimensions are in inches.
+type ProductSearch struct {
+	Text        string
+	Category    string
+	Subcategory string
+	Color       string
+	MaxLength   float32
+	MaxWidth    float32
+	MaxHeight   float32
+	MinLength   float32
+	MinWidth    float32
+	MinHeight   float32
+}
+
+// dimensionPattern matches a dimension limit written either way around, ex. "max width 30in" or "height <= 84".
+var dimensionPattern = regexp.MustCompile(`(?i)(?:\b(max(?:imum)?|under|below|at most|up to|min(?:imum)?|over|above|at least)\s+(length|width|height|long|wide|tall|high)|\b(length|width|height)\s*(max(?:imum)?|under|below|at most|up to|min(?:imum)?|over|above|at least|<=?|>=?))\s*(?:of\s+)?(\d+(?:\.\d+)?)\s*(?:inches|inch|in\b|")?`)
+
+// searchWord matches the words of a search that are looked up in the catalog.
+var searchWord = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// ParseProductSearch reads a search typed by a surveyor. Dimension limits are taken out of the text and the
+// remaining words are searched for, ex. "quiet dishwasher stainless max width 24in".
+func ParseProductSearch(text string) ProductSearch {
+	var s ProductSearch
+
+	text = dimensionPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := dimensionPattern.FindStringSubmatch(match)
+
+		op, dim := m[1], m[2]
+		if op == "" {
+			op, dim = m[4], m[3]
+		}
+
+		value, err := strconv.ParseFloat(m[5], 32)
+		if err != nil {
+			return match
+		}
+
+		op = strings.ToLower(op)
+		lower := strings.HasPrefix(op, "min") || strings.HasPrefix(op, ">") ||
+			op == "over" || op == "above" || op == "at least"
+
+		var limit *float32
+		switch strings.ToLower(dim) {
+		case "length", "long":
+			limit = &s.MaxLength
+			if lower {
+				limit = &s.MinLength
+			}
+		case "width", "wide":
+			limit = &s.MaxWidth
+			if lower {
+				limit = &s.MinWidth
+			}
+		default:
+			limit = &s.MaxHeight
+			if lower {
+				limit = &s.MinHeight
+			}
+		}
+		*limit = float32(value)
+
+		return " "
+	})
+
+	s.Text = strings.Join(strings.Fields(text), " ")
+	return s
+}
+
+// terms returns the distinct lowercase words of the search text.
+func (s ProductSearch) terms() []string {
+	var terms []string
+	for _, w := range searchWord.FindAllString(strings.ToLower(s.Text), -1) {
+		// "or" is an operator to websearch_to_tsquery.
+		if w == "or" || slices.Contains(terms, w) {
+			continue
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// searchMatchesStmt selects the active products matching a search along with how well they match. Its parameters
+// are the first twelve arguments returned by searchArgs. The fuzzy match uses the %> operator so it is served by
+// the trigram index on the name and color.
+const searchMatchesStmt = `SELECT products.*,
+		ts_rank(search_vector, q.query) AS text_rank,
+		(SELECT COALESCE(SUM(w.similarity), 0)
+		 FROM (SELECT word_similarity(t, name || ' ' || COALESCE(color, '')) AS similarity
+		       FROM unnest($2::text[]) AS t) w
+		 WHERE w.similarity >= $3::real) AS fuzzy_rank
+	FROM products, websearch_to_tsquery('english', $1) AS q(query)
+	WHERE discontinued_at IS NULL
+	AND (cardinality($2::text[]) = 0
+		OR search_vector @@ q.query
+		OR (name || ' ' || COALESCE(color, '')) %> ANY($2::text[]))
+	AND ($4='' OR category=$4)
+	AND ($5='' OR subcategory=$5)
+	AND ($6='' OR color=$6)
+	AND ($7::real=0 OR (length > 0 AND length <= $7))
+	AND ($8::real=0 OR (width > 0 AND width <= $8))
+	AND ($9::real=0 OR (height > 0 AND height <= $9))
+	AND length >= $10::real AND width >= $11::real AND height >= $12::real`
+
+// searchOrder puts the best matches first, then follows catalog order.
+const searchOrder = `ORDER BY text_rank + fuzzy_rank DESC, category, subcategory, name, product_id`
+
+// searchArgs returns the parameters of searchMatchesStmt for the search terms.
+func (s ProductSearch) searchArgs(terms []string) []any {
+	return []any{
+		strings.Join(terms, " or "),
+		pq.Array(terms),
+		fuzzyThreshold,
+		s.Category,
+		s.Subcategory,
+		s.Color,
+		s.MaxLength,
+		s.MaxWidth,
+		s.MaxHeight,
+		s.MinLength,
+		s.MinWidth,
+		s.MinHeight,
+	}
+}
+
+// Search returns the active products matching a search, best match first. A product matches when any word of the
+// text is found in its name or description, or closely resembles a word of its name or color. Without text, the
+// products matching the filters are returned in catalog order.
+func (m *ProductModel) Search(s ProductSearch) ([]Product, error) {
+	terms := s.terms()
+
+	limit := 0
+	if len(terms) > 0 {
+		limit = searchLimit
+	}
+
+	stmt := `SELECT ` + productColumns + ` FROM (` + searchMatchesStmt + `) matches
+	` + searchOrder + `
+	LIMIT NULLIF($13, 0)`
+
+	return m.queryProducts(stmt, append(s.searchArgs(terms), limit)...)
+}
+
+// queryProducts runs a query selecting productColumns.
+func (m *ProductModel) queryProducts(stmt string, args ...any) ([]Product, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		err := scanProduct(rows, &p)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// SearchFamilies returns the products matching a search grouped into families, best match first.
+func (m *ProductModel) SearchFamilies(s ProductSearch) ([]ProductFamily, error) {
+	products, err := m.Search(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return groupFamilies(m.DB, products)
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestParseProductSearch(t *testing.T) {
+	tests := []struct {
+		text string
+		want ProductSearch
+	}{
+		{"quiet dishwasher stainless", ProductSearch{Text: "quiet dishwasher stainless"}},
+		{"dishwasher max width 24in", ProductSearch{Text: "dishwasher", MaxWidth: 24}},
+		{"fridge height <= 70.5 stainless", ProductSearch{Text: "fridge stainless", MaxHeight: 70.5}},
+		{"range min width 36\"", ProductSearch{Text: "range", MinWidth: 36}},
+		{"Under Length 96 inches", ProductSearch{MaxLength: 96}},
+	}
+
+	for _, tt := range tests {
+		got := ParseProductSearch(tt.text)
+		if got != tt.want {
+			t.Errorf("ParseProductSearch(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
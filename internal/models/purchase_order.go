@@ -0,0 +1,454 @@
+// models/purchase_order.go turns the line items of a signed estimate into purchase orders, one per vendor.
+// Lines copy the product name, SKU and vendor cost at the time of ordering so a purchase order does not change
+// when the catalog does. A line item is only ever ordered once, so generating again only picks up new items.
+
+package models
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// PurchaseOrderStatus tracks a purchase order from creation until everything on it has arrived.
+type PurchaseOrderStatus string
+
+const (
+	POStatusDraft             PurchaseOrderStatus = "draft"
+	POStatusSent              PurchaseOrderStatus = "sent"
+	POStatusPartiallyReceived PurchaseOrderStatus = "partially_received"
+	POStatusReceived          PurchaseOrderStatus = "received"
+)
+
+// PurchaseOrderStatuses lists every status in the order a purchase order moves through them.
+var PurchaseOrderStatuses = []PurchaseOrderStatus{POStatusDraft, POStatusSent, POStatusPartiallyReceived, POStatusReceived}
+
+func (s PurchaseOrderStatus) String() string {
+	switch s {
+	case POStatusDraft:
+		return "Draft"
+	case POStatusSent:
+		return "Sent"
+	case POStatusPartiallyReceived:
+		return "Partially Received"
+	case POStatusReceived:
+		return "Received"
+	default:
+		return "Unknown"
+	}
+}
+
+// PurchaseOrder is an order placed with one vendor for the products of one estimate. Total is the cost of every
+// line in cents.
+type PurchaseOrder struct {
+	POID             int
+	EstimateID       int
+	CustomerName     string
+	VendorID         int
+	VendorName       string
+	VendorContact    string
+	VendorEmail      string
+	VendorPhone      string
+	Status           PurchaseOrderStatus
+	ExpectedDelivery sql.NullTime
+	CreatedBy        int
+	CreatedAt        time.Time
+	SentAt           sql.NullTime
+	Total            int
+	Lines            []PurchaseOrderLine
+}
+
+// PurchaseOrderLine is a product ordered on a purchase order. LineItemID is the estimate line item it fulfils, or
+// 0 if that line item was removed.
+type PurchaseOrderLine struct {
+	POLineID         int
+	POID             int
+	LineItemID       int
+	ProductID        int
+	Description      string
+	SKU              string
+	ModelNumber      string
+	Quantity         int
+	ReceivedQuantity int
+	UnitCost         int
+}
+
+// Total returns the cost of the line in cents.
+func (l PurchaseOrderLine) Total() int {
+	return l.UnitCost * l.Quantity
+}
+
+// Outstanding returns the quantity that has not arrived yet.
+func (l PurchaseOrderLine) Outstanding() int {
+	return l.Quantity - l.ReceivedQuantity
+}
+
+// PurchaseOrderModel wraps a sql.DB connection and provides methods for purchase orders.
+type PurchaseOrderModel struct {
+	DB *sql.DB
+}
+
+// purchaseOrderColumns is the select list read by scanPurchaseOrder.
+const purchaseOrderColumns = `po.po_id, po.estimate_id, COALESCE(c.name, ''), po.vendor_id, v.name, v.contact_name,
+	v.email, v.phone, po.status, po.expected_delivery, po.created_by, po.created_at, po.sent_at,
+	COALESCE((SELECT SUM(l.quantity * l.unit_cost) FROM purchase_order_lines l WHERE l.po_id = po.po_id), 0)`
+
+// purchaseOrderJoins joins the vendor and the customer of the estimate.
+const purchaseOrderJoins = `FROM purchase_orders po
+	JOIN vendors v ON v.vendor_id = po.vendor_id
+	JOIN estimates e ON e.estimate_id = po.estimate_id
+	LEFT JOIN users c ON c.user_id = e.customer_id`
+
+// scanPurchaseOrder scans a row selected with purchaseOrderColumns.
+func scanPurchaseOrder(row interface{ Scan(...any) error }, po *PurchaseOrder) error {
+	return row.Scan(&po.POID, &po.EstimateID, &po.CustomerName, &po.VendorID, &po.VendorName, &po.VendorContact,
+		&po.VendorEmail, &po.VendorPhone, &po.Status, &po.ExpectedDelivery, &po.CreatedBy, &po.CreatedAt, &po.SentAt,
+		&po.Total)
+}
+
+// GenerateForEstimate creates a draft purchase order per vendor for the catalog line items of a signed estimate
+// that are not on a purchase order yet. The expected delivery is set from the longest lead time on each order.
+// It returns the new purchase order IDs and the number of line items skipped because their product has no vendor.
+// Returns ErrNoRecord if the estimate does not exist and ErrEstimateNotSigned if it has not been signed.
+func (m *PurchaseOrderModel) GenerateForEstimate(estimateID, userID int) ([]int, int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	poIDs, skipped, err := m.GenerateForEstimateTx(tx, estimateID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return poIDs, skipped, nil
+}
+
+// GenerateForEstimateTx is GenerateForEstimate inside a transaction. It is run in the transaction that records the
+// customer's signature, so a signed estimate is ordered as soon as it is signed.
+func (m *PurchaseOrderModel) GenerateForEstimateTx(tx *sql.Tx, estimateID, userID int) ([]int, int, error) {
+	var status EstimateStatus
+	err := tx.QueryRow(`SELECT status FROM estimates WHERE estimate_id=$1 FOR UPDATE`, estimateID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, ErrNoRecord
+		}
+		return nil, 0, err
+	}
+	if status < StatusInProgress {
+		return nil, 0, ErrEstimateNotSigned
+	}
+
+	// Bundles are ordered as their components.
+	stmt := `SELECT ei.line_item_id, p.product_id, ei.quantity * COALESCE(bc.quantity, 1), p.name, COALESCE(p.sku, ''),
+	p.model_number, COALESCE(p.vendor_id, 0), p.vendor_cost, p.lead_time_days
+	FROM estimate_items ei
+	` + lineItemComponents + `
+	JOIN products p ON p.product_id = COALESCE(bc.component_id, ei.product_id)
+	WHERE ei.estimate_id=$1 AND NOT ei.is_optional AND ei.option_id IS NULL
+	AND NOT EXISTS (SELECT 1 FROM purchase_order_lines l
+		WHERE l.line_item_id = ei.line_item_id AND l.product_id = p.product_id)
+	ORDER BY p.vendor_id, ei.line_item_id, p.product_id`
+
+	rows, err := tx.Query(stmt, estimateID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		vendorIDs []int
+		lines     = make(map[int][]PurchaseOrderLine)
+		leadTimes = make(map[int]int)
+		skipped   int
+	)
+	for rows.Next() {
+		var (
+			l                  PurchaseOrderLine
+			vendorID, leadTime int
+		)
+		err := rows.Scan(&l.LineItemID, &l.ProductID, &l.Quantity, &l.Description, &l.SKU, &l.ModelNumber,
+			&vendorID, &l.UnitCost, &leadTime)
+		if err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+
+		if vendorID == 0 {
+			skipped++
+			continue
+		}
+		if _, ok := lines[vendorID]; !ok {
+			vendorIDs = append(vendorIDs, vendorID)
+		}
+		lines[vendorID] = append(lines[vendorID], l)
+		leadTimes[vendorID] = max(leadTimes[vendorID], leadTime)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var poIDs []int
+	for _, vendorID := range vendorIDs {
+		var poID int
+		err := tx.QueryRow(`INSERT INTO purchase_orders (estimate_id, vendor_id, expected_delivery, created_by)
+		VALUES ($1, $2, CASE WHEN $3 > 0 THEN CURRENT_DATE + $3::int END, $4)
+		RETURNING po_id`, estimateID, vendorID, leadTimes[vendorID], userID).Scan(&poID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, l := range lines[vendorID] {
+			_, err := tx.Exec(`INSERT INTO purchase_order_lines
+			(po_id, line_item_id, product_id, description, sku, model_number, quantity, unit_cost)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				poID, l.LineItemID, l.ProductID, l.Description, l.SKU, l.ModelNumber, l.Quantity, l.UnitCost)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		poIDs = append(poIDs, poID)
+	}
+
+	return poIDs, skipped, nil
+}
+
+// Get retrieves a purchase order and its lines. Returns ErrNoRecord if the purchase order does not exist.
+func (m *PurchaseOrderModel) Get(id int) (PurchaseOrder, error) {
+	stmt := `SELECT ` + purchaseOrderColumns + ` ` + purchaseOrderJoins + ` WHERE po.po_id=$1`
+
+	var po PurchaseOrder
+	err := scanPurchaseOrder(m.DB.QueryRow(stmt, id), &po)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return PurchaseOrder{}, ErrNoRecord
+		}
+		return PurchaseOrder{}, err
+	}
+
+	rows, err := m.DB.Query(`SELECT po_line_id, po_id, COALESCE(line_item_id, 0), COALESCE(product_id, 0),
+	description, sku, model_number, quantity, received_quantity, unit_cost
+	FROM purchase_order_lines
+	WHERE po_id=$1
+	ORDER BY po_line_id`, id)
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l PurchaseOrderLine
+		err := rows.Scan(&l.POLineID, &l.POID, &l.LineItemID, &l.ProductID, &l.Description, &l.SKU,
+			&l.ModelNumber, &l.Quantity, &l.ReceivedQuantity, &l.UnitCost)
+		if err != nil {
+			return PurchaseOrder{}, err
+		}
+		po.Lines = append(po.Lines, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return PurchaseOrder{}, err
+	}
+
+	return po, nil
+}
+
+// List returns every purchase order with the given status, newest first. An empty status returns all of them.
+// Lines are not loaded.
+func (m *PurchaseOrderModel) List(status PurchaseOrderStatus) ([]PurchaseOrder, error) {
+	stmt := `SELECT ` + purchaseOrderColumns + ` ` + purchaseOrderJoins + `
+	WHERE ($1='' OR po.status=$1)
+	ORDER BY po.created_at DESC, po.po_id DESC`
+
+	return m.query(stmt, status)
+}
+
+// GetByEstimateID returns the purchase orders placed for an estimate, oldest first. Lines are not loaded.
+func (m *PurchaseOrderModel) GetByEstimateID(estimateID int) ([]PurchaseOrder, error) {
+	stmt := `SELECT ` + purchaseOrderColumns + ` ` + purchaseOrderJoins + `
+	WHERE po.estimate_id=$1
+	ORDER BY po.po_id`
+
+	return m.query(stmt, estimateID)
+}
+
+func (m *PurchaseOrderModel) query(stmt string, args ...any) ([]PurchaseOrder, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []PurchaseOrder
+	for rows.Next() {
+		var po PurchaseOrder
+		if err := scanPurchaseOrder(rows, &po); err != nil {
+			return nil, err
+		}
+		orders = append(orders, po)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// MarkSent records that a draft purchase order was sent to its vendor. Returns ErrNoRecord if the purchase order
+// does not exist and ErrPurchaseOrderStatus if it is not a draft.
+func (m *PurchaseOrderModel) MarkSent(id int) error {
+	result, err := m.DB.Exec(`UPDATE purchase_orders SET status='sent', sent_at=NOW()
+	WHERE po_id=$1 AND status='draft'`, id)
+	if err != nil {
+		return err
+	}
+
+	return m.checkTransition(result, id)
+}
+
+// SetExpectedDelivery changes when a purchase order is expected to arrive. An invalid date clears it.
+// Returns ErrNoRecord if the purchase order does not exist.
+func (m *PurchaseOrderModel) SetExpectedDelivery(id int, expected sql.NullTime) error {
+	result, err := m.DB.Exec(`UPDATE purchase_orders SET expected_delivery=$2 WHERE po_id=$1`, id, expected)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Receive records quantities that arrived, keyed by POLineID. Quantities are added to what was received before
+// and capped at the quantity ordered. The purchase order becomes received once every line has fully arrived, and
+// partially received before that. Returns ErrNoRecord if the purchase order does not exist and
+// ErrPurchaseOrderStatus if it has not been sent.
+func (m *PurchaseOrderModel) Receive(id int, received map[int]int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status PurchaseOrderStatus
+	err = tx.QueryRow(`SELECT status FROM purchase_orders WHERE po_id=$1 FOR UPDATE`, id).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+	if status == POStatusDraft {
+		return ErrPurchaseOrderStatus
+	}
+
+	for lineID, quantity := range received {
+		if quantity <= 0 {
+			continue
+		}
+
+		_, err := tx.Exec(`UPDATE purchase_order_lines
+		SET received_quantity = LEAST(quantity, received_quantity + $3)
+		WHERE po_line_id=$1 AND po_id=$2`, lineID, id, quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE purchase_orders po SET status = CASE
+		WHEN NOT EXISTS (SELECT 1 FROM purchase_order_lines l WHERE l.po_id = po.po_id AND l.received_quantity < l.quantity)
+			THEN 'received'
+		WHEN EXISTS (SELECT 1 FROM purchase_order_lines l WHERE l.po_id = po.po_id AND l.received_quantity > 0)
+			THEN 'partially_received'
+		ELSE po.status END
+	WHERE po.po_id=$1`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkTransition tells a purchase order that is missing apart from one in the wrong status after an update
+// that changed no rows.
+func (m *PurchaseOrderModel) checkTransition(result sql.Result, id int) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = m.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM purchase_orders WHERE po_id=$1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+	return ErrPurchaseOrderStatus
+}
+
+// PurchaseOrderCSVFields is the header row of an exported purchase order.
+var PurchaseOrderCSVFields = []string{
+	"po_id", "estimate_id", "vendor", "sku", "model_number", "description", "quantity", "received_quantity",
+	"unit_cost", "line_total", "expected_delivery",
+}
+
+// WriteCSV writes the lines of a purchase order to w, one row per line, with a header row of
+// PurchaseOrderCSVFields. Costs are written in dollars.
+func (po PurchaseOrder) WriteCSV(w io.Writer) error {
+	expected := ""
+	if po.ExpectedDelivery.Valid {
+		expected = po.ExpectedDelivery.Time.Format("2006-01-02")
+	}
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(PurchaseOrderCSVFields)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range po.Lines {
+		err := cw.Write([]string{
+			strconv.Itoa(po.POID),
+			strconv.Itoa(po.EstimateID),
+			po.VendorName,
+			l.SKU,
+			l.ModelNumber,
+			l.Description,
+			strconv.Itoa(l.Quantity),
+			strconv.Itoa(l.ReceivedQuantity),
+			fmt.Sprintf("%.2f", float64(l.UnitCost)/100),
+			fmt.Sprintf("%.2f", float64(l.Total())/100),
+			expected,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
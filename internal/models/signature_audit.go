@@ -0,0 +1,106 @@
+// models/signature_audit.go keeps the evidence behind each electronic signature: who signed, from which address and
+// browser, when the signing link was sent, opened and signed, the consent statement they agreed to and the SHA-256
+// hash of the agreement they signed. The certificate of completion is generated from it.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SignatureAudit is the audit record of an estimate's signature.
+type SignatureAudit struct {
+	AuditID        int
+	EstimateID     int
+	InvoiceTokenID int
+	SignerName     string
+	SignerEmail    string
+	IPAddress      string
+	UserAgent      string
+	LinkSentAt     time.Time
+	LinkOpenedAt   sql.NullTime
+	SignedAt       time.Time
+	ConsentVersion string
+	ConsentText    string
+	// AgreementSHA256 is the hex encoded hash of the PDF agreement, set once as the agreement is signed.
+	AgreementSHA256      sql.NullString
+	CertificateObjectKey sql.NullString
+}
+
+type SignatureAuditModel struct {
+	DB *sql.DB
+}
+
+// InsertTx records the audit of a signature in the transaction that records the signature itself, so both share
+// the same signing time.
+func (m *SignatureAuditModel) InsertTx(tx *sql.Tx, a *SignatureAudit) error {
+	stmt := `INSERT INTO signature_audits (estimate_id, invoice_token_id, signer_name, signer_email, ip_address,
+		user_agent, link_sent_at, link_opened_at, consent_version, consent_text)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING audit_id, signed_at`
+
+	return tx.QueryRow(stmt, a.EstimateID, a.InvoiceTokenID, a.SignerName, a.SignerEmail, a.IPAddress, a.UserAgent,
+		a.LinkSentAt, a.LinkOpenedAt, a.ConsentVersion, a.ConsentText).Scan(&a.AuditID, &a.SignedAt)
+}
+
+// GetByEstimateID returns the audit record of an estimate's signature. Returns ErrNoRecord if the estimate was not
+// signed, or was signed before signatures were audited.
+func (m *SignatureAuditModel) GetByEstimateID(estimateID int) (SignatureAudit, error) {
+	stmt := `SELECT audit_id, estimate_id, COALESCE(invoice_token_id, 0), signer_name, signer_email, ip_address,
+		user_agent, link_sent_at, link_opened_at, signed_at, consent_version, consent_text, agreement_sha256,
+		certificate_object_key
+	FROM signature_audits WHERE estimate_id = $1`
+
+	var a SignatureAudit
+	err := m.DB.QueryRow(stmt, estimateID).Scan(&a.AuditID, &a.EstimateID, &a.InvoiceTokenID, &a.SignerName,
+		&a.SignerEmail, &a.IPAddress, &a.UserAgent, &a.LinkSentAt, &a.LinkOpenedAt, &a.SignedAt, &a.ConsentVersion,
+		&a.ConsentText, &a.AgreementSHA256, &a.CertificateObjectKey)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SignatureAudit{}, ErrNoRecord
+		}
+		return SignatureAudit{}, err
+	}
+
+	return a, nil
+}
+
+// SetAgreementHashTx records the hash of the agreement generated for a signed estimate, in the transaction that
+// records the signature. The hash can only be set once: returns ErrAgreementRecorded if the audit already has one
+// and ErrNoRecord if the estimate has no audit.
+func (m *SignatureAuditModel) SetAgreementHashTx(tx *sql.Tx, estimateID int, hash string) error {
+	stmt := `UPDATE signature_audits SET agreement_sha256 = $1 WHERE estimate_id = $2 AND agreement_sha256 IS NULL`
+
+	result, err := tx.Exec(stmt, hash, estimateID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM signature_audits WHERE estimate_id = $1)`, estimateID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrAgreementRecorded
+	}
+	return ErrNoRecord
+}
+
+// SetCertificateKeyTx records where the certificate of completion of a signed estimate is stored.
+func (m *SignatureAuditModel) SetCertificateKeyTx(tx *sql.Tx, estimateID int, key string) error {
+	stmt := `UPDATE signature_audits SET certificate_object_key = $1 WHERE estimate_id = $2`
+
+	_, err := tx.Exec(stmt, key, estimateID)
+	return err
+}
@@ -0,0 +1,110 @@
+// models/vendor.go stores the suppliers products are bought from.
+
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Vendor is a supplier of catalog products.
+type Vendor struct {
+	VendorID    int
+	Name        string
+	ContactName string
+	Email       string
+	Phone       string
+	CreatedAt   time.Time
+}
+
+// VendorModel wraps a sql.DB connection and provides methods for vendors.
+type VendorModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new vendor and assigns the generated VendorID to the struct.
+// Returns ErrDuplicateVendor if a vendor with the same name exists.
+func (m *VendorModel) Insert(v *Vendor) error {
+	stmt := `INSERT INTO vendors (name, contact_name, email, phone)
+	VALUES ($1, $2, $3, $4)
+	RETURNING vendor_id, created_at`
+
+	err := m.DB.QueryRow(stmt, v.Name, v.ContactName, v.Email, v.Phone).Scan(&v.VendorID, &v.CreatedAt)
+	return vendorError(err)
+}
+
+// Get retrieves a vendor by its VendorID. Returns ErrNoRecord if the vendor does not exist.
+func (m *VendorModel) Get(id int) (Vendor, error) {
+	stmt := `SELECT vendor_id, name, contact_name, email, phone, created_at FROM vendors WHERE vendor_id=$1`
+
+	var v Vendor
+	err := m.DB.QueryRow(stmt, id).Scan(&v.VendorID, &v.Name, &v.ContactName, &v.Email, &v.Phone, &v.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Vendor{}, ErrNoRecord
+		}
+		return Vendor{}, err
+	}
+
+	return v, nil
+}
+
+// GetAll returns every vendor ordered by name.
+func (m *VendorModel) GetAll() ([]Vendor, error) {
+	stmt := `SELECT vendor_id, name, contact_name, email, phone, created_at FROM vendors ORDER BY name, vendor_id`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vendors []Vendor
+	for rows.Next() {
+		var v Vendor
+		err := rows.Scan(&v.VendorID, &v.Name, &v.ContactName, &v.Email, &v.Phone, &v.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		vendors = append(vendors, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return vendors, nil
+}
+
+// Update saves a vendor. Returns ErrNoRecord if the vendor does not exist and ErrDuplicateVendor if another
+// vendor has the same name.
+func (m *VendorModel) Update(v *Vendor) error {
+	stmt := `UPDATE vendors SET name=$2, contact_name=$3, email=$4, phone=$5 WHERE vendor_id=$1`
+
+	result, err := m.DB.Exec(stmt, v.VendorID, v.Name, v.ContactName, v.Email, v.Phone)
+	if err != nil {
+		return vendorError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// vendorError turns a unique violation on the vendor name into ErrDuplicateVendor.
+func vendorError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "vendors_name_key" {
+		return ErrDuplicateVendor
+	}
+	return err
+}
@@ -0,0 +1,342 @@
+// Package pdf writes simple printable documents: US letter pages of Helvetica text, rules and images laid out from
+// the top of the page down. It covers the documents the app hands to vendors and customers without pulling in a
+// layout engine. Text is encoded as WinAnsi, so characters outside Latin-1 (and a few typographic marks) print as "?".
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// Page size and margins in points (1/72 inch).
+const (
+	PageWidth  = 612.0
+	PageHeight = 792.0
+	Margin     = 54.0
+)
+
+// ContentWidth is the usable width between the left and right margins.
+const ContentWidth = PageWidth - 2*Margin
+
+// lineSpacing is the distance between lines as a multiple of the font size.
+const lineSpacing = 1.35
+
+// Font is one of the standard fonts every PDF reader provides.
+type Font int
+
+const (
+	Regular Font = iota
+	Bold
+)
+
+// resource names the font in page content streams.
+func (f Font) resource() string {
+	if f == Bold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// Column is a cell of a Row. X is measured from the left margin. Right aligns the text to X+Width instead.
+type Column struct {
+	X     float64
+	Width float64
+	Text  string
+	Right bool
+}
+
+// Document is a PDF being laid out. The cursor starts at the top of the first page and moves down as content is
+// added; a new page is started when content would run into the bottom margin.
+type Document struct {
+	title  string
+	pages  []*bytes.Buffer
+	images []picture
+	y      float64
+}
+
+// picture is an image added to the document, stored as Flate compressed RGB samples and an alpha mask.
+type picture struct {
+	width, height int
+	rgb, alpha    []byte
+}
+
+// New returns a document with a single empty page.
+func New(title string) *Document {
+	d := &Document{title: title}
+	d.AddPage()
+	return d
+}
+
+// AddPage starts a new page and moves the cursor to its top.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = PageHeight - Margin
+}
+
+// Pages returns the number of pages so far.
+func (d *Document) Pages() int {
+	return len(d.pages)
+}
+
+// Space moves the cursor down by h points.
+func (d *Document) Space(h float64) {
+	d.ensure(h)
+	d.y -= h
+}
+
+// Text writes a single line at the cursor and moves below it. Text that does not fit is cut off at the margin;
+// use Paragraph for text that should wrap.
+func (d *Document) Text(size float64, font Font, s string) {
+	d.Row(size, font, Column{Width: ContentWidth, Text: s})
+}
+
+// Paragraph writes text wrapped to the content width.
+func (d *Document) Paragraph(size float64, font Font, s string) {
+	for _, line := range Wrap(s, size, font, ContentWidth) {
+		d.Text(size, font, line)
+	}
+}
+
+// Row writes a line of columns at the cursor and moves below it. Text longer than its column is shortened.
+func (d *Document) Row(size float64, font Font, cols ...Column) {
+	h := size * lineSpacing
+	d.ensure(h)
+	d.y -= size
+
+	page := d.pages[len(d.pages)-1]
+	for _, c := range cols {
+		text := fit(c.Text, size, font, c.Width)
+		x := Margin + c.X
+		if c.Right {
+			x += c.Width - TextWidth(text, size, font)
+		}
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font.resource(), size, x, d.y, escape(text))
+	}
+
+	d.y -= h - size
+}
+
+// Rule draws a horizontal line across the content width at the cursor.
+func (d *Document) Rule() {
+	d.ensure(6)
+	d.y -= 3
+	fmt.Fprintf(d.pages[len(d.pages)-1], "0.5 w %.2f %.2f m %.2f %.2f l S\n", Margin, d.y, PageWidth-Margin, d.y)
+	d.y -= 3
+}
+
+// Image draws an image at the cursor, scaled to width points with its aspect ratio kept, and moves below it.
+// Transparent pixels let the page show through, so a signature drawn on a transparent canvas prints as ink only.
+func (d *Document) Image(img image.Image, width float64) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return
+	}
+	height := width * float64(bounds.Dy()) / float64(bounds.Dx())
+
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+		}
+	}
+	d.images = append(d.images, picture{width: bounds.Dx(), height: bounds.Dy(), rgb: deflate(rgb), alpha: deflate(alpha)})
+
+	d.ensure(height)
+	d.y -= height
+	fmt.Fprintf(d.pages[len(d.pages)-1], "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", width, height, Margin, d.y, len(d.images))
+}
+
+// deflate compresses image samples for a FlateDecode stream.
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// ensure starts a new page when h points of content would not fit above the bottom margin.
+func (d *Document) ensure(h float64) {
+	if d.y-h < Margin {
+		d.AddPage()
+	}
+}
+
+// WriteTo writes the finished document.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-5 are the catalog, the page tree, the two fonts and the info dictionary. Each page is followed
+	// by its content stream, and the images come last, each followed by its alpha mask.
+	const firstPage = 6
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	firstImage := firstPage + 2*len(d.pages)
+	var xobjects strings.Builder
+	for i := range d.images {
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, firstImage+2*i)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (EzKitchen) >>", escape(d.title)))
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s>> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, xobjects.String(), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	for i, img := range d.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB "+
+			"/BitsPerComponent 8 /Filter /FlateDecode /SMask %d 0 R /Length %d >>\nstream\n%s\nendstream",
+			img.width, img.height, firstImage+2*i+1, len(img.rgb), img.rgb))
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray "+
+			"/BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			img.width, img.height, len(img.alpha), img.alpha))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return buf.WriteTo(w)
+}
+
+// Wrap splits text into lines no wider than width. Existing line breaks are kept.
+func Wrap(s string, size float64, font Font, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && TextWidth(candidate, size, font) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// TextWidth returns the width of text in points.
+func TextWidth(s string, size float64, font Font) float64 {
+	widths := &helvetica
+	if font == Bold {
+		widths = &helveticaBold
+	}
+
+	total := 0
+	for _, b := range encode(s) {
+		if b >= 32 && b <= 126 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// fit shortens text with an ellipsis until it fits width.
+func fit(s string, size float64, font Font, width float64) string {
+	if width <= 0 || TextWidth(s, size, font) <= width {
+		return s
+	}
+
+	runes := []rune(s)
+	for len(runes) > 0 && TextWidth(string(runes)+"...", size, font) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// winAnsi maps the typographic characters users commonly paste to their WinAnsi codes.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encode converts text to WinAnsi bytes.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			out = append(out, byte(r))
+		case winAnsi[r] != 0:
+			out = append(out, winAnsi[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+// escape encodes text as the body of a PDF string literal. Bytes outside ASCII are written as octal escapes.
+func escape(s string) string {
+	var b strings.Builder
+	for _, c := range encode(s) {
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n', '\r', '\t':
+			b.WriteByte(' ')
+		default:
+			if c >= 0x80 {
+				fmt.Fprintf(&b, "\\%03o", c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Character widths of the printable ASCII range (space through tilde) in thousandths of the font size.
+var helvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBold = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	d := New("PO #1 (draft)")
+	d.Text(16, Bold, "Purchase Order")
+	d.Rule()
+	for i := range 80 {
+		d.Row(10, Regular,
+			Column{Width: 300, Text: fmt.Sprintf("Line %d – 36\" range (stainless)", i)},
+			Column{X: 400, Width: 104, Text: "$1,299.00", Right: true},
+		)
+	}
+
+	if d.Pages() < 2 {
+		t.Fatalf("expected 80 rows to overflow onto a second page, got %d page(s)", d.Pages())
+	}
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "%PDF-1.4") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("missing PDF header or trailer")
+	}
+	if !strings.Contains(out, `(Line 0 \226 36" range \(stainless\)) Tj`) {
+		t.Errorf("expected escaped WinAnsi text in the content stream")
+	}
+	if !strings.Contains(out, fmt.Sprintf("/Count %d", d.Pages())) {
+		t.Errorf("page tree does not count every page")
+	}
+
+	// Every xref entry must point at the start of its object.
+	start, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(out[start:], -1)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(e[1])
+		if want := fmt.Sprintf("%d 0 obj", i+1); !strings.HasPrefix(out[off:], want) {
+			t.Errorf("xref entry %d points at %q", i+1, out[off:off+10])
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 10))
+	img.Set(5, 5, color.Black)
+
+	d := New("Agreement")
+	d.Text(12, Regular, "Signed")
+	d.Image(img, 200)
+	d.Image(image.NewNRGBA(image.Rectangle{}), 200)
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, "q 200.00 0 0 50.00 ") || !strings.Contains(out, "/Im1 Do Q") {
+		t.Errorf("expected the image to be drawn at 200x50pt")
+	}
+	if strings.Contains(out, "/Im2") {
+		t.Errorf("expected an empty image to be skipped")
+	}
+	if !strings.Contains(out, "/XObject << /Im1 8 0 R >>") || !strings.Contains(out, "/SMask 9 0 R") {
+		t.Errorf("expected the image and its alpha mask after the page")
+	}
+	if !strings.Contains(out, "/Width 40 /Height 10 /ColorSpace /DeviceGray") {
+		t.Errorf("expected a grayscale alpha mask")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := Wrap("The customer agrees to the work described above.\nSigned", 10, Regular, 120)
+
+	if len(lines) < 3 || lines[len(lines)-1] != "Signed" {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	for _, line := range lines {
+		if TextWidth(line, 10, Regular) > 120 {
+			t.Errorf("line %q is wider than 120pt", line)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	if got := fit("short", 10, Regular, 100); got != "short" {
+		t.Errorf("expected text that fits to be unchanged, got %q", got)
+	}
+	if got := fit(strings.Repeat("W", 50), 10, Bold, 60); !strings.HasSuffix(got, "...") || TextWidth(got, 10, Bold) > 60 {
+		t.Errorf("expected long text to be shortened to fit, got %q", got)
+	}
+}
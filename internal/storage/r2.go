@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -45,6 +46,91 @@ func (r *R2Storage) UploadSignature(ctx context.Context, estimateID int, body io
 
 }
 
+// AgreementKey returns the object key of the PDF agreement of a signed estimate.
+func AgreementKey(estimateID int) string {
+	return fmt.Sprintf("agreements/%d.pdf", estimateID)
+}
+
+// UploadAgreement stores the PDF agreement of a signed estimate, replacing any earlier copy.
+func (r *R2Storage) UploadAgreement(ctx context.Context, estimateID int, body io.Reader) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(AgreementKey(estimateID)),
+		Body:        body,
+		ContentType: aws.String("application/pdf"),
+	})
+
+	return err
+}
+
+// CertificateKey returns the object key of the certificate of completion of a signed estimate.
+func CertificateKey(estimateID int) string {
+	return fmt.Sprintf("certificates/%d.pdf", estimateID)
+}
+
+// UploadCertificate stores the certificate of completion of a signed estimate, replacing any earlier copy.
+func (r *R2Storage) UploadCertificate(ctx context.Context, estimateID int, body io.Reader) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(CertificateKey(estimateID)),
+		Body:        body,
+		ContentType: aws.String("application/pdf"),
+	})
+
+	return err
+}
+
+// ProductImageKey returns a new storage prefix for a photo of the product. Each upload gets its own prefix so
+// browsers and caches never see a replaced photo under an old URL.
+func ProductImageKey(productID int) string {
+	return fmt.Sprintf("products/%d/%d", productID, time.Now().UnixNano())
+}
+
+// ProductImageVariantKey returns the object key of one resized variant of a product photo.
+func ProductImageVariantKey(prefix, variant string) string {
+	return fmt.Sprintf("%s-%s.jpg", prefix, variant)
+}
+
+// UploadProductImage stores one resized variant of a product photo.
+func (r *R2Storage) UploadProductImage(ctx context.Context, prefix, variant string, body io.Reader) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(r.bucket),
+		Key:          aws.String(ProductImageVariantKey(prefix, variant)),
+		Body:         body,
+		ContentType:  aws.String("image/jpeg"),
+		CacheControl: aws.String("public, max-age=31536000, immutable"),
+	})
+
+	return err
+}
+
+// ProductImportKey returns a new object key for a product import file uploaded by the user, kept between the
+// preview and the commit of the import.
+func ProductImportKey(userID int) string {
+	return fmt.Sprintf("imports/%d/%d.csv", userID, time.Now().UnixNano())
+}
+
+// UploadProductImport stores a product import file under a key from ProductImportKey.
+func (r *R2Storage) UploadProductImport(ctx context.Context, key string, body io.Reader) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(r.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String("text/csv"),
+	})
+
+	return err
+}
+
+func (r *R2Storage) Delete(ctx context.Context, key string) error {
+	_, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
 func (r *R2Storage) Get(ctx context.Context, key string) (*Object, error) {
 
 	out, err := r.client.GetObject(ctx, &s3.GetObjectInput{
@@ -2,6 +2,7 @@ package validator
 
 import (
 	"net/mail"
+	"slices"
 	"strings"
 	"unicode/utf8"
 )
@@ -95,3 +96,8 @@ func LessThanN(value any, min any) bool {
 	}
 	return false
 }
+
+// PermittedValue returns true if the value is one of the permitted values.
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	return slices.Contains(permittedValues, value)
+}
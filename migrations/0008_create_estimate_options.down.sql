@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS estimate_option_selections;
+ALTER TABLE estimate_items DROP COLUMN IF EXISTS option_id;
+DROP TABLE IF EXISTS estimate_options;
+DROP TABLE IF EXISTS estimate_option_groups;
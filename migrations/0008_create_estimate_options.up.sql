@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS estimate_option_groups (
+    group_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS estimate_options (
+    option_id SERIAL PRIMARY KEY,
+    group_id INT NOT NULL REFERENCES estimate_option_groups(group_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    sort_order INT NOT NULL DEFAULT 0
+);
+
+ALTER TABLE estimate_items
+    ADD COLUMN option_id INT REFERENCES estimate_options(option_id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS estimate_option_selections (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    group_id INT NOT NULL REFERENCES estimate_option_groups(group_id) ON DELETE CASCADE,
+    option_id INT NOT NULL REFERENCES estimate_options(option_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id),
+    selected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, group_id)
+);
+
+CREATE INDEX estimate_option_groups_estimate_id_idx
+    ON estimate_option_groups (estimate_id);
+
+CREATE INDEX estimate_items_option_id_idx
+    ON estimate_items (option_id);
@@ -0,0 +1 @@
+ALTER TABLE estimate_items DROP COLUMN IF EXISTS is_optional;
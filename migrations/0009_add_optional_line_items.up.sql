@@ -0,0 +1,2 @@
+ALTER TABLE estimate_items
+    ADD COLUMN is_optional BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+DELETE FROM estimate_items WHERE product_id IS NULL;
+
+ALTER TABLE estimate_items
+    DROP CONSTRAINT IF EXISTS estimate_items_product_or_custom_chk,
+    DROP COLUMN IF EXISTS taxable,
+    DROP COLUMN IF EXISTS custom_category,
+    DROP COLUMN IF EXISTS custom_unit_price,
+    DROP COLUMN IF EXISTS custom_description,
+    ALTER COLUMN product_id SET NOT NULL;
@@ -0,0 +1,10 @@
+ALTER TABLE estimate_items
+    ALTER COLUMN product_id DROP NOT NULL,
+    ADD COLUMN custom_description VARCHAR(255),
+    ADD COLUMN custom_unit_price INT,
+    ADD COLUMN custom_category VARCHAR(50),
+    ADD COLUMN taxable BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD CONSTRAINT estimate_items_product_or_custom_chk CHECK (
+        product_id IS NOT NULL
+        OR (custom_description IS NOT NULL AND custom_unit_price IS NOT NULL)
+    );
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS estimate_shares (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    shared_by INT NOT NULL REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_estimate_shares_user_id ON estimate_shares(user_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS estimate_openings (
+    opening_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('door', 'hallway', 'stairs')),
+    width_inch REAL NOT NULL CHECK (width_inch > 0),
+    height_inch REAL NOT NULL CHECK (height_inch > 0),
+    turn_width_inch REAL NOT NULL DEFAULT 0 CHECK (turn_width_inch >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_estimate_openings_estimate_id ON estimate_openings(estimate_id, position);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS product_audit (
+    audit_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'delete')),
+    changed_by INT NOT NULL REFERENCES users(user_id),
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    changes JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_audit_product_id ON product_audit(product_id, changed_at);
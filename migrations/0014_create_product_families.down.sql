@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_products_family_id;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS size,
+    DROP COLUMN IF EXISTS finish,
+    DROP COLUMN IF EXISTS family_id;
+
+DROP TABLE IF EXISTS product_families;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS product_families (
+    family_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    category VARCHAR(50) NOT NULL,
+    subcategory VARCHAR(50) NOT NULL,
+    length REAL NOT NULL DEFAULT 0,
+    width REAL NOT NULL DEFAULT 0,
+    height REAL NOT NULL DEFAULT 0
+);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS family_id INT REFERENCES product_families(family_id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS finish VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS size VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_products_family_id ON products(family_id);
+
+-- Products that share everything but their color and price become variants of one family.
+INSERT INTO product_families (name, description, category, subcategory, length, width, height)
+SELECT name, COALESCE(description, ''), category, subcategory,
+       COALESCE(length, 0), COALESCE(width, 0), COALESCE(height, 0)
+FROM products
+GROUP BY name, COALESCE(description, ''), category, subcategory,
+         COALESCE(length, 0), COALESCE(width, 0), COALESCE(height, 0)
+HAVING COUNT(*) > 1;
+
+UPDATE products p
+SET family_id = f.family_id
+FROM product_families f
+WHERE p.family_id IS NULL
+  AND p.name = f.name
+  AND COALESCE(p.description, '') = f.description
+  AND p.category = f.category
+  AND p.subcategory = f.subcategory
+  AND COALESCE(p.length, 0) = f.length
+  AND COALESCE(p.width, 0) = f.width
+  AND COALESCE(p.height, 0) = f.height;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_products_active;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS replaced_by,
+    DROP COLUMN IF EXISTS discontinued_at;
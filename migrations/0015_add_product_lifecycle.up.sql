@@ -0,0 +1,5 @@
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS discontinued_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS replaced_by INT REFERENCES products(product_id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_products_active ON products(category, subcategory) WHERE discontinued_at IS NULL;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_products_vendor_id;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS lead_time_days,
+    DROP COLUMN IF EXISTS vendor_cost,
+    DROP COLUMN IF EXISTS vendor_id,
+    DROP COLUMN IF EXISTS model_number,
+    DROP COLUMN IF EXISTS sku;
+
+DROP TABLE IF EXISTS vendors;
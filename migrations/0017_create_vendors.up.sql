@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS vendors (
+    vendor_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    contact_name VARCHAR(100) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(50) UNIQUE,
+    ADD COLUMN IF NOT EXISTS model_number VARCHAR(50) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS vendor_id INT REFERENCES vendors(vendor_id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS vendor_cost INT NOT NULL DEFAULT 0 CHECK (vendor_cost >= 0),
+    ADD COLUMN IF NOT EXISTS lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0);
+
+CREATE INDEX IF NOT EXISTS idx_products_vendor_id ON products(vendor_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS inventory_adjustments;
+DROP TABLE IF EXISTS inventory_reservations;
+DROP TABLE IF EXISTS inventory_stock;
+DROP TABLE IF EXISTS inventory_locations;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS inventory_locations (
+    location_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO inventory_locations (name) VALUES ('Main Warehouse') ON CONFLICT (name) DO NOTHING;
+
+-- A product is tracked once it has a stock row at any location.
+CREATE TABLE IF NOT EXISTS inventory_stock (
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id) ON DELETE CASCADE,
+    on_hand INT NOT NULL DEFAULT 0,
+    reorder_point INT NOT NULL DEFAULT 0 CHECK (reorder_point >= 0),
+    PRIMARY KEY (product_id, location_id)
+);
+
+CREATE TABLE IF NOT EXISTS inventory_reservations (
+    reservation_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    line_item_id INT REFERENCES estimate_items(line_item_id) ON DELETE SET NULL,
+    product_id INT NOT NULL REFERENCES products(product_id),
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(10) NOT NULL DEFAULT 'reserved' CHECK (status IN ('reserved', 'consumed')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_reservations_estimate ON inventory_reservations(estimate_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_reservations_open ON inventory_reservations(product_id, location_id)
+    WHERE status = 'reserved';
+
+-- Every change to on_hand is recorded here.
+CREATE TABLE IF NOT EXISTS inventory_adjustments (
+    adjustment_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    location_id INT NOT NULL REFERENCES inventory_locations(location_id) ON DELETE CASCADE,
+    delta INT NOT NULL,
+    reason VARCHAR(255) NOT NULL,
+    estimate_id INT REFERENCES estimates(estimate_id) ON DELETE SET NULL,
+    adjusted_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inventory_adjustments_product ON inventory_adjustments(product_id, created_at);
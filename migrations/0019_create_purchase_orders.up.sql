@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS purchase_orders (
+    po_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    vendor_id INT NOT NULL REFERENCES vendors(vendor_id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'sent', 'partially_received', 'received')),
+    expected_delivery DATE,
+    created_by INT NOT NULL REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_estimate ON purchase_orders(estimate_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_status ON purchase_orders(status, created_at);
+
+-- Lines keep the product name, SKU and cost as ordered so a PO does not change when the catalog does.
+CREATE TABLE IF NOT EXISTS purchase_order_lines (
+    po_line_id SERIAL PRIMARY KEY,
+    po_id INT NOT NULL REFERENCES purchase_orders(po_id) ON DELETE CASCADE,
+    line_item_id INT UNIQUE REFERENCES estimate_items(line_item_id) ON DELETE SET NULL,
+    product_id INT REFERENCES products(product_id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    sku VARCHAR(50) NOT NULL DEFAULT '',
+    model_number VARCHAR(50) NOT NULL DEFAULT '',
+    quantity INT NOT NULL CHECK (quantity > 0),
+    received_quantity INT NOT NULL DEFAULT 0 CHECK (received_quantity >= 0 AND received_quantity <= quantity),
+    unit_cost INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_lines_po ON purchase_order_lines(po_id);
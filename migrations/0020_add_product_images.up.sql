@@ -0,0 +1,3 @@
+-- image_key is the storage prefix of the product photo's resized variants, or '' when it has none.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS image_key VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- Every price a product has had or is scheduled to have. applied_at is NULL until a scheduled price takes effect.
+CREATE TABLE IF NOT EXISTS product_prices (
+    price_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    unit_price INT NOT NULL CHECK (unit_price >= 0),
+    effective_from DATE NOT NULL,
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_prices_product ON product_prices(product_id, effective_from);
+
+-- A product has at most one scheduled price per day.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_prices_scheduled ON product_prices(product_id, effective_from)
+    WHERE applied_at IS NULL;
+
+INSERT INTO product_prices (product_id, unit_price, effective_from, created_by, applied_at)
+SELECT product_id, unit_price, CURRENT_DATE, created_by, NOW()
+FROM products;
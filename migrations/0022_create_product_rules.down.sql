@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS estimate_site_confirmations;
+DROP TABLE IF EXISTS product_site_requirements;
+DROP TABLE IF EXISTS site_requirements;
+DROP TABLE IF EXISTS product_rules;
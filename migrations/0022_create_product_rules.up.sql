@@ -0,0 +1,48 @@
+-- A rule links a product to another product, or to any product of a subcategory.
+CREATE TABLE IF NOT EXISTS product_rules (
+    rule_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('requires', 'excludes', 'recommends')),
+    target_product_id INT REFERENCES products(product_id) ON DELETE CASCADE,
+    target_subcategory VARCHAR(50),
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK ((target_product_id IS NULL) <> (target_subcategory IS NULL)),
+    CHECK (target_product_id IS DISTINCT FROM product_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_rules_unique ON product_rules
+    (product_id, kind, COALESCE(target_product_id, 0), LOWER(COALESCE(target_subcategory, '')));
+CREATE INDEX IF NOT EXISTS idx_product_rules_target ON product_rules(target_product_id);
+
+-- Things a product needs at the site, ex. a 240V circuit.
+CREATE TABLE IF NOT EXISTS site_requirements (
+    requirement_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_site_requirements_name ON site_requirements(LOWER(name));
+
+INSERT INTO site_requirements (name) VALUES
+    ('240V circuit'),
+    ('Dedicated 20A circuit'),
+    ('Gas line'),
+    ('Water line'),
+    ('Exterior vent')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS product_site_requirements (
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    requirement_id INT NOT NULL REFERENCES site_requirements(requirement_id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, requirement_id)
+);
+
+-- The site requirements a surveyor confirmed are in place for an estimate.
+CREATE TABLE IF NOT EXISTS estimate_site_confirmations (
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    requirement_id INT NOT NULL REFERENCES site_requirements(requirement_id) ON DELETE CASCADE,
+    confirmed_by INT REFERENCES users(user_id),
+    confirmed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (estimate_id, requirement_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE purchase_order_lines DROP CONSTRAINT IF EXISTS purchase_order_lines_line_item_product_key;
+ALTER TABLE purchase_order_lines ADD CONSTRAINT purchase_order_lines_line_item_id_key UNIQUE (line_item_id);
+
+DROP TABLE IF EXISTS product_bundle_components;
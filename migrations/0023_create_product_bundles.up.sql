@@ -0,0 +1,14 @@
+-- A bundle is a product sold at its own price that is made of other products. Bundles cannot contain bundles.
+CREATE TABLE IF NOT EXISTS product_bundle_components (
+    bundle_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(product_id) ON DELETE RESTRICT,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, component_id),
+    CHECK (bundle_id <> component_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_bundle_components_component ON product_bundle_components(component_id);
+
+-- A bundle line item is ordered as one purchase order line per component.
+ALTER TABLE purchase_order_lines DROP CONSTRAINT IF EXISTS purchase_order_lines_line_item_id_key;
+ALTER TABLE purchase_order_lines ADD CONSTRAINT purchase_order_lines_line_item_product_key UNIQUE (line_item_id, product_id);
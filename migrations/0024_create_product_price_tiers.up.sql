@@ -0,0 +1,12 @@
+-- Price breaks that replace a product's unit price once a line item reaches a quantity, or the estimate reaches an
+-- order value in cents. The lowest price a line item qualifies for applies.
+CREATE TABLE IF NOT EXISTS product_price_tiers (
+    tier_id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    basis VARCHAR(20) NOT NULL CHECK (basis IN ('quantity', 'order_value')),
+    threshold INT NOT NULL CHECK (threshold > 0),
+    unit_price INT NOT NULL CHECK (unit_price >= 0),
+    created_by INT REFERENCES users(user_id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, basis, threshold)
+);
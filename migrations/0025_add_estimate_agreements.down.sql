@@ -0,0 +1,2 @@
+ALTER TABLE estimates DROP COLUMN IF EXISTS agreement_object_key;
+ALTER TABLE estimates DROP COLUMN IF EXISTS signed_at;
@@ -0,0 +1,3 @@
+-- When the customer signed, and the PDF agreement generated from the signed estimate.
+ALTER TABLE estimates ADD COLUMN IF NOT EXISTS signed_at TIMESTAMPTZ;
+ALTER TABLE estimates ADD COLUMN IF NOT EXISTS agreement_object_key TEXT;
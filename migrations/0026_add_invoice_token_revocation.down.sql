@@ -0,0 +1,3 @@
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS revoked_by;
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS revoked_at;
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS created_by;
@@ -0,0 +1,4 @@
+-- Signing links can be revoked, and record who issued and revoked them.
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS created_by INT REFERENCES users(user_id);
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS revoked_by INT REFERENCES users(user_id);
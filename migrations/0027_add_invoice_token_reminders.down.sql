@@ -0,0 +1,2 @@
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS reminded_at;
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS sealed_token;
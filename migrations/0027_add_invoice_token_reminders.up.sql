@@ -0,0 +1,4 @@
+-- Signing links keep a sealed copy of their raw token so reminders can resend the same link, and record when the
+-- customer was last reminded.
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS sealed_token BYTEA;
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMPTZ;
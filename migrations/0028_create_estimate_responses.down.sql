@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS estimate_responses;
+
+-- The earlier schema has no status for declined estimates, so they go back to Draft.
+UPDATE estimates SET status = 1 WHERE status = 5;
+
+ALTER TABLE estimates DROP CONSTRAINT IF EXISTS estimates_status_check;
+ALTER TABLE estimates ADD CONSTRAINT estimates_status_check CHECK (status >= 1 AND status <= 4);
@@ -0,0 +1,14 @@
+-- Customers can decline an estimate or ask for changes from the signing page instead of signing it.
+ALTER TABLE estimates DROP CONSTRAINT IF EXISTS estimates_status_check;
+ALTER TABLE estimates ADD CONSTRAINT estimates_status_check CHECK (status >= 1 AND status <= 5);
+
+CREATE TABLE IF NOT EXISTS estimate_responses (
+    response_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('declined', 'changes_requested')),
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_estimate_responses_estimate ON estimate_responses(estimate_id, created_at);
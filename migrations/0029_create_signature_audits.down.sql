@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS signature_audits;
+
+ALTER TABLE invoice_access_tokens DROP COLUMN IF EXISTS opened_at;
@@ -0,0 +1,20 @@
+-- Signing links record when the customer first opened them, and every signature keeps an audit record of who signed,
+-- from where, what they consented to and the hash of the agreement they signed.
+ALTER TABLE invoice_access_tokens ADD COLUMN IF NOT EXISTS opened_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS signature_audits (
+    audit_id SERIAL PRIMARY KEY,
+    estimate_id INT NOT NULL UNIQUE REFERENCES estimates(estimate_id) ON DELETE CASCADE,
+    invoice_token_id BIGINT REFERENCES invoice_access_tokens(invoice_token_id) ON DELETE SET NULL,
+    signer_name TEXT NOT NULL,
+    signer_email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    user_agent TEXT NOT NULL,
+    link_sent_at TIMESTAMPTZ NOT NULL,
+    link_opened_at TIMESTAMPTZ,
+    signed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    consent_version TEXT NOT NULL,
+    consent_text TEXT NOT NULL,
+    agreement_sha256 CHAR(64),
+    certificate_object_key TEXT
+);
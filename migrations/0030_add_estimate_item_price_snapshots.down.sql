@@ -0,0 +1,2 @@
+ALTER TABLE estimate_items DROP COLUMN IF EXISTS snapshot_price_tiers;
+ALTER TABLE estimate_items DROP COLUMN IF EXISTS snapshot_unit_price;
@@ -0,0 +1,13 @@
+-- Line items keep the unit price and price breaks their product had when the estimate left Draft, so catalog price
+-- changes only reprice Draft estimates.
+ALTER TABLE estimate_items ADD COLUMN IF NOT EXISTS snapshot_unit_price INT;
+ALTER TABLE estimate_items ADD COLUMN IF NOT EXISTS snapshot_price_tiers JSONB;
+
+UPDATE estimate_items ei
+SET snapshot_unit_price = p.unit_price,
+    snapshot_price_tiers = COALESCE((
+        SELECT jsonb_agg(jsonb_build_object('TierID', pt.tier_id, 'ProductID', pt.product_id, 'Basis', pt.basis,
+            'Threshold', pt.threshold, 'UnitPrice', pt.unit_price) ORDER BY pt.basis, pt.threshold)
+        FROM product_price_tiers pt WHERE pt.product_id = p.product_id), '[]'::jsonb)
+FROM products p, estimates e
+WHERE p.product_id = ei.product_id AND e.estimate_id = ei.estimate_id AND e.status <> 1;
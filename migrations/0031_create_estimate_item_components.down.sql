@@ -0,0 +1 @@
+DROP TABLE IF EXISTS estimate_item_components;
@@ -0,0 +1,16 @@
+-- Bundle line items keep the components their bundle had when the estimate left Draft, so editing a bundle only
+-- changes what Draft estimates render, reserve and order.
+CREATE TABLE IF NOT EXISTS estimate_item_components (
+    line_item_id INT NOT NULL REFERENCES estimate_items(line_item_id) ON DELETE CASCADE,
+    component_id INT NOT NULL REFERENCES products(product_id) ON DELETE RESTRICT,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (line_item_id, component_id)
+);
+
+INSERT INTO estimate_item_components (line_item_id, component_id, quantity)
+SELECT ei.line_item_id, bc.component_id, bc.quantity
+FROM estimate_items ei
+JOIN estimates e ON e.estimate_id = ei.estimate_id
+JOIN product_bundle_components bc ON bc.bundle_id = ei.product_id
+WHERE e.status <> 1
+ON CONFLICT DO NOTHING;
@@ -38,26 +38,44 @@ function setupAddProductBtn() {
             let subcat = this.closest(".subcategory-panel").id
             let color = ""
 
+            // Products added from an option panel become alternates of that option
+            document.querySelector(".product-modal").dataset.optionId =
+                this.dataset.optionId || ""
+
             // Skip subcategory filter if it matches category
             if (category == subcat) subcat = ""
 
-            let url = `/product/get/?category=${category}&subcategory=${subcat}&color=${color}`
+            await loadProductModal(category, subcat, color, "")
+        })
+    }
 
-            try {
-                const response = await csrfFetch(url)
-                if (!response.ok)
-                    throw new Error(`Response Status: ${response.status}`)
+    // Search within the open modal, keeping the category it was opened for
+    document.addEventListener("submit", async (e) => {
+        const form = e.target.closest(".product-search")
+        if (!form) return
 
-                const modalHTML = await response.text()
-                document.querySelector(".product-modal").innerHTML = modalHTML
-                document.querySelector(".product-modal").showModal()
+        e.preventDefault()
+        await loadProductModal(
+            form.dataset.category,
+            form.dataset.subcategory,
+            form.dataset.color,
+            form.elements.q.value,
+        )
+    })
 
-                setupAddProductToEstimateBtn()
-            } catch (error) {
-                console.error(error.message)
-            }
-        })
-    }
+    // Narrow the open modal to a subcategory or color picked from the filter lists
+    document.addEventListener("click", async (e) => {
+        const facet = e.target.closest(".facet-btn")
+        if (!facet) return
+
+        const form = document.querySelector(".product-search")
+        await loadProductModal(
+            form.dataset.category,
+            facet.dataset.subcategory ?? form.dataset.subcategory,
+            facet.dataset.color ?? form.dataset.color,
+            form.elements.q.value,
+        )
+    })
 
     // Close modal when clicking the close button
     document.addEventListener("click", (e) => {
@@ -68,6 +86,114 @@ function setupAddProductBtn() {
     })
 }
 
+// Fetches the product modal for a category and optional search text, then shows it.
+async function loadProductModal(category, subcat, color, query) {
+    const params = new URLSearchParams({
+        category: category || "",
+        subcategory: subcat || "",
+        color: color || "",
+        q: query || "",
+    })
+
+    try {
+        const response = await csrfFetch(`/product/get/?${params}`)
+        if (!response.ok) throw new Error(`Response Status: ${response.status}`)
+
+        const modal = document.querySelector(".product-modal")
+        modal.innerHTML = await response.text()
+        if (!modal.open) modal.showModal()
+
+        setupVariantPickers()
+        setupAddProductToEstimateBtn()
+        await loadProductFacets(modal, params)
+
+        const input = modal.querySelector(".product-search input")
+        if (query && input) {
+            input.focus()
+            input.setSelectionRange(input.value.length, input.value.length)
+        }
+    } catch (error) {
+        console.error(error.message)
+    }
+}
+
+// Fills the filter lists of the product modal with the subcategories and colors of the products it shows, taken
+// from the product search API. The lists are left hidden if the API cannot be reached.
+async function loadProductFacets(modal, params) {
+    const container = modal.querySelector(".product-facets")
+    if (!container) return
+
+    const search = new URLSearchParams(params)
+    search.set("per_page", "1")
+
+    let data
+    try {
+        const response = await csrfFetch(`/product/search?${search}`)
+        if (!response.ok) throw new Error(`Response Status: ${response.status}`)
+        data = await response.json()
+    } catch (error) {
+        console.error(error.message)
+        return
+    }
+
+    container.replaceChildren()
+    const addGroup = (label, field, facets, selected) => {
+        if (!selected && facets.length < 2) return
+
+        const group = document.createElement("div")
+        group.className = "facet-group"
+        const title = document.createElement("strong")
+        title.textContent = label
+        group.appendChild(title)
+
+        for (const facet of facets) {
+            const btn = document.createElement("button")
+            btn.type = "button"
+            btn.className = "facet-btn"
+            btn.classList.toggle("active", facet.value === selected)
+            btn.dataset[field] = facet.value === selected ? "" : facet.value
+            btn.textContent = `${facet.value} (${facet.count})`
+            group.appendChild(btn)
+        }
+        container.appendChild(group)
+    }
+
+    addGroup(
+        "Type",
+        "subcategory",
+        data.facets.subcategories,
+        search.get("subcategory"),
+    )
+    addGroup("Color", "color", data.facets.colors, search.get("color"))
+    container.hidden = container.childElementCount === 0
+}
+
+// Switches a family card to the chosen variant so its price, dimensions and add button match it.
+function setupVariantPickers() {
+    let pickers = document.getElementsByClassName("variant-picker")
+
+    for (let i = 0; i < pickers.length; i++) {
+        pickers[i].addEventListener("change", function () {
+            const card = this.closest(".card")
+            const option = this.options[this.selectedIndex]
+
+            card.querySelector(".add-item-btn").id = this.value
+            card.querySelector(".card-product-price").textContent =
+                option.dataset.price
+            card.querySelector(".card-product-dimensions").textContent =
+                option.dataset.dimensions
+
+            const image = card.querySelector(".card-product-image")
+            image.hidden = !option.dataset.image
+            if (option.dataset.image) {
+                image.src = option.dataset.image
+            } else {
+                image.removeAttribute("src")
+            }
+        })
+    }
+}
+
 // Handles adding a product from the modal to the current estimate.
 function setupAddProductToEstimateBtn() {
     let addItemBtn = document.getElementsByClassName("add-item-btn")
@@ -80,6 +206,10 @@ function setupAddProductToEstimateBtn() {
                 ".card-product-quantity",
             ).value
             const productID = parseInt(this.id)
+            const optionID =
+                parseInt(
+                    document.querySelector(".product-modal").dataset.optionId,
+                ) || 0
 
             // Basic validation for quantity
             if (quantity < 1 || isNaN(quantity)) {
@@ -95,6 +225,7 @@ function setupAddProductToEstimateBtn() {
                         body: JSON.stringify({
                             product_id: productID,
                             quantity: parseInt(quantity),
+                            option_id: optionID,
                         }),
                     },
                 )
@@ -102,7 +233,11 @@ function setupAddProductToEstimateBtn() {
                 if (!response.ok) {
                     const data = await response.json().catch(() => ({}))
                     if (data.errors) {
-                        handleProductErrors(this, data.errors)
+                        handleProductErrors(this, data.errors, data.warnings)
+                        return
+                    }
+                    if (data.error) {
+                        showInlineError(this, data.error)
                         return
                     }
                     throw new Error(`Response Status: ${response.status}`)
@@ -128,6 +263,9 @@ function setupUpdateItemBtn() {
             const newQuantity = currentRow.querySelector(
                 ".estimate-item-quantity",
             ).value
+            const isOptional = currentRow.querySelector(
+                ".estimate-item-optional",
+            ).checked
 
             if (newQuantity < 1 || isNaN(newQuantity)) {
                 alert("Quantity must be at least 1")
@@ -141,11 +279,14 @@ function setupUpdateItemBtn() {
                         method: "PUT",
                         body: JSON.stringify({
                             quantity: parseInt(newQuantity),
+                            is_optional: isOptional,
                         }),
                     },
                 )
-                if (!response.ok)
-                    throw new Error(`Response status: ${response.status}`)
+                if (!response.ok) {
+                    await alertPolicyError(response)
+                    return
+                }
                 location.reload()
             } catch (error) {
                 console.error(error.message)
@@ -167,8 +308,10 @@ function setupDeleteBtn() {
                         method: "DELETE",
                     },
                 )
-                if (!response.ok)
-                    throw new Error(`Response status: ${response.status}`)
+                if (!response.ok) {
+                    await alertPolicyError(response)
+                    return
+                }
                 location.reload()
             } catch (error) {
                 console.error(error.message)
@@ -177,12 +320,179 @@ function setupDeleteBtn() {
     }
 }
 
-// Displays error messages returned by the backend inline in the modal.
-function handleProductErrors(button, errors) {
-    document.querySelectorAll(".product-error").forEach((e) => e.remove())
+// Sends every changed quantity and optional flag in a single batch so the changes are applied all at once.
+// If any row is invalid nothing is saved and the errors are shown next to the offending rows.
+function setupSaveAllBtn() {
+    const saveAllBtn = document.querySelector(".save-all-btn")
+    if (!saveAllBtn) return
+
+    saveAllBtn.addEventListener("click", async function () {
+        const estimateID =
+            document.querySelector(".estimate-ID").dataset.estimateId
+        const rows = []
+        const operations = []
+
+        document.querySelectorAll("tr[data-line-item-id]").forEach((row) => {
+            const quantity = parseInt(
+                row.querySelector(".estimate-item-quantity").value,
+            )
+            const isOptional = row.querySelector(
+                ".estimate-item-optional",
+            ).checked
+
+            if (
+                quantity === parseInt(row.dataset.quantity) &&
+                isOptional === (row.dataset.optional === "true")
+            )
+                return
+
+            rows.push(row)
+            operations.push({
+                op: "update",
+                line_item_id: parseInt(row.dataset.lineItemId),
+                quantity: quantity,
+                is_optional: isOptional,
+            })
+        })
+
+        if (operations.length === 0) return
+
+        try {
+            const response = await csrfFetch(
+                `/estimate/${estimateID}/items/batch`,
+                {
+                    method: "POST",
+                    body: JSON.stringify({ operations }),
+                },
+            )
+            const data = await response.json().catch(() => ({}))
+
+            if (!response.ok) {
+                if (data.results) {
+                    showBatchErrors(rows, data.results)
+                    return
+                }
+                throw new Error(data.error || `Response Status: ${response.status}`)
+            }
+
+            location.reload()
+        } catch (error) {
+            alert("Something went wrong while saving your changes.")
+            console.error(error.message)
+        }
+    })
+}
+
+// Shows the per-operation errors of a rejected batch next to the row each operation came from.
+function showBatchErrors(rows, results) {
+    document.querySelectorAll(".batch-error").forEach((e) => e.remove())
+
+    results.forEach((result) => {
+        if (result.ok || !rows[result.index]) return
+
+        const err = document.createElement("p")
+        err.className = "batch-error"
+        err.style.color = "red"
+        err.style.margin = "5px 0 0 0"
+        err.textContent = Object.values(result.errors).join(" ")
+        rows[result.index]
+            .querySelector(".estimate-item-quantity")
+            .insertAdjacentElement("afterend", err)
+    })
+}
+
+// Handles adding custom (non-catalog) line items from the Custom Items form.
+function setupCustomItemForm() {
+    const form = document.querySelector(".custom-item-form")
+    if (!form) return
+
+    form.addEventListener("submit", async function (e) {
+        e.preventDefault()
+        form.querySelectorAll(".error").forEach((el) => (el.textContent = ""))
+
+        const estimateID =
+            document.querySelector(".estimate-ID").dataset.estimateId
+        // Prices are entered in dollars but stored in cents.
+        const unitPrice = Math.round(
+            parseFloat(form.elements["unit_price"].value) * 100,
+        )
+
+        try {
+            const response = await csrfFetch(
+                `/estimate/${estimateID}/items/custom`,
+                {
+                    method: "POST",
+                    body: JSON.stringify({
+                        description: form.elements["description"].value,
+                        unit_price: isNaN(unitPrice) ? -1 : unitPrice,
+                        quantity: parseInt(form.elements["quantity"].value) || 0,
+                        category: form.elements["category"].value,
+                        taxable: form.elements["taxable"].checked,
+                    }),
+                },
+            )
+
+            if (!response.ok) {
+                const data = await response.json().catch(() => ({}))
+                if (data.errors) {
+                    for (const [field, message] of Object.entries(data.errors)) {
+                        const el = form.querySelector(
+                            `[data-error-for="${field}"]`,
+                        )
+                        if (el) el.textContent = message
+                    }
+                    return
+                }
+                if (data.error) {
+                    alert(data.error)
+                    return
+                }
+                throw new Error(`Response Status: ${response.status}`)
+            }
+
+            location.reload()
+        } catch (error) {
+            alert("Something went wrong while adding the custom item.")
+            console.error(error.message)
+        }
+    })
+}
+
+// Shows the message of a rejected line item change, such as a 403 when the estimate is not shared with the user
+// or a 409 when the estimate is no longer a draft.
+async function alertPolicyError(response) {
+    const data = await response.json().catch(() => ({}))
+    if (data.error) {
+        alert(data.error)
+        return
+    }
+    if (data.errors) {
+        alert(Object.values(data.errors).join(" "))
+        return
+    }
+    console.error(`Response status: ${response.status}`)
+}
+
+// Displays error messages returned by the backend inline in the modal, followed by any product rule warnings
+// the estimate would have with the product added.
+function handleProductErrors(button, errors, warnings = []) {
+    document
+        .querySelectorAll(".product-error, .product-warning")
+        .forEach((e) => e.remove())
 
     if (errors.quantity) showInlineError(button, errors.quantity)
     if (errors.product) showInlineError(button, errors.product)
+    if (errors.option) showInlineError(button, errors.option)
+
+    const quantityInput = button
+        .closest(".card")
+        .querySelector(".card-product-quantity")
+    for (const warning of warnings || []) {
+        const note = document.createElement("p")
+        note.className = "product-warning"
+        note.textContent = warning.message
+        quantityInput.parentElement.appendChild(note)
+    }
 }
 
 // Creates a small inline error message below the input.
@@ -204,3 +514,5 @@ setupAccordion("subcategory-accordion", "flex")
 setupAddProductBtn()
 setupUpdateItemBtn()
 setupDeleteBtn()
+setupSaveAllBtn()
+setupCustomItemForm()
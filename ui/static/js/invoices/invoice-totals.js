@@ -0,0 +1,57 @@
+// Recalculates the agreement totals whenever the customer changes an option or add-on.
+// The server is the source of truth for totals so the labor and tax rules only live in one place.
+function setupInvoiceTotals() {
+    const form = document.getElementById("agreement-form")
+    if (!form) return
+
+    const inputs = document.querySelectorAll(
+        'input[form="agreement-form"][name^="option_"], input[form="agreement-form"][name="addon"]',
+    )
+
+    inputs.forEach((input) => {
+        input.addEventListener("change", refreshTotals)
+    })
+}
+
+// Collects the current selections and asks the server for the updated totals.
+async function refreshTotals() {
+    const token = document.getElementById("token").value
+    const options = {}
+    const addons = []
+
+    document
+        .querySelectorAll('input[form="agreement-form"][name^="option_"]:checked')
+        .forEach((input) => {
+            options[input.name.replace("option_", "")] = parseInt(input.value)
+        })
+
+    document
+        .querySelectorAll('input[form="agreement-form"][name="addon"]:checked')
+        .forEach((input) => {
+            addons.push(parseInt(input.value))
+        })
+
+    try {
+        const response = await csrfFetch("/invoice/totals", {
+            method: "POST",
+            body: JSON.stringify({ token, options, addons }),
+        })
+        if (!response.ok)
+            throw new Error(`Response Status: ${response.status}`)
+
+        const totals = await response.json()
+        setTotal("total-subtotal", totals.subtotal)
+        setTotal("total-labor", totals.labor_total)
+        setTotal("total-sales-tax", totals.sales_tax)
+        setTotal("total-estimate", totals.estimate_total)
+    } catch (error) {
+        console.error(error.message)
+    }
+}
+
+// Writes a cent value into the element with the given id as dollars.
+function setTotal(id, cents) {
+    document.getElementById(id).textContent = "$" + (cents / 100).toFixed(2)
+}
+
+setupInvoiceTotals()